@@ -0,0 +1,87 @@
+package almacenamiento
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNoExiste se retorna cuando la clave pedida no está en el almacenamiento.
+var ErrNoExiste = errors.New("archivo no existe en el almacenamiento")
+
+// Storage guarda archivos por clave (ej: "12_1700000000.jpg").
+// Las implementaciones deben ser seguras para uso concurrente.
+type Storage interface {
+	// Put guarda el contenido de r bajo la clave, reemplazándolo si ya existe.
+	Put(ctx context.Context, clave string, r io.Reader, tipoContenido string) error
+	// Get abre el archivo de la clave, o retorna ErrNoExiste. El llamador debe cerrarlo.
+	Get(ctx context.Context, clave string) (io.ReadCloser, error)
+	// Delete borra el archivo de la clave. No falla si no existe.
+	Delete(ctx context.Context, clave string) error
+	// URL retorna la URL pública del archivo. Si es relativa (empieza con "/") se sirve desde esta API.
+	URL(clave string) string
+	// List retorna todos los archivos guardados, ej: para buscar archivos huérfanos.
+	List(ctx context.Context) ([]Objeto, error)
+}
+
+// Objeto es un archivo listado por Storage.List.
+type Objeto struct {
+	Clave      string
+	Modificado time.Time
+}
+
+// Portadas es el almacenamiento de las portadas de películas. Se asigna en Cargar.
+var Portadas Storage
+
+// RutaImagenes es la ruta desde donde la API sirve los archivos de Portadas (ver rutas.ServirImagen).
+const RutaImagenes = "/imagenes/"
+
+// Cargar configura Portadas según STORAGE_DRIVER y la firma de sus URLs (ver cargarFirma):
+//   - "local" (por defecto): disco en STORAGE_LOCAL_DIR (default "public/upload/portadas").
+//   - "s3": API compatible con S3 (AWS, MinIO, etc.), ver NuevoS3.
+//   - "memoria": en memoria, solo para pruebas; se pierde al reiniciar.
+func Cargar() error {
+	if err := cargarFirma(); err != nil {
+		return err
+	}
+
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "", "local":
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "public/upload/portadas"
+		}
+		Portadas = NuevoLocal(dir)
+	case "s3":
+		s3, err := NuevoS3(S3Config{
+			Endpoint:   os.Getenv("S3_ENDPOINT"),
+			Region:     os.Getenv("S3_REGION"),
+			Bucket:     os.Getenv("S3_BUCKET"),
+			AccessKey:  os.Getenv("S3_ACCESS_KEY"),
+			SecretKey:  os.Getenv("S3_SECRET_KEY"),
+			URLPublica: os.Getenv("S3_PUBLIC_URL"),
+		})
+		if err != nil {
+			return err
+		}
+		Portadas = s3
+	case "memoria":
+		Portadas = NuevoMemoria()
+	default:
+		return fmt.Errorf("STORAGE_DRIVER desconocido: %s (opciones: local, s3, memoria)", driver)
+	}
+	return nil
+}
+
+// validarClave evita claves vacías, absolutas o que salgan del directorio base (ej: "../main.go").
+func validarClave(clave string) error {
+	if clave == "" || strings.HasPrefix(clave, "/") || path.Clean(clave) != clave || strings.HasPrefix(clave, "..") {
+		return fmt.Errorf("clave de almacenamiento inválida: %q", clave)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package almacenamiento
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Las URLs de imágenes servidas por esta API (RutaImagenes) llevan ?exp=<unix>&sig=<hmac>,
+// así no se pueden adivinar ni enlazar desde otros sitios más allá de su expiración.
+// Con S3_PUBLIC_URL las URLs apuntan al bucket y van prefirmadas por S3 con la misma expiración (ver S3.URL).
+
+var (
+	ErrFirmaInvalida = errors.New("firma de URL inválida")
+	ErrURLExpirada   = errors.New("URL expirada")
+)
+
+// Configuración de la firma, se asigna en cargarFirma.
+var (
+	secretoURL []byte
+	ttlURL     time.Duration
+)
+
+// ventanaExpiracion redondea la expiración hacia arriba para que un mismo archivo tenga la misma URL
+// durante ese lapso, y el navegador o un proxy la puedan cachear.
+const ventanaExpiracion = 5 * time.Minute
+
+// cargarFirma lee IMAGENES_URL_SECRET (requerido) e IMAGENES_URL_TTL (default 1h, formato time.ParseDuration).
+func cargarFirma() error {
+	secreto := os.Getenv("IMAGENES_URL_SECRET")
+	if len(secreto) < 32 {
+		return fmt.Errorf("IMAGENES_URL_SECRET es requerido y debe tener al menos 32 caracteres")
+	}
+	secretoURL = []byte(secreto)
+
+	ttlURL = time.Hour
+	if d, err := time.ParseDuration(os.Getenv("IMAGENES_URL_TTL")); err == nil && d > 0 {
+		ttlURL = d
+	}
+	return nil
+}
+
+// FirmarURL agrega exp y sig a una ruta relativa de imagen (ej: "/imagenes/12_170.jpg").
+func FirmarURL(ruta, clave string, ahora time.Time) string {
+	exp := ahora.Add(ttlURL + ventanaExpiracion - 1).Truncate(ventanaExpiracion).Unix()
+	parametros := url.Values{}
+	parametros.Set("exp", strconv.FormatInt(exp, 10))
+	parametros.Set("sig", firmar(clave, exp))
+	return ruta + "?" + parametros.Encode()
+}
+
+// VerificarFirma valida exp y sig de la URL de la clave. Retorna la expiración para calcular el Cache-Control.
+func VerificarFirma(clave, expStr, sig string, ahora time.Time) (time.Time, error) {
+	exp, err := strconv.ParseInt(expStr, 10, 64)
+	if err != nil || sig == "" {
+		return time.Time{}, ErrFirmaInvalida
+	}
+	// Se compara la firma antes que la expiración, para no confirmar qué claves existen
+	if !hmac.Equal([]byte(sig), []byte(firmar(clave, exp))) {
+		return time.Time{}, ErrFirmaInvalida
+	}
+	expira := time.Unix(exp, 0)
+	if ahora.After(expira) {
+		return time.Time{}, ErrURLExpirada
+	}
+	return expira, nil
+}
+
+func firmar(clave string, exp int64) string {
+	h := hmac.New(sha256.New, secretoURL)
+	h.Write([]byte(clave + "\n" + strconv.FormatInt(exp, 10)))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
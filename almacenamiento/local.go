@@ -0,0 +1,118 @@
+package almacenamiento
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Local guarda los archivos en un directorio del disco.
+// Sirve para una sola réplica o varias que compartan el directorio (ej: NFS).
+type Local struct {
+	dir string
+}
+
+func NuevoLocal(dir string) *Local {
+	return &Local{dir: dir}
+}
+
+func (l *Local) ruta(clave string) (string, error) {
+	if err := validarClave(clave); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(clave)), nil
+}
+
+// Put escribe primero a un archivo temporal y luego lo renombra, así un Get concurrente nunca ve un archivo a medias.
+func (l *Local) Put(ctx context.Context, clave string, r io.Reader, tipoContenido string) error {
+	ruta, err := l.ruta(clave)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ruta), 0755); err != nil {
+		return fmt.Errorf("error creando directorio: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(ruta), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creando archivo temporal: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No hace nada si ya se renombró
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error guardando archivo: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error guardando archivo: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("error guardando archivo: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), ruta); err != nil {
+		return fmt.Errorf("error guardando archivo: %w", err)
+	}
+	return nil
+}
+
+func (l *Local) Get(ctx context.Context, clave string) (io.ReadCloser, error) {
+	ruta, err := l.ruta(clave)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(ruta)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNoExiste
+	}
+	return f, err
+}
+
+func (l *Local) Delete(ctx context.Context, clave string) error {
+	ruta, err := l.ruta(clave)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(ruta); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error borrando archivo %s: %w", clave, err)
+	}
+	return nil
+}
+
+// List recorre el directorio omitiendo los temporales de Put que no alcanzaron a renombrarse.
+func (l *Local) List(ctx context.Context) ([]Objeto, error) {
+	var objetos []Objeto
+	err := filepath.WalkDir(l.dir, func(ruta string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && ruta == l.dir {
+				return fs.SkipAll // Aún no se ha subido nada
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		relativa, err := filepath.Rel(l.dir, ruta)
+		if err != nil {
+			return err
+		}
+		objetos = append(objetos, Objeto{Clave: filepath.ToSlash(relativa), Modificado: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listando %s: %w", l.dir, err)
+	}
+	return objetos, nil
+}
+
+func (l *Local) URL(clave string) string {
+	return RutaImagenes + clave
+}
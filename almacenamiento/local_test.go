@@ -0,0 +1,111 @@
+package almacenamiento
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLocalIdaYVuelta(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "portadas")
+	l := NuevoLocal(dir)
+	ctx := context.Background()
+
+	// Sin nada subido el directorio no existe y List no falla
+	if objetos, err := l.List(ctx); err != nil || len(objetos) != 0 {
+		t.Fatalf("List de un directorio inexistente = %v, %v", objetos, err)
+	}
+
+	archivos := map[string]string{
+		"12_1700000000.jpg":     "original",
+		"12_1700000000_300.jpg": "miniatura",
+		"otros/anidado.png":     "anidado",
+	}
+	for clave, contenido := range archivos {
+		if err := l.Put(ctx, clave, strings.NewReader(contenido), "image/jpeg"); err != nil {
+			t.Fatalf("Put(%q): %v", clave, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(clave))); err != nil {
+			t.Errorf("la clave %q no quedó en su ruta: %v", clave, err)
+		}
+	}
+	// Un temporal que Put no alcanzó a renombrar no se lista
+	if err := os.WriteFile(filepath.Join(dir, ".tmp-123"), []byte("a medias"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for clave, contenido := range archivos {
+		r, err := l.Get(ctx, clave)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", clave, err)
+		}
+		leido, _ := io.ReadAll(r)
+		r.Close()
+		if string(leido) != contenido {
+			t.Errorf("Get(%q) = %q, se esperaba %q", clave, leido, contenido)
+		}
+	}
+
+	objetos, err := l.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	claves := make([]string, len(objetos))
+	for i, o := range objetos {
+		claves[i] = o.Clave
+		if o.Modificado.IsZero() {
+			t.Errorf("List sin fecha de modificación para %q", o.Clave)
+		}
+	}
+	sort.Strings(claves)
+	if esperadas := []string{"12_1700000000.jpg", "12_1700000000_300.jpg", "otros/anidado.png"}; strings.Join(claves, ",") != strings.Join(esperadas, ",") {
+		t.Errorf("List = %v, se esperaba %v", claves, esperadas)
+	}
+
+	// Put reemplaza el contenido
+	if err := l.Put(ctx, "12_1700000000.jpg", strings.NewReader("nuevo"), "image/jpeg"); err != nil {
+		t.Fatalf("Put de reemplazo: %v", err)
+	}
+	r, err := l.Get(ctx, "12_1700000000.jpg")
+	if err != nil {
+		t.Fatalf("Get después del reemplazo: %v", err)
+	}
+	leido, _ := io.ReadAll(r)
+	r.Close()
+	if string(leido) != "nuevo" {
+		t.Errorf("Get después del reemplazo = %q", leido)
+	}
+
+	if err := l.Delete(ctx, "12_1700000000.jpg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := l.Delete(ctx, "12_1700000000.jpg"); err != nil {
+		t.Errorf("Delete de una clave ya borrada: %v", err)
+	}
+	if _, err := l.Get(ctx, "12_1700000000.jpg"); !errors.Is(err, ErrNoExiste) {
+		t.Errorf("Get después de Delete: %v, se esperaba ErrNoExiste", err)
+	}
+
+	if got := l.URL("12_1.jpg"); got != RutaImagenes+"12_1.jpg" {
+		t.Errorf("URL = %q", got)
+	}
+}
+
+func TestLocalClavesInvalidas(t *testing.T) {
+	l := NuevoLocal(t.TempDir())
+	ctx := context.Background()
+
+	for _, clave := range []string{"", "/etc/passwd", "../main.go", "a/../../b.jpg", "a//b.jpg"} {
+		if err := l.Put(ctx, clave, strings.NewReader("x"), "image/jpeg"); err == nil {
+			t.Errorf("Put aceptó la clave inválida %q", clave)
+		}
+		if _, err := l.Get(ctx, clave); err == nil || errors.Is(err, ErrNoExiste) {
+			t.Errorf("Get(%q) = %v, se esperaba un error de clave inválida", clave, err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package almacenamiento
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Memoria guarda los archivos en un map, pensado para pruebas y desarrollo.
+type Memoria struct {
+	mu       sync.RWMutex
+	archivos map[string]archivoMemoria
+}
+
+type archivoMemoria struct {
+	contenido  []byte
+	modificado time.Time
+}
+
+func NuevoMemoria() *Memoria {
+	return &Memoria{archivos: make(map[string]archivoMemoria)}
+}
+
+func (m *Memoria) Put(ctx context.Context, clave string, r io.Reader, tipoContenido string) error {
+	if err := validarClave(clave); err != nil {
+		return err
+	}
+	contenido, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("error leyendo archivo: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.archivos[clave] = archivoMemoria{contenido: contenido, modificado: time.Now()}
+	return nil
+}
+
+func (m *Memoria) Get(ctx context.Context, clave string) (io.ReadCloser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	archivo, ok := m.archivos[clave]
+	if !ok {
+		return nil, ErrNoExiste
+	}
+	// El slice guardado no se modifica nunca (Put lo reemplaza), así que se puede leer sin copiar
+	return io.NopCloser(bytes.NewReader(archivo.contenido)), nil
+}
+
+func (m *Memoria) Delete(ctx context.Context, clave string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.archivos, clave)
+	return nil
+}
+
+func (m *Memoria) URL(clave string) string {
+	return RutaImagenes + clave
+}
+
+func (m *Memoria) List(ctx context.Context) ([]Objeto, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	objetos := make([]Objeto, 0, len(m.archivos))
+	for clave, archivo := range m.archivos {
+		objetos = append(objetos, Objeto{Clave: clave, Modificado: archivo.modificado})
+	}
+	return objetos, nil
+}
@@ -0,0 +1,316 @@
+package almacenamiento
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config agrupa los datos de conexión a una API compatible con S3.
+type S3Config struct {
+	Endpoint  string // ej: "https://s3.us-east-1.amazonaws.com" o "http://localhost:9000" (MinIO)
+	Region    string // Default "us-east-1", MinIO acepta cualquiera
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// Opcional, dirección pública de esta misma API S3 (ej: "https://imagenes.ejemplo.cl" delante de MinIO).
+	// Si está, los clientes descargan del bucket con URLs prefirmadas; si está vacía los archivos se sirven desde esta API.
+	URLPublica string
+}
+
+// S3 guarda los archivos en un bucket compatible con S3 usando URLs path-style (endpoint/bucket/clave),
+// firmando cada request con AWS Signature Version 4. Permite varias réplicas de la API sin disco compartido.
+type S3 struct {
+	cfg      S3Config
+	endpoint *url.URL
+	cliente  *http.Client
+}
+
+func NuevoS3(cfg S3Config) (*S3, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" || cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY y S3_SECRET_KEY son requeridos")
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("S3_ENDPOINT inválido: %q", cfg.Endpoint)
+	}
+	if cfg.URLPublica != "" {
+		if u, err := url.Parse(cfg.URLPublica); err != nil || u.Host == "" {
+			return nil, fmt.Errorf("S3_PUBLIC_URL inválida: %q", cfg.URLPublica)
+		}
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	return &S3{cfg: cfg, endpoint: endpoint, cliente: &http.Client{Timeout: 60 * time.Second}}, nil
+}
+
+// Put lee el archivo completo en memoria para calcular el hash del cuerpo que exige la firma.
+func (s *S3) Put(ctx context.Context, clave string, r io.Reader, tipoContenido string) error {
+	cuerpo, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("error leyendo archivo: %w", err)
+	}
+	resp, err := s.hacer(ctx, http.MethodPut, clave, cuerpo, tipoContenido)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errorS3("subiendo", clave, resp)
+	}
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, clave string) (io.ReadCloser, error) {
+	resp, err := s.hacer(ctx, http.MethodGet, clave, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNoExiste
+	default:
+		defer resp.Body.Close()
+		return nil, errorS3("leyendo", clave, resp)
+	}
+}
+
+func (s *S3) Delete(ctx context.Context, clave string) error {
+	resp, err := s.hacer(ctx, http.MethodDelete, clave, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return errorS3("borrando", clave, resp)
+	}
+}
+
+// URL retorna una URL prefirmada con URLPublica, que expira igual que las firmadas por FirmarURL.
+func (s *S3) URL(clave string) string {
+	if s.cfg.URLPublica != "" {
+		return s.urlPrefirmada(clave, time.Now().UTC())
+	}
+	return RutaImagenes + clave
+}
+
+// urlPrefirmada arma un GET con la firma SigV4 en el query string (X-Amz-*), sobre URLPublica.
+// La fecha se redondea a ventanaExpiracion para que la URL de un archivo se pueda cachear durante ese lapso.
+func (s *S3) urlPrefirmada(clave string, ahora time.Time) string {
+	base, err := url.Parse(s.cfg.URLPublica)
+	if err != nil {
+		return "" // NuevoS3 ya validó URLPublica
+	}
+	desde := ahora.Truncate(ventanaExpiracion)
+	amzFecha := desde.Format("20060102T150405Z")
+	fecha := desde.Format("20060102")
+	alcance := fecha + "/" + s.cfg.Region + "/s3/aws4_request"
+	expira := min(ttlURL+ventanaExpiracion, 7*24*time.Hour) // Máximo que acepta S3
+
+	u := *base
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + s.cfg.Bucket + "/" + clave
+	u.RawPath = codificarRuta(u.Path)
+	u.RawQuery = consultaCanonica(url.Values{
+		"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+		"X-Amz-Credential":    {s.cfg.AccessKey + "/" + alcance},
+		"X-Amz-Date":          {amzFecha},
+		"X-Amz-Expires":       {strconv.FormatInt(int64(expira/time.Second), 10)},
+		"X-Amz-SignedHeaders": {"host"},
+	})
+
+	requestCanonico := strings.Join([]string{
+		http.MethodGet,
+		u.EscapedPath(),
+		u.RawQuery,
+		"host:" + u.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	textoAFirmar := "AWS4-HMAC-SHA256\n" + amzFecha + "\n" + alcance + "\n" + hashHex([]byte(requestCanonico))
+
+	u.RawQuery += "&X-Amz-Signature=" + s.firma(fecha, textoAFirmar)
+	return u.String()
+}
+
+// listaObjetos es la respuesta XML de ListObjectsV2, solo con los campos usados.
+type listaObjetos struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List usa ListObjectsV2, que entrega hasta 1000 objetos por página.
+func (s *S3) List(ctx context.Context) ([]Objeto, error) {
+	var objetos []Objeto
+	consulta := url.Values{"list-type": {"2"}}
+	for {
+		resp, err := s.enviar(ctx, http.MethodGet, "", consulta, nil, "")
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			return nil, errorS3("listando", s.cfg.Bucket, resp)
+		}
+
+		var pagina listaObjetos
+		err = xml.NewDecoder(resp.Body).Decode(&pagina)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error leyendo listado de S3: %w", err)
+		}
+		for _, c := range pagina.Contents {
+			objetos = append(objetos, Objeto{Clave: c.Key, Modificado: c.LastModified})
+		}
+
+		if !pagina.IsTruncated || pagina.NextContinuationToken == "" {
+			return objetos, nil
+		}
+		consulta.Set("continuation-token", pagina.NextContinuationToken)
+	}
+}
+
+// hacer arma, firma y envía un request sobre el objeto de la clave.
+func (s *S3) hacer(ctx context.Context, metodo, clave string, cuerpo []byte, tipoContenido string) (*http.Response, error) {
+	if err := validarClave(clave); err != nil {
+		return nil, err
+	}
+	return s.enviar(ctx, metodo, clave, nil, cuerpo, tipoContenido)
+}
+
+// enviar arma, firma y envía un request sobre el bucket (clave vacía) o sobre un objeto.
+func (s *S3) enviar(ctx context.Context, metodo, clave string, consulta url.Values, cuerpo []byte, tipoContenido string) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + s.cfg.Bucket + "/" + clave
+	u.RawPath = codificarRuta(u.Path)
+	u.RawQuery = consultaCanonica(consulta)
+
+	req, err := http.NewRequestWithContext(ctx, metodo, u.String(), bytes.NewReader(cuerpo))
+	if err != nil {
+		return nil, fmt.Errorf("error armando request S3: %w", err)
+	}
+	if tipoContenido != "" {
+		req.Header.Set("Content-Type", tipoContenido)
+	}
+	s.firmar(req, cuerpo, time.Now().UTC())
+
+	resp, err := s.cliente.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error conectando a S3: %w", err)
+	}
+	return resp, nil
+}
+
+// firmar agrega los headers x-amz-* y Authorization según AWS Signature Version 4.
+// Se firman solo host, x-amz-content-sha256 y x-amz-date, que es el mínimo que exige S3.
+func (s *S3) firmar(req *http.Request, cuerpo []byte, ahora time.Time) {
+	amzFecha := ahora.Format("20060102T150405Z")
+	fecha := ahora.Format("20060102")
+	hashCuerpo := hashHex(cuerpo)
+
+	req.Header.Set("x-amz-date", amzFecha)
+	req.Header.Set("x-amz-content-sha256", hashCuerpo)
+
+	headersFirmados := "host;x-amz-content-sha256;x-amz-date"
+	headersCanonicos := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + hashCuerpo + "\n" +
+		"x-amz-date:" + amzFecha + "\n"
+	requestCanonico := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		headersCanonicos,
+		headersFirmados,
+		hashCuerpo,
+	}, "\n")
+
+	alcance := fecha + "/" + s.cfg.Region + "/s3/aws4_request"
+	textoAFirmar := "AWS4-HMAC-SHA256\n" + amzFecha + "\n" + alcance + "\n" + hashHex([]byte(requestCanonico))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, alcance, headersFirmados, s.firma(fecha, textoAFirmar)))
+}
+
+// firma deriva la clave de SigV4 del día (fecha, ej: "20240131") y firma textoAFirmar.
+func (s *S3) firma(fecha, textoAFirmar string) string {
+	clave := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), fecha)
+	clave = hmacSHA256(clave, s.cfg.Region)
+	clave = hmacSHA256(clave, "s3")
+	clave = hmacSHA256(clave, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(clave, textoAFirmar))
+}
+
+// codificarRuta aplica el URI encoding de SigV4: todo salvo A-Z a-z 0-9 - _ . ~ y "/" se codifica como %XX.
+func codificarRuta(ruta string) string {
+	return codificarURI(ruta, true)
+}
+
+// consultaCanonica arma el query string de SigV4: parámetros ordenados por nombre, con nombre y valor codificados
+// (incluido "/"). Se usa también como query del request, así lo firmado y lo enviado coinciden.
+func consultaCanonica(consulta url.Values) string {
+	nombres := make([]string, 0, len(consulta))
+	for nombre := range consulta {
+		nombres = append(nombres, nombre)
+	}
+	sort.Strings(nombres)
+
+	partes := make([]string, 0, len(nombres))
+	for _, nombre := range nombres {
+		for _, valor := range consulta[nombre] {
+			partes = append(partes, codificarURI(nombre, false)+"="+codificarURI(valor, false))
+		}
+	}
+	return strings.Join(partes, "&")
+}
+
+func codificarURI(texto string, conBarra bool) string {
+	var b strings.Builder
+	for i := 0; i < len(texto); i++ {
+		c := texto[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (conBarra && c == '/') {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hashHex(datos []byte) string {
+	suma := sha256.Sum256(datos)
+	return hex.EncodeToString(suma[:])
+}
+
+func hmacSHA256(clave []byte, datos string) []byte {
+	h := hmac.New(sha256.New, clave)
+	h.Write([]byte(datos))
+	return h.Sum(nil)
+}
+
+// errorS3 incluye el inicio del XML de error de S3 (ej: <Code>AccessDenied</Code>) para poder diagnosticar.
+func errorS3(accion, clave string, resp *http.Response) error {
+	detalle, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("error %s %s en S3: %s: %s", accion, clave, resp.Status, strings.TrimSpace(string(detalle)))
+}
@@ -0,0 +1,357 @@
+package almacenamiento
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	bucketPrueba    = "portadas"
+	accessKeyPrueba = "minio"
+	secretKeyPrueba = "minio-secreto"
+	regionPrueba    = "us-east-1"
+)
+
+// s3Falso imita lo que usa S3 de una API compatible (como MinIO): PUT, GET y DELETE de objetos y ListObjectsV2
+// paginado, con URLs path-style. Rechaza con 403 todo request cuya firma SigV4 no verifique, calculada aquí
+// de forma independiente a s3.go.
+type s3Falso struct {
+	t           *testing.T
+	mu          sync.Mutex
+	objetos     map[string][]byte // Clave -> contenido
+	porPagina   int
+	solicitudes []string // "METODO clave", para revisar el formato de las claves enviadas
+}
+
+func nuevoS3Falso(t *testing.T) (*s3Falso, *httptest.Server) {
+	f := &s3Falso{t: t, objetos: make(map[string][]byte), porPagina: 2}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *s3Falso) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cuerpo, _ := io.ReadAll(r.Body)
+	if err := verificarSigV4(r, cuerpo, time.Now().UTC()); err != nil {
+		f.t.Logf("firma rechazada en %s %s: %v", r.Method, r.URL, err)
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	ruta := strings.TrimPrefix(r.URL.Path, "/")
+	bucket, clave, _ := strings.Cut(ruta, "/")
+	if bucket != bucketPrueba {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.solicitudes = append(f.solicitudes, r.Method+" "+clave)
+
+	switch {
+	case r.Method == http.MethodGet && clave == "":
+		f.listar(w, r)
+	case r.Method == http.MethodPut:
+		f.objetos[clave] = cuerpo
+	case r.Method == http.MethodGet:
+		contenido, ok := f.objetos[clave]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(contenido)
+	case r.Method == http.MethodDelete:
+		delete(f.objetos, clave)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *s3Falso) listar(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("list-type") != "2" {
+		http.Error(w, "solo ListObjectsV2", http.StatusBadRequest)
+		return
+	}
+	claves := make([]string, 0, len(f.objetos))
+	for clave := range f.objetos {
+		claves = append(claves, clave)
+	}
+	sort.Strings(claves)
+
+	inicio := 0
+	if token := r.URL.Query().Get("continuation-token"); token != "" {
+		inicio, _ = strconv.Atoi(strings.TrimPrefix(token, "pagina/"))
+	}
+	fin := min(inicio+f.porPagina, len(claves))
+
+	type contenido struct {
+		Key          string
+		LastModified string
+	}
+	resultado := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Contents              []contenido
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+	}{IsTruncated: fin < len(claves)}
+	for _, clave := range claves[inicio:fin] {
+		resultado.Contents = append(resultado.Contents, contenido{Key: clave, LastModified: "2024-01-31T12:00:00.000Z"})
+	}
+	if resultado.IsTruncated {
+		resultado.NextContinuationToken = fmt.Sprintf("pagina/%d", fin) // Con "/" para probar su codificación
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(resultado)
+}
+
+// verificarSigV4 valida la firma del header Authorization o, en una URL prefirmada, la del query string.
+func verificarSigV4(r *http.Request, cuerpo []byte, ahora time.Time) error {
+	consulta := r.URL.Query()
+	var credencial, firmados, firma, amzFecha, hashCuerpo string
+
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		resto, ok := strings.CutPrefix(auth, "AWS4-HMAC-SHA256 ")
+		if !ok {
+			return errors.New("algoritmo no soportado")
+		}
+		for _, parte := range strings.Split(resto, ", ") {
+			nombre, valor, _ := strings.Cut(parte, "=")
+			switch nombre {
+			case "Credential":
+				credencial = valor
+			case "SignedHeaders":
+				firmados = valor
+			case "Signature":
+				firma = valor
+			}
+		}
+		amzFecha = r.Header.Get("x-amz-date")
+		hashCuerpo = r.Header.Get("x-amz-content-sha256")
+		if suma := sha256.Sum256(cuerpo); hashCuerpo != hex.EncodeToString(suma[:]) {
+			return errors.New("x-amz-content-sha256 no coincide con el cuerpo")
+		}
+	} else {
+		if consulta.Get("X-Amz-Algorithm") != "AWS4-HMAC-SHA256" {
+			return errors.New("request sin firma")
+		}
+		credencial = consulta.Get("X-Amz-Credential")
+		firmados = consulta.Get("X-Amz-SignedHeaders")
+		firma = consulta.Get("X-Amz-Signature")
+		amzFecha = consulta.Get("X-Amz-Date")
+		hashCuerpo = "UNSIGNED-PAYLOAD"
+
+		desde, err := time.Parse("20060102T150405Z", amzFecha)
+		if err != nil {
+			return fmt.Errorf("X-Amz-Date inválida: %w", err)
+		}
+		segundos, err := strconv.Atoi(consulta.Get("X-Amz-Expires"))
+		if err != nil || segundos <= 0 || segundos > 7*24*3600 {
+			return errors.New("X-Amz-Expires inválido")
+		}
+		if ahora.Before(desde) || ahora.After(desde.Add(time.Duration(segundos)*time.Second)) {
+			return errors.New("URL prefirmada fuera de su vigencia")
+		}
+		consulta.Del("X-Amz-Signature")
+	}
+
+	partes := strings.Split(credencial, "/")
+	if len(partes) != 5 || partes[0] != accessKeyPrueba || partes[2] != regionPrueba || partes[3] != "s3" || partes[4] != "aws4_request" {
+		return fmt.Errorf("credencial inválida: %q", credencial)
+	}
+	if !strings.HasPrefix(amzFecha, partes[1]) {
+		return errors.New("la fecha del alcance no coincide con la del request")
+	}
+
+	var headers strings.Builder
+	for _, nombre := range strings.Split(firmados, ";") {
+		valor := r.Header.Get(nombre)
+		if nombre == "host" {
+			valor = r.Host
+		}
+		headers.WriteString(nombre + ":" + strings.TrimSpace(valor) + "\n")
+	}
+
+	nombres := make([]string, 0, len(consulta))
+	for nombre := range consulta {
+		nombres = append(nombres, nombre)
+	}
+	sort.Strings(nombres)
+	var pares []string
+	for _, nombre := range nombres {
+		for _, valor := range consulta[nombre] {
+			pares = append(pares, escaparSigV4(nombre)+"="+escaparSigV4(valor))
+		}
+	}
+
+	requestCanonico := strings.Join([]string{
+		r.Method, r.URL.EscapedPath(), strings.Join(pares, "&"), headers.String(), firmados, hashCuerpo,
+	}, "\n")
+	sumaRequest := sha256.Sum256([]byte(requestCanonico))
+	textoAFirmar := strings.Join([]string{
+		"AWS4-HMAC-SHA256", amzFecha, strings.Join(partes[1:], "/"), hex.EncodeToString(sumaRequest[:]),
+	}, "\n")
+
+	clave := []byte("AWS4" + secretKeyPrueba)
+	for _, dato := range []string{partes[1], regionPrueba, "s3", "aws4_request"} {
+		clave = hmacPrueba(clave, dato)
+	}
+	if esperada := hex.EncodeToString(hmacPrueba(clave, textoAFirmar)); !hmac.Equal([]byte(esperada), []byte(firma)) {
+		return errors.New("firma incorrecta")
+	}
+	return nil
+}
+
+// escaparSigV4 codifica como exige SigV4: todo salvo A-Z a-z 0-9 - _ . ~ (url.QueryEscape usa "+" para el espacio).
+func escaparSigV4(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacPrueba(clave []byte, dato string) []byte {
+	h := hmac.New(sha256.New, clave)
+	h.Write([]byte(dato))
+	return h.Sum(nil)
+}
+
+func nuevoS3Prueba(t *testing.T, endpoint, urlPublica string) *S3 {
+	s, err := NuevoS3(S3Config{
+		Endpoint:   endpoint,
+		Region:     regionPrueba,
+		Bucket:     bucketPrueba,
+		AccessKey:  accessKeyPrueba,
+		SecretKey:  secretKeyPrueba,
+		URLPublica: urlPublica,
+	})
+	if err != nil {
+		t.Fatalf("NuevoS3: %v", err)
+	}
+	return s
+}
+
+func TestS3IdaYVuelta(t *testing.T) {
+	falso, srv := nuevoS3Falso(t)
+	s := nuevoS3Prueba(t, srv.URL, "")
+	ctx := context.Background()
+
+	archivos := map[string]string{
+		"12_1700000000.jpg":     "original",
+		"12_1700000000_300.jpg": "miniatura",
+		"otros/con espacio.png": "anidado",
+	}
+	for clave, contenido := range archivos {
+		if err := s.Put(ctx, clave, strings.NewReader(contenido), "image/jpeg"); err != nil {
+			t.Fatalf("Put(%q): %v", clave, err)
+		}
+	}
+	for clave := range archivos {
+		if _, ok := falso.objetos[clave]; !ok {
+			t.Errorf("la clave %q no quedó tal cual en el bucket, claves: %v", clave, falso.solicitudes)
+		}
+	}
+
+	for clave, contenido := range archivos {
+		r, err := s.Get(ctx, clave)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", clave, err)
+		}
+		leido, _ := io.ReadAll(r)
+		r.Close()
+		if string(leido) != contenido {
+			t.Errorf("Get(%q) = %q, se esperaba %q", clave, leido, contenido)
+		}
+	}
+	if _, err := s.Get(ctx, "no-existe.jpg"); !errors.Is(err, ErrNoExiste) {
+		t.Errorf("Get de una clave inexistente: %v, se esperaba ErrNoExiste", err)
+	}
+
+	// Con 2 objetos por página, List debe seguir el continuation-token
+	objetos, err := s.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(objetos) != len(archivos) {
+		t.Fatalf("List retornó %d objetos, se esperaban %d: %v", len(objetos), len(archivos), objetos)
+	}
+	for _, o := range objetos {
+		if _, ok := archivos[o.Clave]; !ok {
+			t.Errorf("List retornó una clave desconocida: %q", o.Clave)
+		}
+		if o.Modificado.IsZero() {
+			t.Errorf("List no leyó LastModified de %q", o.Clave)
+		}
+	}
+
+	if err := s.Delete(ctx, "12_1700000000.jpg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := s.Delete(ctx, "12_1700000000.jpg"); err != nil {
+		t.Errorf("Delete de una clave ya borrada: %v", err)
+	}
+	if _, err := s.Get(ctx, "12_1700000000.jpg"); !errors.Is(err, ErrNoExiste) {
+		t.Errorf("Get después de Delete: %v, se esperaba ErrNoExiste", err)
+	}
+
+	if err := s.Put(ctx, "../fuera.jpg", bytes.NewReader(nil), "image/jpeg"); err == nil {
+		t.Error("Put aceptó una clave que sale del bucket")
+	}
+}
+
+func TestS3FirmaInvalida(t *testing.T) {
+	_, srv := nuevoS3Falso(t)
+	s := nuevoS3Prueba(t, srv.URL, "")
+	s.cfg.SecretKey = "otro-secreto"
+
+	if err := s.Put(context.Background(), "12_1.jpg", strings.NewReader("x"), "image/jpeg"); err == nil {
+		t.Error("Put con un secreto incorrecto no falló")
+	}
+}
+
+func TestS3URL(t *testing.T) {
+	ttlURL = time.Hour
+
+	if got := nuevoS3Prueba(t, "http://localhost:9000", "").URL("12_1.jpg"); got != RutaImagenes+"12_1.jpg" {
+		t.Errorf("sin URLPublica URL = %q, se esperaba la ruta de la API", got)
+	}
+
+	// Con URLPublica la URL va prefirmada y el bucket la acepta sin credenciales
+	falso, srv := nuevoS3Falso(t)
+	s := nuevoS3Prueba(t, srv.URL, srv.URL)
+	falso.objetos["12_1 (2).jpg"] = []byte("portada")
+
+	u := s.URL("12_1 (2).jpg")
+	if !strings.HasPrefix(u, srv.URL+"/"+bucketPrueba+"/") {
+		t.Fatalf("URL prefirmada fuera de URLPublica: %q", u)
+	}
+	resp, err := http.Get(u)
+	if err != nil {
+		t.Fatalf("GET %s: %v", u, err)
+	}
+	defer resp.Body.Close()
+	leido, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(leido) != "portada" {
+		t.Errorf("GET de la URL prefirmada: %s %q", resp.Status, leido)
+	}
+
+	// La misma URL fuera de su vigencia ya no sirve
+	r := httptest.NewRequest(http.MethodGet, u, nil)
+	if err := verificarSigV4(r, nil, time.Now().Add(ttlURL+2*ventanaExpiracion)); err == nil {
+		t.Error("la URL prefirmada no expira")
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jgutierrez746/clase_7_gin_bun/almacenamiento"
+	"github.com/jgutierrez746/clase_7_gin_bun/config"
+	"github.com/jgutierrez746/clase_7_gin_bun/db"
+	"github.com/jgutierrez746/clase_7_gin_bun/dto"
+	"github.com/jgutierrez746/clase_7_gin_bun/helpers"
+	auth "github.com/jgutierrez746/clase_7_gin_bun/jwt"
+	"github.com/jgutierrez746/clase_7_gin_bun/migraciones"
+	"github.com/jgutierrez746/clase_7_gin_bun/papelera"
+	"github.com/jgutierrez746/clase_7_gin_bun/reconciliacion"
+	"github.com/jgutierrez746/clase_7_gin_bun/semillas"
+)
+
+// idPerfilAdmin es el perfil administrador creado por create-admin, con todos los permisos.
+const idPerfilAdmin = 1
+
+// comandoMigrate ejecuta "migrate up|down|status".
+func comandoMigrate(args []string) {
+	if len(args) != 1 {
+		log.Fatal("Uso: migrate up|down|status")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	switch args[0] {
+	case "up":
+		aplicadas, err := migraciones.Up(ctx)
+		if err != nil {
+			log.Fatal("Error aplicando migraciones: ", err)
+		}
+		fmt.Printf("Migraciones aplicadas: %d\n", aplicadas)
+	case "down":
+		revertida, err := migraciones.Down(ctx)
+		if err != nil {
+			log.Fatal("Error revirtiendo migración: ", err)
+		}
+		if !revertida {
+			fmt.Println("No hay migraciones aplicadas para revertir")
+			return
+		}
+		fmt.Println("Última migración revertida")
+	case "status":
+		estados, err := migraciones.Status(ctx)
+		if err != nil {
+			log.Fatal("Error consultando migraciones: ", err)
+		}
+		for _, e := range estados {
+			estado := "pendiente"
+			if e.Aplicada {
+				estado = "aplicada " + e.AppliedAt.Format(time.DateTime)
+			}
+			fmt.Printf("%04d_%-30s %s\n", e.Version, e.Nombre, estado)
+		}
+	default:
+		log.Fatal("Uso: migrate up|down|status")
+	}
+}
+
+// comandoSeed carga datos de ejemplo.
+func comandoSeed() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := semillas.Ejecutar(ctx); err != nil {
+		log.Fatal("Error cargando datos de ejemplo: ", err)
+	}
+	fmt.Println("Datos de ejemplo cargados")
+}
+
+// comandoCreateAdmin crea un usuario con perfil administrador, creando el perfil si no existe.
+// Permite habilitar el primer admin en una base de datos nueva, ya que /usuarios requiere ser admin.
+func comandoCreateAdmin(args []string) {
+	fs := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	correo := fs.String("correo", "", "Correo del administrador (requerido)")
+	password := fs.String("password", "", "Contraseña, mínimo 6 caracteres (requerido)")
+	nombre := fs.String("nombre", "Administrador", "Nombre del administrador")
+	telefono := fs.String("telefono", "", "Teléfono del administrador")
+	fs.Parse(args)
+
+	if *correo == "" || len(*password) < 6 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Asegurar que exista el perfil administrador
+	var perfil dto.PerfilesSelectDTO
+	if err := db.SelectOne(ctx, config.Tablas["p"], &perfil, "id = ?", idPerfilAdmin); err != nil {
+		if err != sql.ErrNoRows {
+			log.Fatal("Error verificando perfil: ", err)
+		}
+		nuevoPerfil := dto.PerfilesInsert{ID: idPerfilAdmin, Nombre: "Administrador"}
+		if err := db.Insert(ctx, config.Tablas["p"], &nuevoPerfil); err != nil {
+			log.Fatal("Error creando perfil administrador: ", err)
+		}
+	}
+
+	// El perfil administrador tiene todos los permisos del catálogo
+	codigos := make([]string, 0, len(config.Permisos))
+	for codigo := range config.Permisos {
+		codigos = append(codigos, codigo)
+	}
+	if _, desconocidos, err := auth.OtorgarPermisos(ctx, db.DB, idPerfilAdmin, codigos); err != nil {
+		log.Fatal("Error otorgando permisos al perfil administrador: ", err)
+	} else if len(desconocidos) > 0 {
+		log.Fatalf("Permisos sin registrar en la tabla %s, ejecutar \"migrate up\": %v", config.Tablas["pm"], desconocidos)
+	}
+
+	// Evitar duplicar el correo
+	var existente dto.PerfilUsuarioToken
+	if err := db.SelectOne(ctx, config.Tablas["u"], &existente, "correo = ?", *correo); err == nil {
+		log.Fatalf("Ya existe un usuario con correo %s (id %d)", *correo, existente.ID)
+	} else if err != sql.ErrNoRows {
+		log.Fatal("Error verificando usuario: ", err)
+	}
+
+	hashedPassword, err := helpers.HashPassword(*password)
+	if err != nil {
+		log.Fatal("Error procesando contraseña: ", err)
+	}
+
+	usuario := dto.UsuarioInsert{
+		Nombre:   *nombre,
+		Correo:   *correo,
+		Telefono: *telefono,
+		Password: hashedPassword,
+		PerfilID: idPerfilAdmin,
+	}
+	if err := db.Insert(ctx, config.Tablas["u"], &usuario); err != nil {
+		log.Fatal("Error creando usuario: ", err)
+	}
+
+	fmt.Printf("Administrador creado: %s (id %d)\n", usuario.Correo, usuario.ID)
+}
+
+// comandoReconcile compara el almacenamiento de portadas con la tabla portada_pelicula y reporta las diferencias.
+// Sin --fix sale con código 1 si encuentra inconsistencias, para poder usarlo en un cron o healthcheck.
+func comandoReconcile(args []string) {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	corregir := fs.Bool("fix", false, "Borra archivos huérfanos y filas inconsistentes")
+	antiguedad := fs.Duration("min-age", time.Hour, "Ignora archivos y filas más nuevos que esto (subidas en curso)")
+	fs.Parse(args)
+
+	if err := almacenamiento.Cargar(); err != nil {
+		log.Fatal("Error configurando almacenamiento: ", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	reporte, err := reconciliacion.Revisar(ctx, reconciliacion.Opciones{Corregir: *corregir, AntiguedadMinima: *antiguedad})
+	if err != nil {
+		log.Fatal("Error reconciliando portadas: ", err)
+	}
+
+	fmt.Printf("Archivos revisados: %d, filas revisadas: %d\n", reporte.ArchivosRevisados, reporte.FilasRevisadas)
+	fmt.Printf("Archivos huérfanos: %d\n", len(reporte.ArchivosHuerfanos))
+	for _, clave := range reporte.ArchivosHuerfanos {
+		fmt.Printf("  %s\n", clave)
+	}
+	fmt.Printf("Filas sin archivo: %d\n", len(reporte.FilasSinArchivo))
+	for _, f := range reporte.FilasSinArchivo {
+		fmt.Printf("  id %d, película %d: %s\n", f.ID, f.PID, f.NombreArchivo)
+	}
+	fmt.Printf("Filas sin película: %d\n", len(reporte.FilasSinPelicula))
+	for _, f := range reporte.FilasSinPelicula {
+		fmt.Printf("  id %d, película %d: %s\n", f.ID, f.PID, f.NombreArchivo)
+	}
+
+	switch {
+	case reporte.Corregido:
+		fmt.Println("Inconsistencias corregidas")
+	case reporte.Inconsistencias() > 0:
+		fmt.Println("Ejecutar con --fix para corregir")
+		os.Exit(1)
+	}
+}
+
+// comandoPurge borra definitivamente lo que lleva en la papelera más que la retención, como la purga periódica.
+func comandoPurge(args []string) {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	retencion := fs.Duration("retention", duracionEntorno("PAPELERA_RETENCION", 30*24*time.Hour), "Purga lo eliminado hace más que esto")
+	fs.Parse(args)
+
+	if err := almacenamiento.Cargar(); err != nil {
+		log.Fatal("Error configurando almacenamiento: ", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	reporte, err := papelera.Purgar(ctx, *retencion)
+	if err != nil {
+		log.Fatal("Error purgando la papelera: ", err)
+	}
+
+	fmt.Printf("Películas purgadas: %d (%d archivos de portada)\n", reporte.Peliculas, reporte.Archivos)
+	fmt.Printf("Temáticas purgadas: %d\n", reporte.Tematicas)
+	fmt.Printf("Usuarios purgados: %d\n", reporte.Usuarios)
+}
@@ -0,0 +1,20 @@
+package config
+
+// Permisos es el catálogo de permisos que se pueden otorgar a un perfil (codigo -> descripción).
+// El formato es "<recurso>:<acción>"; para agregar uno nuevo se agrega aquí y en una migración.
+var Permisos = map[string]string{
+	"peliculas:read":   "Consultar películas, sus temáticas y portadas",
+	"peliculas:write":  "Crear y editar películas, sus temáticas y portadas",
+	"peliculas:delete": "Eliminar películas, sus temáticas y portadas",
+	"tematicas:read":   "Consultar temáticas",
+	"tematicas:write":  "Crear y editar temáticas",
+	"tematicas:delete": "Eliminar temáticas",
+	"perfiles:read":    "Consultar perfiles y sus permisos",
+	"perfiles:write":   "Crear y editar perfiles",
+	"perfiles:delete":  "Eliminar perfiles",
+	"usuarios:read":    "Consultar usuarios",
+	"usuarios:write":   "Crear y editar usuarios",
+	"usuarios:delete":  "Eliminar usuarios",
+	"permisos:write":   "Otorgar y revocar permisos a perfiles",
+	"auditoria:read":   "Consultar el registro de auditoría",
+}
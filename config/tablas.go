@@ -1,10 +1,17 @@
 package config
 
 var Tablas = map[string]string{
-	"tm": "tematicas",
-	"pl": "peliculas",
-	"pt": "pelicula_tematicas",
-	"pp": "portada_pelicula",
-	"p":  "perfiles",
-	"u":  "usuarios",
+	"tm":  "tematicas",
+	"pl":  "peliculas",
+	"pt":  "pelicula_tematicas",
+	"pp":  "portada_pelicula",
+	"p":   "perfiles",
+	"u":   "usuarios",
+	"sm":  "schema_migrations",
+	"rt":  "refresh_tokens",
+	"tr":  "tokens_revocados",
+	"pm":  "permisos",
+	"pfp": "perfil_permisos",
+	"sp":  "slugs_pelicula",
+	"au":  "auditoria",
 }
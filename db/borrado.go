@@ -0,0 +1,36 @@
+package db
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+// tablasBorradoLogico son las tablas con columna deleted_at (ver RegistrarBorradoLogico).
+var tablasBorradoLogico = map[string]bool{}
+
+type claveIncluirBorrados struct{}
+
+// RegistrarBorradoLogico indica las tablas con borrado lógico (columna deleted_at). Las consultas de este paquete
+// sobre ellas excluyen las filas borradas, salvo con un ctx de IncluirBorrados. Se llama una vez al iniciar.
+func RegistrarBorradoLogico(tablas ...string) {
+	for _, t := range tablas {
+		tablasBorradoLogico[t] = true
+	}
+}
+
+// IncluirBorrados retorna un ctx con el que las consultas también entregan las filas borradas, ej: la papelera.
+func IncluirBorrados(ctx context.Context) context.Context {
+	return context.WithValue(ctx, claveIncluirBorrados{}, true)
+}
+
+// filtrarBorrados agrega "<table>.deleted_at IS NULL" si la tabla tiene borrado lógico y ctx no pide incluirlos.
+func filtrarBorrados(ctx context.Context, q *bun.SelectQuery, table string) *bun.SelectQuery {
+	if !tablasBorradoLogico[table] {
+		return q
+	}
+	if incluir, _ := ctx.Value(claveIncluirBorrados{}).(bool); incluir {
+		return q
+	}
+	return q.Where(table + ".deleted_at IS NULL")
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// BuscarFullText realiza una búsqueda FULLTEXT (MATCH ... AGAINST en modo BOOLEAN) paginada.
+// Agrega la columna calculada "relevancia" y ordena por ella de mayor a menor.
+// - columnas: columnas del índice FULLTEXT, deben coincidir exactamente con las del índice.
+// - consulta: expresión booleana ya normalizada, ej: "+pelicula* +terror*".
+// Retorna el total de filas que coinciden, sin considerar LIMIT/OFFSET.
+func BuscarFullText(ctx context.Context, table string, columnas []string, consulta string, dest interface{}, p Paginacion) (int, error) {
+	if DB == nil {
+		return 0, fmt.Errorf("DB no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
+	}
+
+	match := fmt.Sprintf("MATCH(%s) AGAINST (? IN BOOLEAN MODE)", strings.Join(columnas, ", "))
+
+	q := filtrarBorrados(ctx, seleccionarColumnas(DB.NewSelect().Table(table), table, dest, "relevancia"), table).
+		ColumnExpr(match+" AS relevancia", consulta).
+		Where(match, consulta)
+
+	p.Orden = append([]string{"relevancia DESC"}, p.Orden...)
+	q = aplicarPaginacion(q, p)
+	return q.ScanAndCount(ctx, dest)
+}
+
+// AgregarFullText agrega un índice FULLTEXT a una tabla. Si el índice ya existe no hace nada.
+// - indexName: nombre del índice, ej: "ft_peliculas_busqueda"
+// - cols: columnas indexadas
+func AgregarFullText(ctx context.Context, tableName, indexName string, cols ...string) error {
+	if DB == nil {
+		return fmt.Errorf("DB no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
+	}
+
+	existe, err := ExisteIndice(ctx, tableName, indexName)
+	if err != nil {
+		return err
+	}
+	if existe {
+		log.Printf("Índice %s ya existe, se omite.", indexName)
+		return nil
+	}
+
+	sql := fmt.Sprintf("ALTER TABLE %s ADD FULLTEXT INDEX %s (%s);", tableName, indexName, strings.Join(cols, ", "))
+
+	if _, err := DB.ExecContext(ctx, sql); err != nil {
+		return fmt.Errorf("error agregando índice FULLTEXT %s en %s: %w", indexName, tableName, err)
+	}
+
+	log.Printf("Índice FULLTEXT agregado: %s(%s)", tableName, strings.Join(cols, ", "))
+	return nil
+}
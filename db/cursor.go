@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Cursor identifica la última fila entregada en una paginación por keyset.
+// Con PorFecha el keyset es (created_at, id), si no solo id.
+type Cursor struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at,omitempty"`
+	PorFecha    bool      `json:"por_fecha,omitempty"`
+	Descendente bool      `json:"desc,omitempty"`
+}
+
+// PaginacionCursor agrupa los parámetros de una consulta paginada por keyset.
+// A diferencia de OFFSET, el costo no crece con la profundidad de la página y las filas
+// insertadas durante la iteración no desplazan a las ya entregadas.
+type PaginacionCursor struct {
+	Despues     *Cursor           // nil para la primera página
+	Limite      int               // Cantidad de filas por página
+	PorFecha    bool              // Ordena por (created_at, id) en vez de solo id
+	Descendente bool              // Sentido del orden
+	Prefijo     string            // Prefijo de las columnas del keyset cuando hay JOINs, ej: "usuarios."
+	Filtros     map[string]string // Columna -> valor, se aplican como igualdad
+}
+
+// aplicarCursor agrega los filtros, la condición del keyset, el ORDER BY y el LIMIT a la consulta.
+// Se pide una fila extra para saber si existe una página siguiente.
+func aplicarCursor(q *bun.SelectQuery, p PaginacionCursor) *bun.SelectQuery {
+	q = aplicarPaginacion(q, Paginacion{Filtros: p.Filtros})
+
+	colID := p.Prefijo + "id"
+	colFecha := p.Prefijo + "created_at"
+
+	comparador := ">"
+	if p.Descendente {
+		comparador = "<"
+	}
+
+	if p.Despues != nil {
+		if p.PorFecha {
+			q = q.Where(fmt.Sprintf("(%s, %s) %s (?, ?)", colFecha, colID, comparador), p.Despues.CreatedAt, p.Despues.ID)
+		} else {
+			q = q.Where(fmt.Sprintf("%s %s ?", colID, comparador), p.Despues.ID)
+		}
+	}
+
+	for _, orden := range p.Orden() {
+		q = q.OrderExpr(orden)
+	}
+	return q.Limit(p.Limite + 1)
+}
+
+// Orden retorna las expresiones ORDER BY del keyset, ej: ["created_at DESC", "id DESC"].
+func (p PaginacionCursor) Orden() []string {
+	direccion := "ASC"
+	if p.Descendente {
+		direccion = "DESC"
+	}
+	if p.PorFecha {
+		return []string{p.Prefijo + "created_at " + direccion, p.Prefijo + "id " + direccion}
+	}
+	return []string{p.Prefijo + "id " + direccion}
+}
+
+// SelectCursor realiza un SELECT paginado por keyset sobre una tabla.
+// Retorna el cursor de la página siguiente, o nil si no hay más filas.
+// El struct de dest debe tener los campos ID y, si se ordena por fecha, CreatedAt.
+// Ej: var pelis []Pelicula; next, err := SelectCursor(ctx, "peliculas", &pelis, db.PaginacionCursor{Limite: 100})
+func SelectCursor(ctx context.Context, table string, dest interface{}, p PaginacionCursor) (*Cursor, error) {
+	if DB == nil {
+		return nil, fmt.Errorf("DB no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
+	}
+
+	q := seleccionarColumnas(DB.NewSelect().Table(table), table, dest)
+	q = aplicarCursor(filtrarBorrados(ctx, q, table), p)
+	if err := q.Scan(ctx, dest); err != nil {
+		return nil, err
+	}
+	return siguienteCursor(dest, p)
+}
+
+// siguienteCursor recorta la fila extra pedida por aplicarCursor y arma el cursor desde la última fila entregada.
+func siguienteCursor(dest interface{}, p PaginacionCursor) (*Cursor, error) {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return nil, fmt.Errorf("dest debe ser un puntero a slice")
+	}
+	filas := rv.Elem()
+	if filas.Len() <= p.Limite {
+		return nil, nil // No hay más páginas
+	}
+	filas.Set(filas.Slice(0, p.Limite))
+
+	ultima := reflect.Indirect(filas.Index(p.Limite - 1))
+	campoID := ultima.FieldByName("ID")
+	if !campoID.IsValid() || !campoID.CanInt() {
+		return nil, fmt.Errorf("el modelo no tiene un campo ID entero")
+	}
+
+	cursor := &Cursor{
+		ID:          campoID.Int(),
+		PorFecha:    p.PorFecha,
+		Descendente: p.Descendente,
+	}
+	if p.PorFecha {
+		campo := ultima.FieldByName("CreatedAt")
+		if !campo.IsValid() {
+			return nil, fmt.Errorf("el modelo no tiene un campo CreatedAt")
+		}
+		campoFecha, ok := campo.Interface().(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("el modelo no tiene un campo CreatedAt de tipo time.Time")
+		}
+		cursor.CreatedAt = campoFecha
+	}
+	return cursor, nil
+}
@@ -3,9 +3,11 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"reflect"
+	"slices"
 	"strings"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -38,14 +40,46 @@ func InitDB(dsn string) error {
 	return nil
 }
 
+// CerrarDB cierra las conexiones del pool. Se llama al apagar el servidor, después de que terminaron las solicitudes.
+func CerrarDB() error {
+	if DB == nil {
+		return fmt.Errorf("DB no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
+	}
+
+	return DB.Close()
+}
+
+// seleccionarColumnas selecciona las columnas que mapea dest (*T, *[]T o *[]*T de un struct), calificadas con table,
+// en vez de SELECT *: una columna nueva en la tabla no rompe los DTOs que no la usan, y un campo del DTO que no existe
+// en la tabla falla en la consulta. excluir son campos que la consulta calcula aparte (ej: relevancia).
+// Si dest no es un struct (ej: *[]string) la consulta no cambia.
+func seleccionarColumnas(q *bun.SelectQuery, table string, dest interface{}, excluir ...string) *bun.SelectQuery {
+	rt := reflect.TypeOf(dest)
+	for rt != nil && (rt.Kind() == reflect.Ptr || rt.Kind() == reflect.Slice) {
+		rt = rt.Elem()
+	}
+	if rt == nil || rt.Kind() != reflect.Struct {
+		return q
+	}
+
+	for _, campo := range q.Dialect().Tables().Get(rt).Fields {
+		if !slices.Contains(excluir, campo.Name) {
+			q = q.ColumnExpr("?.?", bun.Ident(table), bun.Ident(campo.Name))
+		}
+	}
+	return q
+}
+
 // SelectAll realiza un SELECT de todas las filas de una tabla y las escanea en un slice de structs.
+// En las tablas con borrado lógico excluye las filas borradas (ver RegistrarBorradoLogico), igual que los demás SELECT.
 // Ej: var users []User; err := SelectAll(ctx, "users", &users)
 func SelectAll(ctx context.Context, table string, dest interface{}) error {
 	if DB == nil {
 		return fmt.Errorf("db no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
 	}
 
-	return DB.NewSelect().Table(table).OrderExpr("id DESC").Scan(ctx, dest)
+	q := seleccionarColumnas(DB.NewSelect().Table(table), table, dest)
+	return filtrarBorrados(ctx, q, table).OrderExpr("id DESC").Scan(ctx, dest)
 }
 
 // SelectOne realiza un SELECT de una sola fila de una tabla con clausula WHERE.
@@ -55,7 +89,8 @@ func SelectOne(ctx context.Context, table string, dest interface{}, where string
 		return fmt.Errorf("DB no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
 	}
 
-	q := DB.NewSelect().Table(table).Where(where, args...)
+	q := seleccionarColumnas(DB.NewSelect().Table(table), table, dest)
+	q = filtrarBorrados(ctx, q, table).Where(where, args...)
 	return q.Scan(ctx, dest)
 }
 
@@ -66,17 +101,13 @@ func Update(ctx context.Context, table string, model interface{}, where string,
 		return 0, fmt.Errorf("DB no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
 	}
 
-	q := DB.NewUpdate().Model(model).ModelTableExpr(table).Where(where, args...)
-	res, err := q.Exec(ctx)
-	if err != nil {
-		return 0, fmt.Errorf("error actualizando: %w", err)
-	}
-
-	filasAfectadas, _ := res.RowsAffected() // Ignoramos el error
-	log.Printf("Registro actualizado en tabla %s con WHERE: %s", table, where)
-	return filasAfectadas, nil
+	return UpdateTx(ctx, DB, table, model, where, args...)
 }
 
+// ErrVersion indica que UpdateConVersionTx no actualizó nada: otra escritura cambió la versión de la fila (o la fila
+// no existe).
+var ErrVersion = errors.New("el registro fue modificado por otra operación")
+
 // Delete borra filas de una tabla con cláusula WHERE.
 // Retorna el número de filas afectadas (int64).
 // Ej: affected, err := Delete(ctx, "users", "id = ?", 1)
@@ -85,15 +116,7 @@ func Delete(ctx context.Context, table string, where string, args ...interface{}
 		return 0, fmt.Errorf("DB no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
 	}
 
-	q := DB.NewDelete().Table(table).Where(where, args...)
-	res, err := q.Exec(ctx)
-	if err != nil {
-		return 0, fmt.Errorf("error eliminando: %w", err)
-	}
-
-	filasAfectadas, _ := res.RowsAffected() // Se ignora el error
-	log.Printf("%d registos eliminados de la tabla %s con WHERE: %s", filasAfectadas, table, where)
-	return filasAfectadas, nil
+	return DeleteTx(ctx, DB, table, where, args...)
 }
 
 // SelectWithJoin realiza un SELECT con JOINs en una tabla principal, escaneando en un slice de structs.
@@ -101,21 +124,25 @@ func Delete(ctx context.Context, table string, where string, args ...interface{}
 //   - joins: []string{"LEFT JOIN orders ON orders.user_id = users.id"}
 //   - where: "users.id > ?", 10
 //
-// Nota: El modelo (dest) debe mapear todos los campos de las tablas unidas.
+// Nota: El modelo (dest) debe mapear todas las columnas seleccionadas; sin columnas se seleccionan las del modelo
+// en mainTable (ver seleccionarColumnas).
 func SelectConJoin(ctx context.Context, mainTable string, joins, columnas []string, modelo interface{}, order string, where string, args ...interface{}) error {
 	if DB == nil {
 		return fmt.Errorf("DB no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
 	}
-	q := DB.NewSelect().Table(mainTable)
+	q := filtrarBorrados(ctx, DB.NewSelect().Table(mainTable), mainTable)
 
 	// Agregar JOINs dinámicamente.
 	for _, join := range joins {
 		q = q.Join(join)
 	}
-	// Agregar columnas dinamicamente.
+	// Agregar columnas dinamicamente, sin columnas las del modelo.
 	for _, columna := range columnas {
 		q = q.ColumnExpr(columna)
 	}
+	if len(columnas) == 0 {
+		q = seleccionarColumnas(q, mainTable, modelo)
+	}
 
 	// WHERE opcional.
 	if where != "" {
@@ -130,6 +157,48 @@ func SelectConJoin(ctx context.Context, mainTable string, joins, columnas []stri
 	return q.Scan(ctx, modelo)
 }
 
+// RecorrerConJoin es como SelectConJoin pero no carga el resultado en memoria: itera el cursor de la consulta
+// y llama a fn con cada fila escaneada en un T. Si fn retorna error la iteración se detiene y se retorna ese error.
+// Ej: err := RecorrerConJoin(ctx, "users", joins, columnas, "users.id ASC", func(u User) error { ... }, "")
+func RecorrerConJoin[T any](ctx context.Context, mainTable string, joins, columnas []string, order string, fn func(T) error, where string, args ...interface{}) error {
+	if DB == nil {
+		return fmt.Errorf("DB no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
+	}
+	q := filtrarBorrados(ctx, DB.NewSelect().Table(mainTable), mainTable)
+	for _, join := range joins {
+		q = q.Join(join)
+	}
+	for _, columna := range columnas {
+		q = q.ColumnExpr(columna)
+	}
+	if len(columnas) == 0 {
+		q = seleccionarColumnas(q, mainTable, new(T))
+	}
+	if where != "" {
+		q = q.Where(where, args...)
+	}
+	if order != "" {
+		q = q.OrderExpr(order)
+	}
+
+	rows, err := q.Rows(ctx)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fila T
+		if err := DB.ScanRow(ctx, rows, &fila); err != nil {
+			return err
+		}
+		if err := fn(fila); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 // Insert inserta un modelo (struct) en la tabla (infiriendo del tag bun:table)
 // Bun maneja autoincrement (ID)
 func Insert(ctx context.Context, table string, model interface{}) error {
@@ -137,12 +206,7 @@ func Insert(ctx context.Context, table string, model interface{}) error {
 		return fmt.Errorf("DB no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
 	}
 
-	_, err := DB.NewInsert().Model(model).ModelTableExpr(table).Returning("id").Exec(ctx)
-	if err != nil {
-		return fmt.Errorf("error insertando: %w", err)
-	}
-	log.Printf("Registro insertado exitosamente en tabla inferida del modelo.")
-	return nil
+	return InsertTx(ctx, DB, table, model)
 }
 
 // InsertBatch inserta múltiples modelos en batch (más eficiente para muchos registros)
@@ -151,13 +215,38 @@ func InsertBatch[T any](ctx context.Context, table string, models []T) (int64, e
 		return 0, fmt.Errorf("DB no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
 	}
 
-	res, err := DB.NewInsert().Model(&models).ModelTableExpr(table).Exec(ctx)
+	return InsertBatchTx(ctx, DB, table, models)
+}
+
+// InsertBatchIgnorar inserta múltiples modelos omitiendo los que violan una clave única (INSERT IGNORE).
+// Útil para asociaciones idempotentes, ej: otorgar un permiso que el perfil ya tiene.
+func InsertBatchIgnorar[T any](ctx context.Context, table string, models []T) (int64, error) {
+	if DB == nil {
+		return 0, fmt.Errorf("DB no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
+	}
+
+	res, err := DB.NewInsert().Model(&models).ModelTableExpr(table).Ignore().Exec(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("error insertando batch: %w", err)
 	}
 
 	filas, _ := res.RowsAffected()
-	log.Printf("Batch de %d registros insertado exitosamente.", filas)
+	log.Printf("Batch de %d registros insertado exitosamente (%d omitidos).", filas, int64(len(models))-filas)
+	return filas, nil
+}
+
+// Ejecutar corre una sentencia SQL arbitraria (ej: INSERT ... SELECT en migraciones) y retorna las filas afectadas.
+func Ejecutar(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	if DB == nil {
+		return 0, fmt.Errorf("DB no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
+	}
+
+	res, err := DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("error ejecutando sentencia: %w", err)
+	}
+
+	filas, _ := res.RowsAffected()
 	return filas, nil
 }
 
@@ -210,6 +299,7 @@ func inferirTabla(model interface{}) string {
 }
 
 // AgregarFk agrega una Fk a una tabla, se puede llamar cuantas veces sea necesario en caso de contener más de 1 fk en la tabla
+// Si la constraint fk_<tabla>_<columna> ya existe no hace nada.
 // - tableName: Tabla donde agregar la Fk
 // - fkCol: Columna en tableName que será la FK
 // - refTable: Tabla referenciada
@@ -227,6 +317,17 @@ func AgregarFK(ctx context.Context, tableName, fkCol, refTable, refCol, onDelete
 		onDelete = "CASCADE"
 	}
 
+	// Idempotente: si la constraint ya existe no se vuelve a crear
+	nombreFK := fmt.Sprintf("fk_%s_%s", tableName, fkCol)
+	existe, err := ExisteFK(ctx, tableName, nombreFK)
+	if err != nil {
+		return err
+	}
+	if existe {
+		log.Printf("FK %s ya existe, se omite.", nombreFK)
+		return nil
+	}
+
 	// SQL dinámico para ALTER
 	sql := fmt.Sprintf(`
 		ALTER TABLE %s
@@ -234,7 +335,7 @@ func AgregarFK(ctx context.Context, tableName, fkCol, refTable, refCol, onDelete
 		FOREIGN KEY (%s) REFERENCES %s(%s) ON DELETE %s;
 	`, tableName, tableName, fkCol, fkCol, refTable, refCol, onDelete)
 
-	_, err := DB.ExecContext(ctx, sql)
+	_, err = DB.ExecContext(ctx, sql)
 	if err != nil {
 		return fmt.Errorf("error agregando FK %s - > %s.%s: %w", fkCol, refTable, refCol, err)
 	}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Códigos de error de MySQL usados por la API.
+const (
+	errDuplicado            = 1062 // ER_DUP_ENTRY
+	errFilaReferenciada     = 1451 // ER_ROW_IS_REFERENCED_2: borrar/actualizar un padre con hijos
+	errReferenciaInvalida   = 1452 // ER_NO_REFERENCED_ROW_2: insertar/actualizar un hijo con un padre que no existe
+	errTiempoBloqueo        = 1205 // ER_LOCK_WAIT_TIMEOUT
+	errDeadlock             = 1213 // ER_LOCK_DEADLOCK
+	errTiempoConsulta       = 3024 // ER_QUERY_TIMEOUT (max_execution_time)
+	errDemasiadasConexiones = 1040 // ER_CON_COUNT_ERROR
+)
+
+// Códigos de ErrorBD, se entregan al cliente en el campo "codigo".
+const (
+	CodigoDuplicado          = "duplicado"
+	CodigoReferenciado       = "referenciado"
+	CodigoReferenciaInvalida = "referencia_invalida"
+	CodigoNoDisponible       = "no_disponible"
+	CodigoErrorInterno       = "error_interno"
+)
+
+// ErrorBD es un error de la base de datos clasificado para responder al cliente sin exponer el SQL ni el texto
+// del driver. El error original se conserva (Unwrap) para el log.
+type ErrorBD struct {
+	Codigo  string // Uno de los Codigo*
+	Estado  int    // Status HTTP que corresponde
+	Mensaje string // Texto seguro para el cliente, vacío en errores internos
+	Err     error
+}
+
+func (e *ErrorBD) Error() string { return e.Err.Error() }
+func (e *ErrorBD) Unwrap() error { return e.Err }
+
+// Ej: "Duplicate entry 'dracula' for key 'peliculas.slug'" (MySQL 8) o "... for key 'slug'" (5.7)
+var reClaveDuplicada = regexp.MustCompile("for key '([^']+)'")
+
+// Ej: "... a foreign key constraint fails (`cine`.`usuarios`, CONSTRAINT `fk_usuarios_perfil_id` FOREIGN KEY (`perfil_id`) REFERENCES `perfiles` (`id`))"
+var reFK = regexp.MustCompile("`([^`]+)`, CONSTRAINT `[^`]+` FOREIGN KEY \\(`([^`]+)`\\) REFERENCES `([^`]+)`")
+
+// TraducirError clasifica err: violaciones de índices únicos (409), de FKs (409 al borrar un registro en uso,
+// 422 al referenciar uno que no existe) y bloqueos, timeouts o conexiones caídas (503). El resto es 500.
+func TraducirError(err error) *ErrorBD {
+	var errBD *ErrorBD
+	if errors.As(err, &errBD) {
+		return errBD
+	}
+
+	var errMySQL *mysql.MySQLError
+	if errors.As(err, &errMySQL) {
+		switch errMySQL.Number {
+		case errDuplicado:
+			mensaje := "ya existe un registro con los mismos datos"
+			if m := reClaveDuplicada.FindStringSubmatch(errMySQL.Message); m != nil {
+				clave := m[1][strings.LastIndex(m[1], ".")+1:] // Sin el prefijo de la tabla
+				mensaje = fmt.Sprintf("ya existe un registro con el mismo valor de %s", clave)
+			}
+			return &ErrorBD{Codigo: CodigoDuplicado, Estado: http.StatusConflict, Mensaje: mensaje, Err: err}
+		case errFilaReferenciada:
+			mensaje := "el registro está en uso por otros registros"
+			if m := reFK.FindStringSubmatch(errMySQL.Message); m != nil {
+				mensaje = fmt.Sprintf("el registro está en uso en %s", m[1])
+			}
+			return &ErrorBD{Codigo: CodigoReferenciado, Estado: http.StatusConflict, Mensaje: mensaje, Err: err}
+		case errReferenciaInvalida:
+			mensaje := "se hace referencia a un registro que no existe"
+			if m := reFK.FindStringSubmatch(errMySQL.Message); m != nil {
+				mensaje = fmt.Sprintf("%s no corresponde a un registro existente de %s", m[2], m[3])
+			}
+			return &ErrorBD{Codigo: CodigoReferenciaInvalida, Estado: http.StatusUnprocessableEntity, Mensaje: mensaje, Err: err}
+		case errTiempoBloqueo, errDeadlock, errTiempoConsulta, errDemasiadasConexiones:
+			return noDisponible(err)
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return noDisponible(err)
+	}
+
+	return &ErrorBD{Codigo: CodigoErrorInterno, Estado: http.StatusInternalServerError, Err: err}
+}
+
+func noDisponible(err error) *ErrorBD {
+	return &ErrorBD{
+		Codigo:  CodigoNoDisponible,
+		Estado:  http.StatusServiceUnavailable,
+		Mensaje: "la base de datos no está disponible en este momento, reintentar",
+		Err:     err,
+	}
+}
+
+// EsDuplicado indica si err es una violación de un índice único (ej: dos INSERT concurrentes con el mismo slug).
+func EsDuplicado(err error) bool {
+	return TraducirError(err).Codigo == CodigoDuplicado
+}
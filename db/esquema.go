@@ -0,0 +1,163 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Consultas a information_schema usadas para que los cambios de esquema sean idempotentes.
+
+// existeEnEsquema cuenta filas de information_schema.<vista> para la base de datos actual.
+func existeEnEsquema(ctx context.Context, vista, where string, args ...interface{}) (bool, error) {
+	if DB == nil {
+		return false, fmt.Errorf("DB no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
+	}
+
+	n, err := DB.NewSelect().
+		TableExpr("information_schema."+vista).
+		Where("table_schema = DATABASE()").
+		Where(where, args...).
+		Count(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error consultando information_schema.%s: %w", vista, err)
+	}
+	return n > 0, nil
+}
+
+// ExisteTabla indica si la tabla existe en la base de datos actual.
+func ExisteTabla(ctx context.Context, tableName string) (bool, error) {
+	return existeEnEsquema(ctx, "tables", "table_name = ?", tableName)
+}
+
+// ExisteColumna indica si la columna existe en la tabla.
+func ExisteColumna(ctx context.Context, tableName, columna string) (bool, error) {
+	return existeEnEsquema(ctx, "columns", "table_name = ? AND column_name = ?", tableName, columna)
+}
+
+// ExisteIndice indica si el índice existe en la tabla.
+func ExisteIndice(ctx context.Context, tableName, indice string) (bool, error) {
+	return existeEnEsquema(ctx, "statistics", "table_name = ? AND index_name = ?", tableName, indice)
+}
+
+// ExisteFK indica si la constraint de FK existe en la tabla.
+func ExisteFK(ctx context.Context, tableName, nombreFK string) (bool, error) {
+	return existeEnEsquema(ctx, "table_constraints", "table_name = ? AND constraint_name = ? AND constraint_type = 'FOREIGN KEY'", tableName, nombreFK)
+}
+
+// AgregarColumna agrega una columna si no existe.
+// - definicion: tipo y modificadores SQL, ej: "TIMESTAMP NULL DEFAULT NULL"
+func AgregarColumna(ctx context.Context, tableName, columna, definicion string) error {
+	existe, err := ExisteColumna(ctx, tableName, columna)
+	if err != nil {
+		return err
+	}
+	if existe {
+		log.Printf("Columna %s.%s ya existe, se omite.", tableName, columna)
+		return nil
+	}
+
+	sql := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", tableName, columna, definicion)
+	if _, err := DB.ExecContext(ctx, sql); err != nil {
+		return fmt.Errorf("error agregando columna %s.%s: %w", tableName, columna, err)
+	}
+
+	log.Printf("Columna agregada: %s.%s %s", tableName, columna, definicion)
+	return nil
+}
+
+// EliminarColumna elimina una columna si existe.
+func EliminarColumna(ctx context.Context, tableName, columna string) error {
+	existe, err := ExisteColumna(ctx, tableName, columna)
+	if err != nil {
+		return err
+	}
+	if !existe {
+		return nil
+	}
+
+	sql := fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", tableName, columna)
+	if _, err := DB.ExecContext(ctx, sql); err != nil {
+		return fmt.Errorf("error eliminando columna %s.%s: %w", tableName, columna, err)
+	}
+
+	log.Printf("Columna eliminada: %s.%s", tableName, columna)
+	return nil
+}
+
+// EliminarFK elimina una constraint de FK si existe.
+func EliminarFK(ctx context.Context, tableName, nombreFK string) error {
+	existe, err := ExisteFK(ctx, tableName, nombreFK)
+	if err != nil {
+		return err
+	}
+	if !existe {
+		return nil
+	}
+
+	sql := fmt.Sprintf("ALTER TABLE %s DROP FOREIGN KEY %s;", tableName, nombreFK)
+	if _, err := DB.ExecContext(ctx, sql); err != nil {
+		return fmt.Errorf("error eliminando FK %s: %w", nombreFK, err)
+	}
+
+	log.Printf("FK eliminada: %s.%s", tableName, nombreFK)
+	return nil
+}
+
+// AgregarIndice agrega un índice (no único) sobre las columnas indicadas si no existe.
+func AgregarIndice(ctx context.Context, tableName, indice string, cols ...string) error {
+	existe, err := ExisteIndice(ctx, tableName, indice)
+	if err != nil {
+		return err
+	}
+	if existe {
+		log.Printf("Índice %s ya existe, se omite.", indice)
+		return nil
+	}
+
+	sql := fmt.Sprintf("ALTER TABLE %s ADD INDEX %s (%s);", tableName, indice, strings.Join(cols, ", "))
+	if _, err := DB.ExecContext(ctx, sql); err != nil {
+		return fmt.Errorf("error agregando índice %s en %s: %w", indice, tableName, err)
+	}
+
+	log.Printf("Índice agregado: %s(%s)", tableName, strings.Join(cols, ", "))
+	return nil
+}
+
+// EliminarIndice elimina un índice si existe.
+func EliminarIndice(ctx context.Context, tableName, indice string) error {
+	existe, err := ExisteIndice(ctx, tableName, indice)
+	if err != nil {
+		return err
+	}
+	if !existe {
+		return nil
+	}
+
+	sql := fmt.Sprintf("ALTER TABLE %s DROP INDEX %s;", tableName, indice)
+	if _, err := DB.ExecContext(ctx, sql); err != nil {
+		return fmt.Errorf("error eliminando índice %s: %w", indice, err)
+	}
+
+	log.Printf("Índice eliminado: %s.%s", tableName, indice)
+	return nil
+}
+
+// DropTable elimina una tabla si existe.
+func DropTable(ctx context.Context, model interface{}) error {
+	if DB == nil {
+		return fmt.Errorf("DB no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
+	}
+
+	tableName := inferirTabla(model)
+	if tableName == "" {
+		return fmt.Errorf("nombre de tabla no proporcionado mediante el tag correspondiente")
+	}
+
+	if _, err := DB.NewDropTable().Model(model).IfExists().Exec(ctx); err != nil {
+		return fmt.Errorf("error eliminando tabla %s: %w", tableName, err)
+	}
+	log.Printf("Tabla %s eliminada.", tableName)
+	return nil
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/uptrace/bun"
+)
+
+// Paginacion agrupa los parámetros de paginación, orden y filtrado de un listado.
+// Las columnas de Orden y Filtros deben venir ya validadas contra una lista blanca,
+// ya que se concatenan directamente en la consulta.
+type Paginacion struct {
+	Pagina    int               // Página solicitada (comienza en 1)
+	PorPagina int               // Cantidad de filas por página
+	Orden     []string          // Expresiones ORDER BY, ej: "titulo ASC"
+	Filtros   map[string]string // Columna -> valor, se aplican como igualdad
+}
+
+// Offset calcula el desplazamiento de filas según la página solicitada.
+func (p Paginacion) Offset() int {
+	if p.Pagina < 1 {
+		return 0
+	}
+	return (p.Pagina - 1) * p.PorPagina
+}
+
+// aplicarPaginacion agrega WHERE, ORDER BY, LIMIT y OFFSET a la consulta.
+func aplicarPaginacion(q *bun.SelectQuery, p Paginacion) *bun.SelectQuery {
+	// Se ordenan las columnas para generar siempre el mismo SQL.
+	columnas := make([]string, 0, len(p.Filtros))
+	for columna := range p.Filtros {
+		columnas = append(columnas, columna)
+	}
+	sort.Strings(columnas)
+	for _, columna := range columnas {
+		q = q.Where(columna+" = ?", p.Filtros[columna])
+	}
+
+	for _, orden := range p.Orden {
+		q = q.OrderExpr(orden)
+	}
+
+	if p.PorPagina > 0 {
+		q = q.Limit(p.PorPagina).Offset(p.Offset())
+	}
+	return q
+}
+
+// SelectPaginado realiza un SELECT paginado de una tabla y retorna el total de filas que cumplen los filtros.
+// Ej: var users []User; total, err := SelectPaginado(ctx, "users", &users, db.Paginacion{Pagina: 1, PorPagina: 20})
+func SelectPaginado(ctx context.Context, table string, dest interface{}, p Paginacion) (int, error) {
+	if DB == nil {
+		return 0, fmt.Errorf("DB no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
+	}
+
+	q := seleccionarColumnas(DB.NewSelect().Table(table), table, dest)
+	q = aplicarPaginacion(filtrarBorrados(ctx, q, table), p)
+	return q.ScanAndCount(ctx, dest)
+}
+
+// SelectConJoinPaginado es la variante paginada de SelectConJoin.
+// Retorna el total de filas que cumplen el WHERE y los filtros, sin considerar LIMIT/OFFSET.
+func SelectConJoinPaginado(ctx context.Context, mainTable string, joins, columnas []string, modelo interface{}, p Paginacion, where string, args ...interface{}) (int, error) {
+	if DB == nil {
+		return 0, fmt.Errorf("DB no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
+	}
+	q := filtrarBorrados(ctx, DB.NewSelect().Table(mainTable), mainTable)
+
+	for _, join := range joins {
+		q = q.Join(join)
+	}
+	for _, columna := range columnas {
+		q = q.ColumnExpr(columna)
+	}
+	if len(columnas) == 0 {
+		q = seleccionarColumnas(q, mainTable, modelo)
+	}
+
+	if where != "" {
+		q = q.Where(where, args...)
+	}
+
+	q = aplicarPaginacion(q, p)
+	return q.ScanAndCount(ctx, modelo)
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// SlugDisponible retorna el primer slug libre en la columna slug de la tabla, probando en orden base, las alternativas
+// y luego base-2, base-3, etc. Las filas con id excluirID (la que se está editando, o 0) no cuentan como ocupadas.
+// Ej: SlugDisponible(ctx, tx, "peliculas", "dracula", 0, "dracula-1931") -> "dracula-1931" si "dracula" ya existe.
+func SlugDisponible(ctx context.Context, idb bun.IDB, table, base string, excluirID int64, alternativas ...string) (string, error) {
+	usados := make(map[string]bool)
+	if err := SlugsOcupados(ctx, idb, table, "id", base, excluirID, usados); err != nil {
+		return "", err
+	}
+	return SlugLibre(usados, base, alternativas...), nil
+}
+
+// SlugsOcupados agrega a usados los slugs de la tabla que pueden competir con base (base y base-...), salvo los de
+// las filas cuya columnaID es excluirID. Permite juntar los slugs de varias tablas antes de llamar a SlugLibre.
+func SlugsOcupados(ctx context.Context, idb bun.IDB, table, columnaID, base string, excluirID int64, usados map[string]bool) error {
+	// Todos los candidatos empiezan con la base, así basta una consulta. Los slugs solo tienen [a-z0-9-],
+	// no hay comodines de LIKE que escapar.
+	var ocupados []string
+	err := idb.NewSelect().Table(table).Column("slug").
+		Where("(slug = ? OR slug LIKE ?) AND ? <> ?", base, base+"-%", bun.Ident(columnaID), excluirID).
+		Scan(ctx, &ocupados)
+	if err != nil {
+		return fmt.Errorf("error consultando slugs de %s: %w", table, err)
+	}
+
+	for _, s := range ocupados {
+		usados[s] = true
+	}
+	return nil
+}
+
+// SlugLibre retorna el primer candidato que no está en usados: base, las alternativas y luego base-2, base-3, etc.
+func SlugLibre(usados map[string]bool, base string, alternativas ...string) string {
+	for _, candidato := range append([]string{base}, alternativas...) {
+		if !usados[candidato] {
+			return candidato
+		}
+	}
+	for i := 2; ; i++ {
+		if candidato := fmt.Sprintf("%s-%d", base, i); !usados[candidato] {
+			return candidato
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/uptrace/bun"
+)
+
+// WithTx ejecuta fn dentro de una transacción: hace COMMIT si fn retorna nil y ROLLBACK si retorna error o hace panic.
+// Dentro de fn se deben usar las variantes *Tx con el tx recibido, no las funciones que usan DB directamente.
+// Ej:
+//
+//	err := db.WithTx(ctx, func(tx bun.IDB) error {
+//		if _, err := db.DeleteTx(ctx, tx, "portada_pelicula", "p_id = ?", id); err != nil {
+//			return err
+//		}
+//		return db.InsertTx(ctx, tx, "portada_pelicula", &portada)
+//	})
+func WithTx(ctx context.Context, fn func(tx bun.IDB) error) error {
+	if DB == nil {
+		return fmt.Errorf("DB no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
+	}
+
+	return DB.RunInTx(ctx, &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
+		return fn(tx)
+	})
+}
+
+// SelectOneTx es la variante de SelectOne que se ejecuta sobre idb (DB o una transacción).
+// Con porActualizar agrega FOR UPDATE para bloquear la fila hasta el fin de la transacción.
+func SelectOneTx(ctx context.Context, idb bun.IDB, table string, dest interface{}, porActualizar bool, where string, args ...interface{}) error {
+	q := seleccionarColumnas(idb.NewSelect().Table(table), table, dest)
+	q = filtrarBorrados(ctx, q, table).Where(where, args...)
+	if porActualizar {
+		q = q.For("UPDATE")
+	}
+	return q.Scan(ctx, dest)
+}
+
+// SelectTx realiza un SELECT de varias filas sobre idb (DB o una transacción), con WHERE y ORDER BY opcionales.
+func SelectTx(ctx context.Context, idb bun.IDB, table string, dest interface{}, order string, where string, args ...interface{}) error {
+	q := seleccionarColumnas(idb.NewSelect().Table(table), table, dest)
+	q = filtrarBorrados(ctx, q, table)
+	if where != "" {
+		q = q.Where(where, args...)
+	}
+	if order != "" {
+		q = q.OrderExpr(order)
+	}
+	return q.Scan(ctx, dest)
+}
+
+// UpdateTx es la variante de Update que se ejecuta sobre idb (DB o una transacción).
+func UpdateTx(ctx context.Context, idb bun.IDB, table string, model interface{}, where string, args ...interface{}) (int64, error) {
+	q := idb.NewUpdate().Model(model).ModelTableExpr(table).Where(where, args...)
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error actualizando: %w", err)
+	}
+
+	filasAfectadas, _ := res.RowsAffected() // Ignoramos el error
+	log.Printf("Registro actualizado en tabla %s con WHERE: %s", table, where)
+	return filasAfectadas, nil
+}
+
+// UpdateColumnasTx actualiza solo las columnas del mapa (un valor nil deja la columna en NULL) sobre idb (DB o una
+// transacción), a diferencia de UpdateTx que escribe todas las columnas del modelo. Sirve para actualizaciones
+// parciales (PATCH).
+func UpdateColumnasTx(ctx context.Context, idb bun.IDB, table string, columnas map[string]interface{}, where string, args ...interface{}) (int64, error) {
+	q := idb.NewUpdate().Model(&columnas).TableExpr(table).Where(where, args...)
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error actualizando: %w", err)
+	}
+
+	filasAfectadas, _ := res.RowsAffected() // Ignoramos el error
+	log.Printf("%d columnas actualizadas en tabla %s con WHERE: %s", len(columnas), table, where)
+	return filasAfectadas, nil
+}
+
+// UpdateConVersionTx es UpdateTx con control de concurrencia optimista para tablas con columna version: solo
+// actualiza si la fila sigue en la versión leída y la incrementa en 1. Si ninguna fila cumple retorna ErrVersion.
+// model puede ser un struct (como en UpdateTx) o un *map[string]interface{} (como en UpdateColumnasTx).
+func UpdateConVersionTx(ctx context.Context, idb bun.IDB, table string, model interface{}, version int64, where string, args ...interface{}) (int64, error) {
+	q := idb.NewUpdate().Where(where, args...).Where("version = ?", version)
+	if columnas, ok := model.(*map[string]interface{}); ok {
+		(*columnas)["version"] = bun.Safe("version + 1")
+		q = q.Model(columnas).TableExpr(table)
+	} else {
+		q = q.Model(model).ModelTableExpr(table).Value("version", "version + 1")
+	}
+
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error actualizando: %w", err)
+	}
+
+	filasAfectadas, _ := res.RowsAffected() // Ignoramos el error
+	if filasAfectadas == 0 {
+		return 0, ErrVersion
+	}
+	log.Printf("Registro actualizado a la versión %d en tabla %s con WHERE: %s", version+1, table, where)
+	return filasAfectadas, nil
+}
+
+// DeleteTx es la variante de Delete que se ejecuta sobre idb (DB o una transacción).
+func DeleteTx(ctx context.Context, idb bun.IDB, table string, where string, args ...interface{}) (int64, error) {
+	q := idb.NewDelete().Table(table).Where(where, args...)
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error eliminando: %w", err)
+	}
+
+	filasAfectadas, _ := res.RowsAffected() // Se ignora el error
+	log.Printf("%d registos eliminados de la tabla %s con WHERE: %s", filasAfectadas, table, where)
+	return filasAfectadas, nil
+}
+
+// InsertTx es la variante de Insert que se ejecuta sobre idb (DB o una transacción).
+func InsertTx(ctx context.Context, idb bun.IDB, table string, model interface{}) error {
+	_, err := idb.NewInsert().Model(model).ModelTableExpr(table).Returning("id").Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("error insertando: %w", err)
+	}
+	log.Printf("Registro insertado exitosamente en tabla inferida del modelo.")
+	return nil
+}
+
+// InsertBatchTx es la variante de InsertBatch que se ejecuta sobre idb (DB o una transacción).
+func InsertBatchTx[T any](ctx context.Context, idb bun.IDB, table string, models []T) (int64, error) {
+	res, err := idb.NewInsert().Model(&models).ModelTableExpr(table).Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error insertando batch: %w", err)
+	}
+
+	filas, _ := res.RowsAffected()
+	log.Printf("Batch de %d registros insertado exitosamente.", filas)
+	return filas, nil
+}
@@ -0,0 +1,33 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditoriaInsert struct {
+	ID        int64     `bun:"id,pk,autoincrement"`
+	UsuarioID int64     `bun:"usuario_id,nullzero"`
+	Accion    string    `bun:"accion"`
+	Entidad   string    `bun:"entidad"`
+	EntidadID string    `bun:"entidad_id"`
+	Antes     string    `bun:"antes,nullzero"`
+	Despues   string    `bun:"despues,nullzero"`
+	IP        string    `bun:"ip"`
+	RequestID string    `bun:"request_id"`
+	CreatedAt time.Time `bun:"created_at"`
+}
+
+// AuditoriaSelectDTO es un registro de GET /auditoria. Antes y después van como el JSON guardado (null si no hay).
+type AuditoriaSelectDTO struct {
+	ID        int64           `json:"id" bun:"id"`
+	UsuarioID *int64          `json:"usuario_id" bun:"usuario_id"`
+	Accion    string          `json:"accion" bun:"accion"`
+	Entidad   string          `json:"entidad" bun:"entidad"`
+	EntidadID string          `json:"entidad_id" bun:"entidad_id"`
+	Antes     json.RawMessage `json:"antes" bun:"antes"`
+	Despues   json.RawMessage `json:"despues" bun:"despues"`
+	IP        string          `json:"ip" bun:"ip"`
+	RequestID string          `json:"request_id" bun:"request_id"`
+	CreatedAt time.Time       `json:"created_at" bun:"created_at"`
+}
@@ -4,3 +4,7 @@ type LoginDTO struct {
 	Correo   string `json:"correo" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
 }
+
+type RefreshDTO struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
@@ -0,0 +1,70 @@
+package dto
+
+import "encoding/json"
+
+// Campo es un campo de un cuerpo PATCH con la semántica de JSON Merge Patch (RFC 7396): si no viene en el JSON
+// no se modifica, si viene null se borra (la columna queda NULL) y si viene con un valor se reemplaza.
+// Las reglas `binding:"..."` se aplican al valor y solo cuando viene uno, ej: `binding:"omitnil,min=1000"`.
+type Campo[T any] struct {
+	Presente bool // Vino en el JSON, con un valor o null
+	Nulo     bool // Vino null
+	Valor    T
+}
+
+// CampoPatch permite recorrer los campos de un DTO de PATCH sin conocer su tipo (ver helpers.ColumnasPatch).
+type CampoPatch interface {
+	Patch() (presente, nulo bool, valor interface{})
+}
+
+func (c *Campo[T]) UnmarshalJSON(b []byte) error {
+	c.Presente = true
+	if string(b) == "null" {
+		var cero T
+		c.Nulo, c.Valor = true, cero
+		return nil
+	}
+	c.Nulo = false
+	return json.Unmarshal(b, &c.Valor)
+}
+
+// Asignado indica si el campo vino con un valor (ni ausente ni null).
+func (c Campo[T]) Asignado() bool {
+	return c.Presente && !c.Nulo
+}
+
+// Aplicar copia el campo en destino si vino en el JSON (con null queda el valor cero de T).
+func (c Campo[T]) Aplicar(destino *T) {
+	if c.Presente {
+		*destino = c.Valor
+	}
+}
+
+func (c Campo[T]) Patch() (presente, nulo bool, valor interface{}) {
+	return c.Presente, c.Nulo, c.Valor
+}
+
+// Los DTO de PATCH solo tienen los campos editables. La columna y si acepta null salen del tag bun
+// (nullzero = la columna acepta NULL); un null en otro campo es un error de validación.
+
+type PeliculaPatch struct {
+	Anio        Campo[int]    `json:"anio" bun:"anio,nullzero" binding:"omitnil,min=1000,max=9999"`
+	Titulo      Campo[string] `json:"titulo" bun:"titulo" binding:"omitnil,min=1,max=255"`
+	Descripcion Campo[string] `json:"descripcion" bun:"descripcion,nullzero"`
+	Director    Campo[string] `json:"director" bun:"director" binding:"omitnil,min=1,max=100"`
+}
+
+type TematicaPatch struct {
+	Nombre Campo[string] `json:"nombre" bun:"nombre" binding:"omitnil,min=1,max=100"`
+}
+
+type PerfilPatch struct {
+	Nombre Campo[string] `json:"nombre" bun:"nombre" binding:"omitnil,min=1"`
+}
+
+type UsuarioPatch struct {
+	Nombre   Campo[string] `json:"nombre" bun:"nombre" binding:"omitnil,min=1"`
+	Correo   Campo[string] `json:"correo" bun:"correo" binding:"omitnil,email"`
+	Telefono Campo[string] `json:"telefono" bun:"telefono" binding:"omitnil,min=1"`
+	Password Campo[string] `json:"password" bun:"password" binding:"omitnil,min=6"`
+	PerfilID Campo[int64]  `json:"perfil_id" bun:"perfil_id" binding:"omitnil,min=1"`
+}
@@ -0,0 +1,76 @@
+package dto
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCampoUnmarshalJSON(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		json     string
+		presente bool
+		nulo     bool
+		anio     int
+		titulo   string
+	}{
+		{"ausente", `{}`, false, false, 0, ""},
+		{"null", `{"anio": null, "titulo": null}`, true, true, 0, ""},
+		{"valor", `{"anio": 1999, "titulo": "Matrix"}`, true, false, 1999, "Matrix"},
+		{"valor cero", `{"anio": 0, "titulo": ""}`, true, false, 0, ""},
+	}
+	for _, c := range casos {
+		var patch PeliculaPatch
+		if err := json.Unmarshal([]byte(c.json), &patch); err != nil {
+			t.Fatalf("%s: %v", c.nombre, err)
+		}
+		for _, campo := range []CampoPatch{patch.Anio, patch.Titulo} {
+			if presente, nulo, _ := campo.Patch(); presente != c.presente || nulo != c.nulo {
+				t.Errorf("%s: presente, nulo = %v, %v; se esperaba %v, %v", c.nombre, presente, nulo, c.presente, c.nulo)
+			}
+		}
+		if patch.Anio.Valor != c.anio || patch.Titulo.Valor != c.titulo {
+			t.Errorf("%s: valores %d %q, se esperaba %d %q", c.nombre, patch.Anio.Valor, patch.Titulo.Valor, c.anio, c.titulo)
+		}
+		if asignado := c.presente && !c.nulo; patch.Anio.Asignado() != asignado {
+			t.Errorf("%s: Asignado = %v, se esperaba %v", c.nombre, patch.Anio.Asignado(), asignado)
+		}
+	}
+}
+
+func TestCampoUnmarshalJSONTipoInvalido(t *testing.T) {
+	var patch PeliculaPatch
+	if err := json.Unmarshal([]byte(`{"anio": "1999"}`), &patch); err == nil {
+		t.Error("se aceptó un string en un campo int")
+	}
+}
+
+func TestCampoNullDespuesDeValor(t *testing.T) {
+	// Un mismo Campo reutilizado: null deja el valor cero, no el anterior
+	c := Campo[string]{}
+	if err := json.Unmarshal([]byte(`"hola"`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(`null`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Presente || !c.Nulo || c.Valor != "" {
+		t.Errorf("después de null: %+v", c)
+	}
+}
+
+func TestCampoAplicar(t *testing.T) {
+	destino := "original"
+	Campo[string]{}.Aplicar(&destino)
+	if destino != "original" {
+		t.Errorf("un campo ausente cambió el destino a %q", destino)
+	}
+	Campo[string]{Presente: true, Valor: "nuevo"}.Aplicar(&destino)
+	if destino != "nuevo" {
+		t.Errorf("Aplicar con valor = %q", destino)
+	}
+	Campo[string]{Presente: true, Nulo: true}.Aplicar(&destino)
+	if destino != "" {
+		t.Errorf("Aplicar con null = %q, se esperaba el valor cero", destino)
+	}
+}
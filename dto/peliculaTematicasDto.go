@@ -3,7 +3,7 @@ package dto
 import "time"
 
 type PeliculaTematicasSelectOne struct {
-	PID        int64     `json:"p_id"`
+	PID        int64     `json:"p_id" bun:"p_id"`
 	TematicaID int64     `json:"tematica_id"`
 	Orden      int       `json:"orden"`
 	CreatedAt  time.Time `json:"created_at"`
@@ -3,14 +3,20 @@ package dto
 import "time"
 
 type PeliculaSelectDTO struct {
-	ID          int64     `json:"id"`
-	Anio        int       `json:"anio"`
-	Titulo      string    `json:"titulo"`
-	Slug        string    `json:"slug"`
-	Descripcion string    `json:"descripcion"`
-	Director    string    `json:"director"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int64      `json:"id"`
+	Anio        int        `json:"anio"`
+	Titulo      string     `json:"titulo"`
+	Slug        string     `json:"slug"`
+	Descripcion string     `json:"descripcion"`
+	Director    string     `json:"director"`
+	Version     int64      `json:"-"` // Va en el header ETag
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"` // Solo en la papelera
+
+	// Relaciones opcionales, se cargan solo con ?include=tematicas,portada
+	Tematicas []TematicasPeliculaDTO `json:"tematicas,omitempty" bun:"-"`
+	Portada   *PortadaSelectDTO      `json:"portada,omitempty" bun:"-"`
 }
 
 type PeliculasAllSelect []PeliculaSelectDTO
@@ -22,6 +28,7 @@ type PeliculaInsert struct {
 	Slug        string    `json:"slug,omitempty"`
 	Descripcion string    `json:"descripcion" binding:"required"`
 	Director    string    `json:"director" binding:"required"`
+	Busqueda    string    `json:"-"` // Texto normalizado para el índice FULLTEXT, no se expone
 	CreatedAt   time.Time `json:"created_at,omitempty"`
 	UpdatedAt   time.Time `json:"updated_at,omitempty"`
 }
@@ -33,5 +40,50 @@ type PeliculaUpdate struct {
 	Slug        string    `json:"slug,omitempty"` // Este campo no se usa en el JSON, es solo para entregar la información en la respuesta
 	Descripcion string    `json:"descripcion,omitempty" binding:"required"`
 	Director    string    `json:"director,omitempty" binding:"required"`
+	Busqueda    string    `json:"-"` // Texto normalizado para el índice FULLTEXT, no se expone
 	UpdatedAt   time.Time `json:"updated_at,omitempty"`
 }
+
+// PeliculaBusquedaDTO es un resultado de GET /peliculas/search con su puntaje de relevancia.
+type PeliculaBusquedaDTO struct {
+	PeliculaSelectDTO
+	Relevancia float64 `json:"relevancia" bun:"relevancia"`
+}
+
+// PeliculaImport es una fila de POST /peliculas/import: los campos (y reglas de validación) de PeliculaInsert
+// más los slugs de sus temáticas, en orden.
+type PeliculaImport struct {
+	PeliculaInsert
+	Tematicas []string `json:"tematicas"`
+}
+
+// ErrorImportacion agrupa los errores de validación de una fila del archivo importado.
+type ErrorImportacion struct {
+	Fila    int      `json:"fila"` // Número de línea en el archivo
+	Titulo  string   `json:"titulo,omitempty"`
+	Errores []string `json:"errores"`
+}
+
+// PeliculaExport es una película de GET /peliculas/export?format=ndjson. Las temáticas van como slugs,
+// en orden, igual que en POST /peliculas/import.
+type PeliculaExport struct {
+	ID             int64     `json:"id"`
+	Anio           int       `json:"anio"`
+	Titulo         string    `json:"titulo"`
+	Slug           string    `json:"slug"`
+	Descripcion    string    `json:"descripcion"`
+	Director       string    `json:"director"`
+	Tematicas      []string  `json:"tematicas"`
+	PortadaUrl     string    `json:"portada_url,omitempty"`
+	PortadaArchivo string    `json:"portada_archivo,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// SlugPeliculaInsert es un slug antiguo de una película, se guarda al cambiar su título.
+type SlugPeliculaInsert struct {
+	ID        int64     `bun:",pk,autoincrement"`
+	PID       int64     `bun:"p_id"`
+	Slug      string    `bun:"slug"`
+	CreatedAt time.Time `bun:"created_at"`
+}
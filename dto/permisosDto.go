@@ -0,0 +1,21 @@
+package dto
+
+import "time"
+
+type PermisoSelectDTO struct {
+	ID          int64  `json:"id" bun:"id"`
+	Codigo      string `json:"codigo" bun:"codigo"`
+	Descripcion string `json:"descripcion" bun:"descripcion"`
+}
+
+type PermisosAllSelect []PermisoSelectDTO
+
+type PermisosAsignar struct {
+	Permisos []string `json:"permisos" binding:"required,min=1"`
+}
+
+type PerfilPermisoInsert struct {
+	PerfilID  int64     `json:"perfil_id" bun:"perfil_id"`
+	PermisoID int64     `json:"permiso_id" bun:"permiso_id"`
+	CreatedAt time.Time `json:"created_at" bun:"created_at"`
+}
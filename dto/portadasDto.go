@@ -3,15 +3,34 @@ package dto
 import "time"
 
 type PortadaSelectDTO struct {
-	ID            int64     `json:"id" bun:"id"`
-	PID           int64     `json:"p_id" bun:"p_id"`
-	NombreArchivo string    `json:"nombre_archivo" bun:"nombre_archivo"`
-	Url           string    `json:"url" bun:"-"` // Campo calculado, no en BD
-	CreatedAt     time.Time `json:"created_at" bun:"created_at"`
+	ID            int64          `json:"id" bun:"id"`
+	PID           int64          `json:"p_id" bun:"p_id"`
+	NombreArchivo string         `json:"nombre_archivo" bun:"nombre_archivo"`
+	Tipo          string         `json:"tipo" bun:"tipo"`
+	Orden         int            `json:"orden" bun:"orden"`
+	IsPrimary     bool           `json:"is_primary" bun:"is_primary"`
+	Url           string         `json:"url" bun:"-"`                  // Campo calculado, no en BD
+	Miniaturas    map[int]string `json:"miniaturas,omitempty" bun:"-"` // Ancho (px) -> URL, campo calculado
+	CreatedAt     time.Time      `json:"created_at" bun:"created_at"`
 }
 
 type PortadaInsertDTO struct {
+	ID            int64     `json:"id" bun:"id,pk,autoincrement"`
 	PID           int64     `json:"p_id" bun:"p_id"`
 	NombreArchivo string    `json:"nombre_archivo" bun:"nombre_archivo"`
+	Tipo          string    `json:"tipo" bun:"tipo"`
+	Orden         int       `json:"orden" bun:"orden"`
+	IsPrimary     bool      `json:"is_primary" bun:"is_primary"`
 	CreatedAt     time.Time `json:"created_at" bun:",type:timestamp,default:current_timestamp"`
 }
+
+// ImagenInsertForm son los campos de formulario que acompañan al archivo en POST /peliculas/:id/imagenes.
+type ImagenInsertForm struct {
+	Tipo      string `form:"tipo" binding:"required,oneof=poster backdrop still"`
+	Principal bool   `form:"principal"` // Si es true la imagen pasa a ser la principal de la película
+}
+
+// ImagenesOrden es el nuevo orden de las imágenes de una película: cada id queda con orden = posición + 1.
+type ImagenesOrden struct {
+	IDs []int64 `json:"ids" binding:"required,min=1"`
+}
@@ -31,3 +31,10 @@ type TematicasPeliculaJoinRow struct {
 	// Pelicula_Tematicas
 	Orden int
 }
+
+// PeliculaExportRow es una fila del JOIN que recorre GET /peliculas/export: una por cada temática de la película,
+// con el archivo de su imagen principal (vacío si no tiene).
+type PeliculaExportRow struct {
+	PeliculaJoinRow
+	Portada string
+}
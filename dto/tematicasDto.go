@@ -5,9 +5,21 @@ import (
 )
 
 type TematicasSelectOne struct {
+	ID        int64      `json:"id"`
+	Nombre    string     `json:"nombre"`
+	Slug      string     `json:"slug"`
+	Version   int64      `json:"-"` // Va en el header ETag
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"` // Solo en la papelera
+}
+
+// TematicasPeliculaDTO es una temática asociada a una película, con su orden dentro de ella.
+type TematicasPeliculaDTO struct {
 	ID        int64     `json:"id"`
 	Nombre    string    `json:"nombre"`
 	Slug      string    `json:"slug"`
+	Orden     int       `json:"orden"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
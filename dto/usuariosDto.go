@@ -3,14 +3,15 @@ package dto
 import "time"
 
 type UsuarioPerfilDTO struct {
-	ID           int64     `json:"id" bun:"id"`
-	Nombre       string    `json:"nombre" bun:"nombre"`
-	Correo       string    `json:"correo" bun:"correo"`
-	Telefono     string    `json:"telefono" bun:"telefono"`
-	PerfilID     int64     `json:"perfil_id" bun:"perfil_id"`
-	PerfilNombre string    `json:"perfil" bun:"perfil_nombre"` // Join column
-	CreatedAt    time.Time `json:"created_at" bun:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at" bun:"updated_at"`
+	ID           int64      `json:"id" bun:"id"`
+	Nombre       string     `json:"nombre" bun:"nombre"`
+	Correo       string     `json:"correo" bun:"correo"`
+	Telefono     string     `json:"telefono" bun:"telefono"`
+	PerfilID     int64      `json:"perfil_id" bun:"perfil_id"`
+	PerfilNombre string     `json:"perfil" bun:"perfil_nombre"` // Join column
+	CreatedAt    time.Time  `json:"created_at" bun:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" bun:"updated_at"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty" bun:"deleted_at"` // Solo en la papelera
 }
 
 type UsuarioInsert struct {
@@ -22,12 +23,17 @@ type UsuarioInsert struct {
 	PerfilID int64  `json:"perfil_id" binding:"required"`
 }
 
+// UsuarioUpdate es el cuerpo de PUT /usuarios/:id. Los campos vacíos no se modifican.
 type UsuarioUpdate struct {
-	ID        int64     `json:"id,omitempty" bun:"id"`
-	Nombre    string    `json:"nombre,omitempty"`
-	Correo    string    `json:"correo,omitempty" binding:"omitempty,email"`
-	Telefono  string    `json:"telefono,omitempty"`
-	Password  string    `json:"password,omitempty" binding:"omitempty,min=6"`
-	PerfilID  int64     `json:"perfil_id,omitempty"`
-	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	Nombre   string `json:"nombre,omitempty"`
+	Correo   string `json:"correo,omitempty" binding:"omitempty,email"`
+	Telefono string `json:"telefono,omitempty"`
+	Password string `json:"password,omitempty" binding:"omitempty,min=6"`
+	PerfilID int64  `json:"perfil_id,omitempty"`
+}
+
+// PerfilUsuarioToken son los datos del usuario necesarios para emitir un token.
+type PerfilUsuarioToken struct {
+	ID       int64 `bun:"id"`
+	PerfilID int64 `bun:"perfil_id"`
 }
@@ -1,12 +1,18 @@
 package helpers
 
-/*func AgruparPeliculas(rows []dto.PeliculaJoinRow) []dto.PeliculaSelectDTO {
-	peliculasMap := make(map[int64]*dto.PeliculaSelectDTO)
+import "github.com/jgutierrez746/clase_7_gin_bun/dto"
+
+// AgruparPeliculas agrupa las filas del LEFT JOIN peliculas/pelicula_tematicas/tematicas en una película por ID.
+// Se conserva el orden en que cada película aparece por primera vez en rows (ej: ORDER BY id DESC),
+// por eso se usa un slice con índice en vez de iterar el map.
+func AgruparPeliculas(rows []dto.PeliculaJoinRow) []dto.PeliculaSelectDTO {
+	peliculas := []dto.PeliculaSelectDTO{}
+	indices := make(map[int64]int)
 
 	for _, r := range rows {
-		p, exists := peliculasMap[r.ID]
+		i, exists := indices[r.ID]
 		if !exists {
-			p = &dto.PeliculaSelectDTO{
+			peliculas = append(peliculas, dto.PeliculaSelectDTO{
 				ID:          r.ID,
 				Anio:        r.Anio,
 				Titulo:      r.Titulo,
@@ -15,26 +21,23 @@ package helpers
 				Director:    r.Director,
 				CreatedAt:   r.CreatedAt,
 				UpdatedAt:   r.UpdatedAt,
-				Tematicas:   []dto.TematicasSelectOne{},
-			}
-			peliculasMap[r.ID] = p
+				Tematicas:   []dto.TematicasPeliculaDTO{},
+			})
+			i = len(peliculas) - 1
+			indices[r.ID] = i
 		}
 
 		if r.TematicaID != 0 {
-			p.Tematicas = append(p.Tematicas, dto.TematicasSelectOne{
+			peliculas[i].Tematicas = append(peliculas[i].Tematicas, dto.TematicasPeliculaDTO{
 				ID:        r.TematicaID,
 				Nombre:    r.Nombre,
 				Slug:      r.SlugTem,
+				Orden:     r.Orden,
 				CreatedAt: r.TCreatedAt,
 				UpdatedAt: r.TUpdatedAt,
 			})
 		}
 	}
 
-	var peliculas []dto.PeliculaSelectDTO
-	for _, p := range peliculasMap {
-		peliculas = append(peliculas, *p)
-	}
 	return peliculas
 }
-*/
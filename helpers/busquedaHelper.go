@@ -0,0 +1,54 @@
+package helpers
+
+import (
+	"strings"
+
+	"github.com/gosimple/slug"
+)
+
+// NormalizarBusqueda transforma los textos igual que slug.Make (sin tildes, minúsculas, sin símbolos),
+// pero separando las palabras con espacios para que MySQL las indexe por separado.
+// Ej: NormalizarBusqueda("Película", "Guillermo del Toro") -> "pelicula guillermo del toro"
+func NormalizarBusqueda(textos ...string) string {
+	partes := make([]string, 0, len(textos))
+	for _, texto := range textos {
+		if s := slug.Make(texto); s != "" {
+			partes = append(partes, strings.ReplaceAll(s, "-", " "))
+		}
+	}
+	return strings.Join(partes, " ")
+}
+
+// largoMinimoTokenFT es innodb_ft_min_token_size (por defecto 3): las palabras más cortas no se indexan.
+const largoMinimoTokenFT = 3
+
+// stopwordsFT es la lista de stopwords por defecto de InnoDB (INFORMATION_SCHEMA.INNODB_FT_DEFAULT_STOPWORD),
+// palabras que tampoco se indexan.
+var stopwordsFT = map[string]bool{
+	"a": true, "about": true, "an": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"com": true, "de": true, "en": true, "for": true, "from": true, "how": true, "i": true, "in": true,
+	"is": true, "it": true, "la": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "was": true, "what": true, "when": true, "where": true, "who": true,
+	"will": true, "with": true, "und": true, "www": true,
+}
+
+// PalabrasBusqueda retorna las palabras normalizadas de q (ver NormalizarBusqueda).
+func PalabrasBusqueda(q string) []string {
+	return strings.Fields(NormalizarBusqueda(q))
+}
+
+// ConsultaBooleana arma la expresión para MATCH ... AGAINST IN BOOLEAN MODE:
+// todas las palabras son obligatorias y se buscan por prefijo. Se omiten las que el índice no tiene
+// (más cortas que largoMinimoTokenFT o stopwords), que de ser obligatorias harían que nada coincida.
+// Retorna "" si no queda ninguna; en ese caso se busca con LIKE (ver PalabrasBusqueda).
+// Ej: ConsultaBooleana("El padrino terror") -> "+padrino* +terror*"
+func ConsultaBooleana(q string) string {
+	var terminos []string
+	for _, palabra := range PalabrasBusqueda(q) {
+		if len(palabra) < largoMinimoTokenFT || stopwordsFT[palabra] {
+			continue
+		}
+		terminos = append(terminos, "+"+palabra+"*")
+	}
+	return strings.Join(terminos, " ")
+}
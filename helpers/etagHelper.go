@@ -0,0 +1,48 @@
+package helpers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ETag es el ETag de un registro con columna version, ej: "3". Cambia con cada escritura del registro.
+func ETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// CumpleIfMatch indica si se puede escribir el registro según el header If-Match: sin header o con "*" siempre,
+// si no solo si la lista incluye el ETag de la versión actual. La comparación es fuerte: un W/"3" no coincide.
+func CumpleIfMatch(c *gin.Context, version int64) bool {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return true
+	}
+	etag := ETag(version)
+	for _, candidato := range strings.Split(header, ",") {
+		if strings.TrimSpace(candidato) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// NoModificado indica si el header If-None-Match incluye el ETag de la versión actual (o es "*"), en cuyo caso
+// un GET responde 304. La comparación es débil: W/"3" coincide con "3".
+func NoModificado(c *gin.Context, version int64) bool {
+	header := strings.TrimSpace(c.GetHeader("If-None-Match"))
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+	etag := ETag(version)
+	for _, candidato := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidato), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
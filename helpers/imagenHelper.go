@@ -0,0 +1,248 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Registra el decoder WebP para image.Decode
+)
+
+// Límites de las imágenes subidas.
+const (
+	TamanoMaximoImagen    = 10 << 20 // 10 MB
+	DimensionMaximaImagen = 4000     // px por lado
+	CalidadJPEG           = 85
+)
+
+// AnchosMiniatura son los anchos (px) de las miniaturas que se generan de cada portada.
+var AnchosMiniatura = []int{150, 300, 600}
+
+// Errores de validación, el handler los traduce a 4xx con errors.Is.
+var (
+	ErrImagenMuyGrande   = errors.New("la imagen supera el tamaño máximo")
+	ErrFormatoImagen     = errors.New("formato de imagen no soportado (solo JPEG, PNG o WebP)")
+	ErrDimensionesImagen = errors.New("dimensiones de imagen inválidas")
+)
+
+// ImagenProcesada es una imagen validada y re-codificada, lista para guardar.
+type ImagenProcesada struct {
+	Extension     string         // ".jpg" o ".png", según el formato de salida
+	TipoContenido string         // "image/jpeg" o "image/png"
+	Original      []byte         // Imagen completa sin metadatos
+	Miniaturas    map[int][]byte // Ancho -> imagen, uno por cada valor de AnchosMiniatura
+}
+
+// ProcesarImagen valida y normaliza una imagen subida:
+//   - El tipo se detecta por el contenido, no por la extensión del nombre: solo JPEG, PNG y WebP.
+//   - Se rechazan archivos sobre TamanoMaximoImagen y lados sobre DimensionMaximaImagen (antes de decodificar todo).
+//   - Se re-codifica, lo que descarta EXIF y cualquier otro metadato; la orientación EXIF de los JPEG se aplica antes.
+//   - PNG y WebP con transparencia se guardan como PNG, el resto como JPEG.
+//   - Se generan las miniaturas de AnchosMiniatura conservando la proporción (nunca se agranda la imagen).
+func ProcesarImagen(r io.Reader) (*ImagenProcesada, error) {
+	datos, err := io.ReadAll(io.LimitReader(r, TamanoMaximoImagen+1))
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo imagen: %w", err)
+	}
+	if len(datos) > TamanoMaximoImagen {
+		return nil, fmt.Errorf("%w (%d MB)", ErrImagenMuyGrande, TamanoMaximoImagen>>20)
+	}
+
+	switch tipo := http.DetectContentType(datos); tipo {
+	case "image/jpeg", "image/png", "image/webp":
+	default:
+		return nil, fmt.Errorf("%w: se recibió %s", ErrFormatoImagen, tipo)
+	}
+
+	// DecodeConfig solo lee la cabecera, así una imagen de 50000x50000 no llega a reservar memoria
+	cfg, formato, err := image.DecodeConfig(bytes.NewReader(datos))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFormatoImagen, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > DimensionMaximaImagen || cfg.Height > DimensionMaximaImagen {
+		return nil, fmt.Errorf("%w: %dx%d (máximo %dx%d)", ErrDimensionesImagen, cfg.Width, cfg.Height, DimensionMaximaImagen, DimensionMaximaImagen)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(datos))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFormatoImagen, err)
+	}
+	if formato == "jpeg" {
+		img = orientar(img, orientacionExif(datos))
+	}
+
+	// La transparencia solo se conserva en PNG
+	comoPNG := false
+	if formato != "jpeg" {
+		if o, ok := img.(interface{ Opaque() bool }); !ok || !o.Opaque() {
+			comoPNG = true
+		}
+	}
+
+	procesada := &ImagenProcesada{Extension: ".jpg", TipoContenido: "image/jpeg", Miniaturas: make(map[int][]byte)}
+	if comoPNG {
+		procesada.Extension, procesada.TipoContenido = ".png", "image/png"
+	}
+
+	if procesada.Original, err = codificar(img, comoPNG); err != nil {
+		return nil, err
+	}
+	for _, ancho := range AnchosMiniatura {
+		if procesada.Miniaturas[ancho], err = codificar(redimensionar(img, ancho), comoPNG); err != nil {
+			return nil, err
+		}
+	}
+	return procesada, nil
+}
+
+// ClaveMiniatura retorna la clave de almacenamiento de una miniatura, ej: ("12_170.jpg", 300) -> "12_170_300.jpg".
+func ClaveMiniatura(nombreArchivo string, ancho int) string {
+	ext := path.Ext(nombreArchivo)
+	return strings.TrimSuffix(nombreArchivo, ext) + "_" + strconv.Itoa(ancho) + ext
+}
+
+// ClavesPortada retorna la clave del archivo original y las de todas sus miniaturas.
+func ClavesPortada(nombreArchivo string) []string {
+	claves := []string{nombreArchivo}
+	for _, ancho := range AnchosMiniatura {
+		claves = append(claves, ClaveMiniatura(nombreArchivo, ancho))
+	}
+	return claves
+}
+
+func codificar(img image.Image, comoPNG bool) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if comoPNG {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: CalidadJPEG})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error codificando imagen: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// redimensionar escala img al ancho indicado conservando la proporción. Si ya es más angosta se retorna tal cual.
+func redimensionar(img image.Image, ancho int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= ancho {
+		return img
+	}
+	alto := max(1, b.Dy()*ancho/b.Dx())
+	dst := image.NewNRGBA(image.Rect(0, 0, ancho, alto))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// orientacionExif lee el tag Orientation (0x0112) del segmento APP1 Exif de un JPEG.
+// Retorna 1 (sin transformación) si no hay EXIF o no se puede leer.
+func orientacionExif(jpg []byte) int {
+	if len(jpg) < 4 || jpg[0] != 0xFF || jpg[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(jpg); {
+		if jpg[i] != 0xFF {
+			return 1
+		}
+		marcador := jpg[i+1]
+		largo := int(binary.BigEndian.Uint16(jpg[i+2:]))
+		if marcador == 0xDA || largo < 2 || i+2+largo > len(jpg) { // Inicio de los datos de imagen: ya no hay metadatos
+			return 1
+		}
+		segmento := jpg[i+4 : i+2+largo]
+		if marcador == 0xE1 && bytes.HasPrefix(segmento, []byte("Exif\x00\x00")) {
+			return orientacionTIFF(segmento[6:])
+		}
+		i += 2 + largo
+	}
+	return 1
+}
+
+func orientacionTIFF(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var orden binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		orden = binary.LittleEndian
+	case "MM":
+		orden = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(orden.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entradas := int(orden.Uint16(tiff[ifd:]))
+	for e := 0; e < entradas; e++ {
+		pos := ifd + 2 + e*12
+		if pos+12 > len(tiff) {
+			return 1
+		}
+		if orden.Uint16(tiff[pos:]) == 0x0112 {
+			if o := int(orden.Uint16(tiff[pos+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orientar aplica la transformación de la orientación EXIF (2-8) para que la imagen quede derecha sin el metadato.
+func orientar(img image.Image, orientacion int) image.Image {
+	if orientacion <= 1 || orientacion > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	src := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	w, h := b.Dx(), b.Dy()
+
+	// Las orientaciones 5-8 intercambian ancho y alto
+	dw, dh := w, h
+	if orientacion >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientacion {
+			case 2: // Espejo horizontal
+				dx, dy = w-1-x, y
+			case 3: // Rotado 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // Espejo vertical
+				dx, dy = x, h-1-y
+			case 5: // Transpuesta
+				dx, dy = y, x
+			case 6: // Rotar 90° horario
+				dx, dy = h-1-y, x
+			case 7: // Transversa
+				dx, dy = h-1-y, w-1-x
+			case 8: // Rotar 90° antihorario
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], src.Pix[src.PixOffset(x, y):][:4])
+		}
+	}
+	return dst
+}
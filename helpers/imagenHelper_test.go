@@ -0,0 +1,207 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// tiffOrientacion arma un bloque TIFF con un IFD de una sola entrada: el tag Orientation con el valor indicado.
+func tiffOrientacion(orden binary.ByteOrder, orientacion uint16) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	if orden == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	orden.PutUint16(tiff[2:], 42)
+	orden.PutUint32(tiff[4:], 8) // El IFD empieza justo después de la cabecera
+	orden.PutUint16(tiff[8:], 1)
+	orden.PutUint16(tiff[10:], 0x0112) // Orientation
+	orden.PutUint16(tiff[12:], 3)      // SHORT
+	orden.PutUint32(tiff[14:], 1)
+	orden.PutUint16(tiff[18:], orientacion)
+	return tiff
+}
+
+// segmento arma un segmento JPEG (marcador, largo y datos).
+func segmento(marcador byte, datos []byte) []byte {
+	s := []byte{0xFF, marcador, 0, 0}
+	binary.BigEndian.PutUint16(s[2:], uint16(len(datos)+2))
+	return append(s, datos...)
+}
+
+// conSegmentos inserta los segmentos después del SOI de jpg.
+func conSegmentos(jpg []byte, segmentos ...[]byte) []byte {
+	resultado := append([]byte{}, jpg[:2]...)
+	for _, s := range segmentos {
+		resultado = append(resultado, s...)
+	}
+	return append(resultado, jpg[2:]...)
+}
+
+func exif(tiff []byte) []byte {
+	return segmento(0xE1, append([]byte("Exif\x00\x00"), tiff...))
+}
+
+// imagenPrueba retorna una imagen de w x h donde cada pixel tiene un color distinto.
+func imagenPrueba(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 40), G: uint8(y * 40), B: 200, A: 255})
+		}
+	}
+	return img
+}
+
+func jpegPrueba(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, imagenPrueba(w, h), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestOrientacionExif(t *testing.T) {
+	jpg := jpegPrueba(t, 3, 2)
+
+	ifdFuera := tiffOrientacion(binary.BigEndian, 6)
+	binary.BigEndian.PutUint32(ifdFuera[4:], 1<<20)
+	ifdAlBorde := tiffOrientacion(binary.BigEndian, 6)
+	binary.BigEndian.PutUint32(ifdAlBorde[4:], uint32(len(ifdAlBorde)-1))
+	entradasDeMas := tiffOrientacion(binary.LittleEndian, 6)
+	binary.LittleEndian.PutUint16(entradasDeMas[8:], 500)
+	binary.LittleEndian.PutUint16(entradasDeMas[10:], 0x0100) // La primera entrada no es Orientation
+	ordenInvalido := tiffOrientacion(binary.BigEndian, 6)
+	copy(ordenInvalido, "XX")
+
+	app1Truncado := exif(tiffOrientacion(binary.BigEndian, 6))
+	binary.BigEndian.PutUint16(app1Truncado[2:], 0xFFF0) // Dice ser más largo que el archivo
+
+	casos := []struct {
+		nombre string
+		datos  []byte
+		espera int
+	}{
+		{"vacío", nil, 1},
+		{"no es JPEG", []byte("GIF89a"), 1},
+		{"JPEG sin EXIF", jpg, 1},
+		{"II orientación 6", conSegmentos(jpg, exif(tiffOrientacion(binary.LittleEndian, 6))), 6},
+		{"MM orientación 8", conSegmentos(jpg, exif(tiffOrientacion(binary.BigEndian, 8))), 8},
+		{"después de otro APP", conSegmentos(jpg, segmento(0xE0, []byte("JFIF\x00")), exif(tiffOrientacion(binary.BigEndian, 3))), 3},
+		{"orientación fuera de rango", conSegmentos(jpg, exif(tiffOrientacion(binary.BigEndian, 9))), 1},
+		{"orden de bytes inválido", conSegmentos(jpg, exif(ordenInvalido)), 1},
+		{"APP1 sin Exif", conSegmentos(jpg, segmento(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00"))), 1},
+		{"APP1 truncado", conSegmentos(jpg, app1Truncado), 1},
+		{"largo de segmento menor a 2", append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x01}, jpg[2:]...), 1},
+		{"solo el SOI y un marcador cortado", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00}, 1},
+		{"TIFF más corto que su cabecera", conSegmentos(jpg, exif([]byte("MM\x00"))), 1},
+		{"IFD fuera del segmento", conSegmentos(jpg, exif(ifdFuera)), 1},
+		{"IFD en el último byte", conSegmentos(jpg, exif(ifdAlBorde)), 1},
+		{"más entradas que bytes", conSegmentos(jpg, exif(entradasDeMas)), 1},
+		{"EXIF después del SOS", append(append([]byte{}, jpg...), exif(tiffOrientacion(binary.BigEndian, 6))...), 1},
+	}
+	for _, c := range casos {
+		if got := orientacionExif(c.datos); got != c.espera {
+			t.Errorf("%s: orientacionExif = %d, se esperaba %d", c.nombre, got, c.espera)
+		}
+	}
+}
+
+func TestOrientar(t *testing.T) {
+	const w, h = 3, 2
+	img := imagenPrueba(w, h)
+	esquina := img.NRGBAAt(0, 0) // Pixel de arriba a la izquierda del original
+
+	casos := []struct {
+		orientacion int
+		ancho, alto int
+		x, y        int // Dónde queda la esquina de arriba a la izquierda
+	}{
+		{1, w, h, 0, 0},
+		{2, w, h, w - 1, 0},
+		{3, w, h, w - 1, h - 1},
+		{4, w, h, 0, h - 1},
+		{5, h, w, 0, 0},
+		{6, h, w, h - 1, 0},
+		{7, h, w, h - 1, w - 1},
+		{8, h, w, 0, w - 1},
+		{9, w, h, 0, 0}, // Fuera de rango: sin cambios
+	}
+	for _, c := range casos {
+		resultado := orientar(img, c.orientacion)
+		b := resultado.Bounds()
+		if b.Dx() != c.ancho || b.Dy() != c.alto {
+			t.Errorf("orientación %d: %dx%d, se esperaba %dx%d", c.orientacion, b.Dx(), b.Dy(), c.ancho, c.alto)
+			continue
+		}
+		if got := color.NRGBAModel.Convert(resultado.At(c.x, c.y)); got != esquina {
+			t.Errorf("orientación %d: en (%d,%d) hay %v, se esperaba la esquina %v", c.orientacion, c.x, c.y, got, esquina)
+		}
+	}
+}
+
+func TestProcesarImagenAplicaOrientacion(t *testing.T) {
+	jpg := conSegmentos(jpegPrueba(t, 30, 20), exif(tiffOrientacion(binary.LittleEndian, 6)))
+
+	procesada, err := ProcesarImagen(bytes.NewReader(jpg))
+	if err != nil {
+		t.Fatalf("ProcesarImagen: %v", err)
+	}
+	if procesada.TipoContenido != "image/jpeg" || procesada.Extension != ".jpg" {
+		t.Errorf("tipo = %s %s, se esperaba image/jpeg .jpg", procesada.TipoContenido, procesada.Extension)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(procesada.Original))
+	if err != nil {
+		t.Fatalf("el original no es un JPEG válido: %v", err)
+	}
+	if cfg.Width != 20 || cfg.Height != 30 {
+		t.Errorf("original de %dx%d, se esperaba 20x30 (rotado)", cfg.Width, cfg.Height)
+	}
+	if bytes.Contains(procesada.Original, []byte("Exif\x00\x00")) {
+		t.Error("el original conserva el EXIF")
+	}
+	if len(procesada.Miniaturas) != len(AnchosMiniatura) {
+		t.Errorf("%d miniaturas, se esperaban %d", len(procesada.Miniaturas), len(AnchosMiniatura))
+	}
+}
+
+func TestProcesarImagenRechaza(t *testing.T) {
+	var grande bytes.Buffer
+	if err := png.Encode(&grande, image.NewGray(image.Rect(0, 0, DimensionMaximaImagen+1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	var alta bytes.Buffer
+	if err := png.Encode(&alta, image.NewGray(image.Rect(0, 0, 1, DimensionMaximaImagen+1))); err != nil {
+		t.Fatal(err)
+	}
+	jpgCortado := jpegPrueba(t, 30, 20)
+	jpgCortado = jpgCortado[:len(jpgCortado)/2]
+
+	casos := []struct {
+		nombre string
+		datos  []byte
+		espera error
+	}{
+		{"texto", []byte("esto no es una imagen"), ErrFormatoImagen},
+		{"HTML", []byte("<html><body>hola</body></html>"), ErrFormatoImagen},
+		{"GIF", []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;"), ErrFormatoImagen},
+		{"JPEG cortado", jpgCortado, ErrFormatoImagen},
+		{"más ancha que el máximo", grande.Bytes(), ErrDimensionesImagen},
+		{"más alta que el máximo", alta.Bytes(), ErrDimensionesImagen},
+		{"archivo sobre el tamaño máximo", append([]byte("\xFF\xD8\xFF"), strings.Repeat("x", TamanoMaximoImagen)...), ErrImagenMuyGrande},
+	}
+	for _, c := range casos {
+		if _, err := ProcesarImagen(bytes.NewReader(c.datos)); !errors.Is(err, c.espera) {
+			t.Errorf("%s: ProcesarImagen = %v, se esperaba %v", c.nombre, err, c.espera)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ParsearInclude lee ?include=a,b y valida cada relación contra las permitidas.
+// Ej: ParsearInclude(c, "tematicas", "portada") con ?include=tematicas -> {"tematicas": true}
+func ParsearInclude(c *gin.Context, permitidos ...string) (map[string]bool, error) {
+	include := map[string]bool{}
+	v := c.Query("include")
+	if v == "" {
+		return include, nil
+	}
+
+	for _, relacion := range strings.Split(v, ",") {
+		relacion = strings.TrimSpace(relacion)
+		valido := false
+		for _, p := range permitidos {
+			if relacion == p {
+				valido = true
+				break
+			}
+		}
+		if !valido {
+			return nil, fmt.Errorf("include no soportado: %s", relacion)
+		}
+		include[relacion] = true
+	}
+	return include, nil
+}
@@ -0,0 +1,197 @@
+package helpers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jgutierrez746/clase_7_gin_bun/db"
+)
+
+const (
+	PaginaPorDefecto    = 1
+	PorPaginaPorDefecto = 20
+	PorPaginaMaximo     = 100
+)
+
+// ParsearPaginacion lee ?page=, ?per_page=, ?sort= y los filtros permitidos desde el query string.
+// - campos: lista blanca de parámetro -> columna SQL, usada tanto para ordenar como para filtrar. Su "id" se agrega
+// al final del orden como desempate (ver desempatarPorID).
+// - ordenDefecto: expresión ORDER BY usada cuando no se envía ?sort=, ej: "id DESC".
+// Ej: ?page=2&per_page=10&sort=titulo,-anio&director=Nolan
+func ParsearPaginacion(c *gin.Context, campos map[string]string, ordenDefecto string) (db.Paginacion, error) {
+	p := db.Paginacion{
+		Pagina:    PaginaPorDefecto,
+		PorPagina: PorPaginaPorDefecto,
+		Filtros:   map[string]string{},
+	}
+
+	if v := c.Query("page"); v != "" {
+		pagina, err := strconv.Atoi(v)
+		if err != nil || pagina < 1 {
+			return p, fmt.Errorf("parámetro page inválido: %s", v)
+		}
+		p.Pagina = pagina
+	}
+
+	if v := c.Query("per_page"); v != "" {
+		porPagina, err := strconv.Atoi(v)
+		if err != nil || porPagina < 1 {
+			return p, fmt.Errorf("parámetro per_page inválido: %s", v)
+		}
+		if porPagina > PorPaginaMaximo {
+			porPagina = PorPaginaMaximo
+		}
+		p.PorPagina = porPagina
+	}
+
+	if v := c.Query("sort"); v != "" {
+		for _, campo := range strings.Split(v, ",") {
+			campo = strings.TrimSpace(campo)
+			direccion := "ASC"
+			if strings.HasPrefix(campo, "-") {
+				direccion = "DESC"
+				campo = strings.TrimPrefix(campo, "-")
+			}
+			columna, ok := campos[campo]
+			if !ok {
+				return p, fmt.Errorf("no se puede ordenar por el campo: %s", campo)
+			}
+			p.Orden = append(p.Orden, columna+" "+direccion)
+		}
+	}
+	if len(p.Orden) == 0 && ordenDefecto != "" {
+		p.Orden = []string{ordenDefecto}
+	}
+	p.Orden = desempatarPorID(p.Orden, campos["id"])
+
+	for campo, columna := range campos {
+		if v, ok := c.GetQuery(campo); ok && v != "" {
+			p.Filtros[columna] = v
+		}
+	}
+
+	return p, nil
+}
+
+// desempatarPorID agrega columnaID al final del orden, en la dirección de la última columna, si no está ya.
+// Con LIMIT/OFFSET, las filas que empatan en las columnas pedidas (ej: ?sort=anio) no tienen un orden fijo y se
+// podrían repetir u omitir entre páginas. Sin columnaID (no hay "id" en campos) se deja el orden como está.
+func desempatarPorID(orden []string, columnaID string) []string {
+	if columnaID == "" || len(orden) == 0 {
+		return orden
+	}
+	direccion := "ASC"
+	for _, expresion := range orden {
+		partes := strings.Fields(expresion)
+		if partes[0] == columnaID {
+			return orden
+		}
+		direccion = "ASC"
+		if len(partes) > 1 {
+			direccion = strings.ToUpper(partes[1])
+		}
+	}
+	return append(orden, columnaID+" "+direccion)
+}
+
+// URLConParametros reconstruye la URL del request actual reemplazando los parámetros indicados.
+func URLConParametros(c *gin.Context, parametros map[string]string) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+
+	query := c.Request.URL.Query()
+	for clave, valor := range parametros {
+		query.Set(clave, valor)
+	}
+
+	u := url.URL{
+		Scheme:   scheme,
+		Host:     c.Request.Host,
+		Path:     c.Request.URL.Path,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// EsModoCursor indica si el request pide paginación por keyset (?after= o ?limit=) en vez de ?page=.
+func EsModoCursor(c *gin.Context) bool {
+	_, after := c.GetQuery("after")
+	_, limit := c.GetQuery("limit")
+	return after || limit
+}
+
+// ParsearCursor lee ?after=, ?limit=, ?sort= y los filtros permitidos para la paginación por keyset.
+// En modo cursor solo se puede ordenar por id o created_at (ej: ?sort=-created_at).
+// Si se envía ?after=, el orden lo define el cursor y ?sort= se ignora.
+// - prefijo: tabla para calificar id/created_at cuando la consulta tiene JOINs, ej: "usuarios.".
+func ParsearCursor(c *gin.Context, campos map[string]string, prefijo string) (db.PaginacionCursor, error) {
+	p := db.PaginacionCursor{
+		Limite:      PorPaginaPorDefecto,
+		Descendente: true, // Mismo orden por defecto que el listado paginado (id DESC)
+		Prefijo:     prefijo,
+		Filtros:     map[string]string{},
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limite, err := strconv.Atoi(v)
+		if err != nil || limite < 1 {
+			return p, fmt.Errorf("parámetro limit inválido: %s", v)
+		}
+		if limite > PorPaginaMaximo {
+			limite = PorPaginaMaximo
+		}
+		p.Limite = limite
+	}
+
+	if v := c.Query("after"); v != "" {
+		cursor, err := DecodificarCursor(v)
+		if err != nil {
+			return p, err
+		}
+		p.Despues = cursor
+		p.PorFecha = cursor.PorFecha
+		p.Descendente = cursor.Descendente
+	} else if v := c.Query("sort"); v != "" {
+		switch v {
+		case "id", "-id", "created_at", "-created_at":
+			p.Descendente = strings.HasPrefix(v, "-")
+			p.PorFecha = strings.TrimPrefix(v, "-") == "created_at"
+		default:
+			return p, fmt.Errorf("en modo cursor solo se puede ordenar por id o created_at: %s", v)
+		}
+	}
+
+	for campo, columna := range campos {
+		if v, ok := c.GetQuery(campo); ok && v != "" {
+			p.Filtros[columna] = v
+		}
+	}
+
+	return p, nil
+}
+
+// CodificarCursor serializa el cursor como un token opaco seguro para URLs.
+func CodificarCursor(cursor *db.Cursor) string {
+	datos, _ := json.Marshal(cursor) // Un struct de tipos simples no falla al serializar
+	return base64.RawURLEncoding.EncodeToString(datos)
+}
+
+// DecodificarCursor revierte CodificarCursor.
+func DecodificarCursor(token string) (*db.Cursor, error) {
+	datos, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("cursor inválido")
+	}
+	var cursor db.Cursor
+	if err := json.Unmarshal(datos, &cursor); err != nil {
+		return nil, fmt.Errorf("cursor inválido")
+	}
+	return &cursor, nil
+}
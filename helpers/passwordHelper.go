@@ -0,0 +1,15 @@
+package helpers
+
+import "golang.org/x/crypto/bcrypt"
+
+// costoBcrypt es el costo usado para hashear contraseñas de usuarios.
+const costoBcrypt = 8
+
+// HashPassword genera el hash bcrypt que se guarda en usuarios.password.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), costoBcrypt)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
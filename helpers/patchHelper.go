@@ -0,0 +1,112 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/jgutierrez746/clase_7_gin_bun/dto"
+)
+
+// TamanoMaximoPatch es el tamaño máximo del cuerpo de un PATCH.
+const TamanoMaximoPatch = 1 << 20 // 1 MB
+
+// ErrorCampos son errores de validación ya convertidos en un mensaje por campo (ver ErroresValidacion).
+type ErrorCampos []string
+
+func (e ErrorCampos) Error() string {
+	return strings.Join(e, "; ")
+}
+
+func init() {
+	// El validador recibe el valor de los dto.Campo: nil si no vino o vino null (lo salta "omitnil"), o un puntero
+	// al valor para que las reglas se apliquen incluso a "" o 0
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterCustomTypeFunc(valorCampo[string], dto.Campo[string]{})
+		v.RegisterCustomTypeFunc(valorCampo[int], dto.Campo[int]{})
+		v.RegisterCustomTypeFunc(valorCampo[int64], dto.Campo[int64]{})
+	}
+}
+
+func valorCampo[T any](v reflect.Value) interface{} {
+	c := v.Interface().(dto.Campo[T])
+	if !c.Asignado() {
+		return (*T)(nil)
+	}
+	return &c.Valor
+}
+
+// ParsearPatch lee en patch (un DTO con campos dto.Campo) el cuerpo de un PATCH con JSON Merge Patch
+// (Content-Type application/merge-patch+json o application/json) y lo valida.
+// El cuerpo debe ser un objeto y solo puede traer los campos del DTO. Los errores de validación, incluido un
+// null en un campo que no acepta null, se retornan como ErrorCampos. Un cuerpo sobre TamanoMaximoPatch retorna
+// un *http.MaxBytesError.
+func ParsearPatch(c *gin.Context, patch interface{}) error {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, TamanoMaximoPatch)
+	cuerpo, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	if b := bytes.TrimSpace(cuerpo); len(b) == 0 || b[0] != '{' {
+		return errors.New("el cuerpo debe ser un objeto JSON")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(cuerpo))
+	dec.DisallowUnknownFields() // Ej: "slug" o "id", que no se pueden modificar
+	if err := dec.Decode(patch); err != nil {
+		return err
+	}
+
+	var mensajes ErrorCampos
+	recorrerPatch(patch, func(nombre, columna string, nulable bool, c dto.CampoPatch) {
+		if presente, nulo, _ := c.Patch(); presente && nulo && !nulable {
+			mensajes = append(mensajes, fmt.Sprintf("%s: no puede ser null", nombre))
+		}
+	})
+	mensajes = append(mensajes, ErroresValidacion(binding.Validator.ValidateStruct(patch))...)
+	if len(mensajes) > 0 {
+		return mensajes
+	}
+	return nil
+}
+
+// ColumnasPatch retorna las columnas a actualizar según los campos que vinieron en patch, con nil en las que
+// vinieron null. Si no vino ningún campo el mapa queda vacío.
+func ColumnasPatch(patch interface{}) map[string]interface{} {
+	columnas := map[string]interface{}{}
+	recorrerPatch(patch, func(nombre, columna string, nulable bool, c dto.CampoPatch) {
+		presente, nulo, valor := c.Patch()
+		switch {
+		case !presente:
+		case nulo:
+			columnas[columna] = nil
+		default:
+			columnas[columna] = valor
+		}
+	})
+	return columnas
+}
+
+// recorrerPatch llama a fn con cada campo dto.Campo de patch (un puntero a struct): su nombre en el JSON,
+// su columna y si acepta null.
+func recorrerPatch(patch interface{}, fn func(nombre, columna string, nulable bool, c dto.CampoPatch)) {
+	v := reflect.Indirect(reflect.ValueOf(patch))
+	for i := 0; i < v.NumField(); i++ {
+		c, ok := v.Field(i).Interface().(dto.CampoPatch)
+		if !ok {
+			continue
+		}
+		campo := v.Type().Field(i)
+		columna, opciones, _ := strings.Cut(campo.Tag.Get("bun"), ",")
+		nombre, _, _ := strings.Cut(campo.Tag.Get("json"), ",")
+		fn(nombre, columna, strings.Contains(opciones, "nullzero"), c)
+	}
+}
@@ -0,0 +1,107 @@
+package helpers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jgutierrez746/clase_7_gin_bun/dto"
+)
+
+// contextoPatch arma un gin.Context con un PATCH cuyo cuerpo es cuerpo.
+func contextoPatch(cuerpo string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(cuerpo))
+	c.Request.Header.Set("Content-Type", "application/merge-patch+json")
+	return c
+}
+
+func TestParsearPatchPelicula(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		cuerpo   string
+		columnas map[string]interface{}
+		errores  ErrorCampos // nil: sin error de validación
+	}{
+		{"vacío", `{}`, map[string]interface{}{}, nil},
+		{"solo un campo", `{"titulo": "Nuevo"}`, map[string]interface{}{"titulo": "Nuevo"}, nil},
+		{"null en columna que acepta null", `{"anio": null, "descripcion": null}`, map[string]interface{}{"anio": nil, "descripcion": nil}, nil},
+		{"valor y null", `{"titulo": "Nuevo", "descripcion": null}`, map[string]interface{}{"titulo": "Nuevo", "descripcion": nil}, nil},
+		{"null en columna que no acepta null", `{"titulo": null}`, nil, ErrorCampos{"titulo: no puede ser null"}},
+		{"omitnil no salta el valor vacío", `{"titulo": ""}`, nil, ErrorCampos{"titulo: debe cumplir min=1"}},
+		{"regla sobre el valor", `{"anio": 999}`, nil, ErrorCampos{"anio: debe cumplir min=1000"}},
+		{"varios errores", `{"titulo": null, "director": "", "anio": 10000}`, nil,
+			ErrorCampos{"titulo: no puede ser null", "anio: debe cumplir max=9999", "director: debe cumplir min=1"}},
+	}
+	for _, c := range casos {
+		var patch dto.PeliculaPatch
+		err := ParsearPatch(contextoPatch(c.cuerpo), &patch)
+		if c.errores != nil {
+			var campos ErrorCampos
+			if !errors.As(err, &campos) {
+				t.Errorf("%s: ParsearPatch = %v, se esperaba ErrorCampos", c.nombre, err)
+			} else if !reflect.DeepEqual(campos, c.errores) {
+				t.Errorf("%s: errores %q, se esperaba %q", c.nombre, campos, c.errores)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: ParsearPatch: %v", c.nombre, err)
+			continue
+		}
+		if got := ColumnasPatch(&patch); !reflect.DeepEqual(got, c.columnas) {
+			t.Errorf("%s: ColumnasPatch = %v, se esperaba %v", c.nombre, got, c.columnas)
+		}
+	}
+}
+
+func TestParsearPatchUsuario(t *testing.T) {
+	var patch dto.UsuarioPatch
+	if err := ParsearPatch(contextoPatch(`{"perfil_id": 2, "telefono": "+56 9 1234 5678"}`), &patch); err != nil {
+		t.Fatalf("ParsearPatch: %v", err)
+	}
+	esperadas := map[string]interface{}{"perfil_id": int64(2), "telefono": "+56 9 1234 5678"}
+	if got := ColumnasPatch(&patch); !reflect.DeepEqual(got, esperadas) {
+		t.Errorf("ColumnasPatch = %v, se esperaba %v", got, esperadas)
+	}
+
+	patch = dto.UsuarioPatch{}
+	err := ParsearPatch(contextoPatch(`{"correo": "no-es-correo", "perfil_id": 0}`), &patch)
+	if esperados := (ErrorCampos{"correo: debe cumplir email", "perfilid: debe cumplir min=1"}); !reflect.DeepEqual(err, esperados) {
+		t.Errorf("ParsearPatch = %v, se esperaba %q", err, esperados)
+	}
+}
+
+func TestParsearPatchCuerpoInvalido(t *testing.T) {
+	for _, cuerpo := range []string{
+		``,
+		`   `,
+		`null`,
+		`[]`,
+		`"titulo"`,
+		`{"titulo": "Nuevo"`,           // JSON cortado
+		`{"slug": "otro-slug"}`,        // Campo que no se puede modificar
+		`{"titulo": "Nuevo", "id": 5}`, // Idem
+		`{"anio": "1999"}`,             // Tipo incorrecto
+	} {
+		var patch dto.PeliculaPatch
+		err := ParsearPatch(contextoPatch(cuerpo), &patch)
+		var campos ErrorCampos
+		if err == nil || errors.As(err, &campos) {
+			t.Errorf("ParsearPatch(%q) = %v, se esperaba un error de formato", cuerpo, err)
+		}
+	}
+}
+
+func TestParsearPatchTamanoMaximo(t *testing.T) {
+	cuerpo := `{"descripcion": "` + strings.Repeat("a", TamanoMaximoPatch) + `"}`
+	var patch dto.PeliculaPatch
+	var maxBytes *http.MaxBytesError
+	if err := ParsearPatch(contextoPatch(cuerpo), &patch); !errors.As(err, &maxBytes) {
+		t.Errorf("ParsearPatch con un cuerpo de %d bytes = %v, se esperaba *http.MaxBytesError", len(cuerpo), err)
+	}
+}
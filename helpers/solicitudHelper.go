@@ -0,0 +1,36 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HeaderIDSolicitud identifica una solicitud en los logs y en la auditoría.
+const HeaderIDSolicitud = "X-Request-ID"
+
+// idSolicitudValido acepta el id que envía un proxy o cliente solo si es corto y sin caracteres raros.
+var idSolicitudValido = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// MiddlewareIDSolicitud asigna a cada solicitud un id: el del header X-Request-ID si viene uno válido, o uno
+// aleatorio. Se devuelve en el mismo header de la respuesta y se obtiene con IDSolicitud.
+func MiddlewareIDSolicitud() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(HeaderIDSolicitud)
+		if !idSolicitudValido.MatchString(id) {
+			b := make([]byte, 16)
+			rand.Read(b) // No falla en las plataformas soportadas
+			id = hex.EncodeToString(b)
+		}
+		c.Set("request_id", id)
+		c.Header(HeaderIDSolicitud, id)
+		c.Next()
+	}
+}
+
+// IDSolicitud retorna el id que MiddlewareIDSolicitud asignó a la solicitud, o "" si no pasó por él.
+func IDSolicitud(c *gin.Context) string {
+	return c.GetString("request_id")
+}
@@ -0,0 +1,47 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Validar aplica a obj las mismas reglas `binding:"..."` que ShouldBindJSON, para datos que no vienen del body
+// (ej: filas de un CSV). Retorna un mensaje por campo inválido, o nil si es válido.
+func Validar(obj interface{}) []string {
+	return ErroresValidacion(binding.Validator.ValidateStruct(obj))
+}
+
+// ErroresValidacion convierte el error del validador en mensajes legibles, ej: "anio: debe cumplir min=1000".
+func ErroresValidacion(err error) []string {
+	if err == nil {
+		return nil
+	}
+
+	var campos ErrorCampos
+	if errors.As(err, &campos) {
+		return campos
+	}
+
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return []string{err.Error()}
+	}
+
+	mensajes := make([]string, 0, len(errs))
+	for _, e := range errs {
+		campo := strings.ToLower(e.Field())
+		switch {
+		case e.Tag() == "required":
+			mensajes = append(mensajes, fmt.Sprintf("%s: es requerido", campo))
+		case e.Param() != "":
+			mensajes = append(mensajes, fmt.Sprintf("%s: debe cumplir %s=%s", campo, e.Tag(), e.Param()))
+		default:
+			mensajes = append(mensajes, fmt.Sprintf("%s: debe cumplir %s", campo, e.Tag()))
+		}
+	}
+	return mensajes
+}
@@ -0,0 +1,121 @@
+package helpers
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EscritorXLSX escribe una planilla XLSX de una sola hoja fila por fila, sin mantenerla en memoria.
+// Las celdas de texto van como inlineStr, así no hace falta la tabla de strings compartidos (que obliga a
+// conocer todos los textos antes de escribir la hoja).
+type EscritorXLSX struct {
+	zip  *zip.Writer
+	hoja *bufio.Writer
+	fila int
+}
+
+// Partes fijas del paquete: tipos de contenido, relaciones, libro con una hoja y un estilo de fecha (índice 1).
+var partesXLSX = []struct{ nombre, contenido string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`},
+	{"xl/styles.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts><fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders><cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs><cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs></styleSheet>`},
+}
+
+// NuevoEscritorXLSX escribe en w las partes fijas y el inicio de la hoja, que se llamará nombreHoja.
+func NuevoEscritorXLSX(w io.Writer, nombreHoja string) (*EscritorXLSX, error) {
+	z := zip.NewWriter(w)
+	for _, parte := range partesXLSX {
+		if err := escribirParteZip(z, parte.nombre, parte.contenido); err != nil {
+			return nil, err
+		}
+	}
+	libro := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="` +
+		escaparXML(nombreHoja) + `" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	if err := escribirParteZip(z, "xl/workbook.xml", libro); err != nil {
+		return nil, err
+	}
+
+	parte, err := z.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	hoja := bufio.NewWriter(parte)
+	hoja.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return &EscritorXLSX{zip: z, hoja: hoja}, nil
+}
+
+// Fila agrega una fila. Los enteros y decimales quedan como números, time.Time como fecha y el resto como texto.
+func (e *EscritorXLSX) Fila(celdas ...interface{}) error {
+	e.fila++
+	fmt.Fprintf(e.hoja, `<row r="%d">`, e.fila)
+	for i, celda := range celdas {
+		ref := columnaXLSX(i) + strconv.Itoa(e.fila)
+		switch v := celda.(type) {
+		case int:
+			fmt.Fprintf(e.hoja, `<c r="%s"><v>%d</v></c>`, ref, v)
+		case int64:
+			fmt.Fprintf(e.hoja, `<c r="%s"><v>%d</v></c>`, ref, v)
+		case float64:
+			fmt.Fprintf(e.hoja, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(v, 'f', -1, 64))
+		case time.Time:
+			fmt.Fprintf(e.hoja, `<c r="%s" s="1"><v>%s</v></c>`, ref, strconv.FormatFloat(serialFechaXLSX(v), 'f', -1, 64))
+		default:
+			fmt.Fprintf(e.hoja, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, escaparXML(fmt.Sprint(v)))
+		}
+	}
+	_, err := e.hoja.WriteString(`</row>`)
+	return err
+}
+
+// Cerrar termina la hoja y el zip. No cierra el io.Writer recibido.
+func (e *EscritorXLSX) Cerrar() error {
+	e.hoja.WriteString(`</sheetData></worksheet>`)
+	if err := e.hoja.Flush(); err != nil {
+		return err
+	}
+	return e.zip.Close()
+}
+
+func escribirParteZip(z *zip.Writer, nombre, contenido string) error {
+	w, err := z.Create(nombre)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, contenido)
+	return err
+}
+
+// escaparXML escapa el texto para XML. Los caracteres inválidos en XML 1.0 (ej: controles) quedan como U+FFFD.
+func escaparXML(texto string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(texto))
+	return b.String()
+}
+
+// columnaXLSX convierte un índice base 0 en la letra de la columna: 0 -> A, 25 -> Z, 26 -> AA.
+func columnaXLSX(i int) string {
+	letras := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		letras = string(rune('A'+(i-1)%26)) + letras
+	}
+	return letras
+}
+
+// serialFechaXLSX convierte la fecha (con su hora local) al número de días desde 1899-12-30 que usa Excel.
+func serialFechaXLSX(t time.Time) float64 {
+	local := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return local.Sub(time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)).Hours() / 24
+}
@@ -0,0 +1,217 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// clave es una clave de firma o verificación identificada por su kid.
+type clave struct {
+	kid     string
+	metodo  jwt.SigningMethod
+	firma   interface{} // Clave para firmar: []byte (HMAC), *rsa.PrivateKey o ed25519.PrivateKey. nil si solo verifica
+	publica interface{} // Clave para verificar: []byte (HMAC), *rsa.PublicKey o ed25519.PublicKey
+}
+
+var (
+	claveFirma         *clave            // Clave con la que se firman los tokens nuevos
+	clavesVerificacion map[string]*clave // kid -> clave, incluye la de firma y las anteriores en rotación
+)
+
+// CargarClaves lee la configuración de firma desde las variables de entorno. Debe llamarse después de cargar .env.
+//
+//   - JWT_PRIVATE_KEY_FILE: PEM con la clave privada RSA (RS256) o Ed25519 (EdDSA) para firmar.
+//     El kid es JWT_KID o, si no se define, el nombre del archivo sin extensión.
+//   - JWT_PUBLIC_KEY_FILES: PEMs de claves públicas anteriores separados por coma, que se siguen aceptando
+//     durante la rotación. El kid de cada una es el nombre del archivo sin extensión.
+//   - JWT_SECRET: secreto HMAC (HS256), solo si no se define JWT_PRIVATE_KEY_FILE.
+//
+// Si no hay ninguna clave configurada retorna error: ya no existe un secreto por defecto.
+func CargarClaves() error {
+	accessTTL = getDuracion("JWT_ACCESS_TTL", 15*time.Minute)
+	refreshTTL = getDuracion("JWT_REFRESH_TTL", 30*24*time.Hour)
+
+	clavesVerificacion = map[string]*clave{}
+
+	if archivo := os.Getenv("JWT_PRIVATE_KEY_FILE"); archivo != "" {
+		kid := os.Getenv("JWT_KID")
+		if kid == "" {
+			kid = kidDesdeArchivo(archivo)
+		}
+		k, err := leerClavePrivada(archivo, kid)
+		if err != nil {
+			return err
+		}
+		claveFirma = k
+	} else if secreto := os.Getenv("JWT_SECRET"); secreto != "" {
+		claveFirma = &clave{
+			kid:     os.Getenv("JWT_KID"), // Vacío: los tokens HMAC antiguos no traen kid
+			metodo:  jwt.SigningMethodHS256,
+			firma:   []byte(secreto),
+			publica: []byte(secreto),
+		}
+	} else {
+		return fmt.Errorf("debe definir JWT_PRIVATE_KEY_FILE o JWT_SECRET para firmar los tokens")
+	}
+	clavesVerificacion[claveFirma.kid] = claveFirma
+
+	for _, archivo := range strings.Split(os.Getenv("JWT_PUBLIC_KEY_FILES"), ",") {
+		archivo = strings.TrimSpace(archivo)
+		if archivo == "" {
+			continue
+		}
+		k, err := leerClavePublica(archivo, kidDesdeArchivo(archivo))
+		if err != nil {
+			return err
+		}
+		if _, existe := clavesVerificacion[k.kid]; existe {
+			return fmt.Errorf("kid duplicado en las claves JWT: %s", k.kid)
+		}
+		clavesVerificacion[k.kid] = k
+	}
+
+	log.Printf("Claves JWT cargadas: firma %s (kid %q), %d claves de verificación", claveFirma.metodo.Alg(), claveFirma.kid, len(clavesVerificacion))
+	return nil
+}
+
+// kidDesdeArchivo usa el nombre del archivo sin extensiones como kid, ej: "keys/2026-10.pub.pem" -> "2026-10".
+func kidDesdeArchivo(archivo string) string {
+	base := filepath.Base(archivo)
+	if i := strings.Index(base, "."); i > 0 {
+		return base[:i]
+	}
+	return base
+}
+
+func leerPEM(archivo string) (*pem.Block, error) {
+	datos, err := os.ReadFile(archivo)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo clave %s: %w", archivo, err)
+	}
+	bloque, _ := pem.Decode(datos)
+	if bloque == nil {
+		return nil, fmt.Errorf("el archivo %s no contiene un bloque PEM", archivo)
+	}
+	return bloque, nil
+}
+
+// leerClavePrivada carga una clave privada PKCS#8 (RSA o Ed25519) o PKCS#1 (RSA).
+func leerClavePrivada(archivo, kid string) (*clave, error) {
+	bloque, err := leerPEM(archivo)
+	if err != nil {
+		return nil, err
+	}
+
+	var privada interface{}
+	if privada, err = x509.ParsePKCS8PrivateKey(bloque.Bytes); err != nil {
+		if privada, err = x509.ParsePKCS1PrivateKey(bloque.Bytes); err != nil {
+			return nil, fmt.Errorf("clave privada no soportada en %s: %w", archivo, err)
+		}
+	}
+
+	switch k := privada.(type) {
+	case *rsa.PrivateKey:
+		return &clave{kid: kid, metodo: jwt.SigningMethodRS256, firma: k, publica: &k.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &clave{kid: kid, metodo: jwt.SigningMethodEdDSA, firma: k, publica: k.Public()}, nil
+	default:
+		return nil, fmt.Errorf("tipo de clave privada no soportado en %s: %T", archivo, privada)
+	}
+}
+
+// leerClavePublica carga una clave pública PKIX (RSA o Ed25519) o PKCS#1 (RSA).
+func leerClavePublica(archivo, kid string) (*clave, error) {
+	bloque, err := leerPEM(archivo)
+	if err != nil {
+		return nil, err
+	}
+
+	var publica interface{}
+	if publica, err = x509.ParsePKIXPublicKey(bloque.Bytes); err != nil {
+		if publica, err = x509.ParsePKCS1PublicKey(bloque.Bytes); err != nil {
+			return nil, fmt.Errorf("clave pública no soportada en %s: %w", archivo, err)
+		}
+	}
+
+	switch k := publica.(type) {
+	case *rsa.PublicKey:
+		return &clave{kid: kid, metodo: jwt.SigningMethodRS256, publica: k}, nil
+	case ed25519.PublicKey:
+		return &clave{kid: kid, metodo: jwt.SigningMethodEdDSA, publica: k}, nil
+	default:
+		return nil, fmt.Errorf("tipo de clave pública no soportado en %s: %T", archivo, publica)
+	}
+}
+
+// claveParaToken es el keyfunc de ValidateToken: elige la clave por kid y exige que el alg coincida.
+func claveParaToken(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	k, ok := clavesVerificacion[kid]
+	if !ok {
+		return nil, fmt.Errorf("kid desconocido: %q", kid)
+	}
+	if token.Method.Alg() != k.metodo.Alg() {
+		return nil, fmt.Errorf("método de firma inesperado: %v", token.Header["alg"])
+	}
+	return k.publica, nil
+}
+
+// jwk es una clave pública en formato JSON Web Key (RFC 7517).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`   // RSA: módulo
+	E   string `json:"e,omitempty"`   // RSA: exponente
+	Crv string `json:"crv,omitempty"` // OKP: curva
+	X   string `json:"x,omitempty"`   // OKP: clave pública
+}
+
+// JWKS responde GET /.well-known/jwks.json con las claves públicas de verificación,
+// para que otros servicios validen los tokens sin compartir un secreto. Las claves HMAC no se publican.
+func JWKS(c *gin.Context) {
+	claves := []jwk{}
+	for _, k := range clavesVerificacion {
+		if j, ok := aJWK(k); ok {
+			claves = append(claves, j)
+		}
+	}
+	sort.Slice(claves, func(i, j int) bool { return claves[i].Kid < claves[j].Kid })
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{
+		"keys": claves,
+	})
+}
+
+func aJWK(k *clave) (jwk, bool) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch pub := k.publica.(type) {
+	case *rsa.PublicKey:
+		return jwk{
+			Kty: "RSA", Kid: k.kid, Alg: k.metodo.Alg(), Use: "sig",
+			N: b64(pub.N.Bytes()),
+			E: b64(big.NewInt(int64(pub.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return jwk{Kty: "OKP", Kid: k.kid, Alg: k.metodo.Alg(), Use: "sig", Crv: "Ed25519", X: b64(pub)}, true
+	default:
+		return jwk{}, false
+	}
+}
@@ -9,35 +9,58 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/jgutierrez746/clase_7_gin_bun/respuesta"
 )
 
-var secretKey = []byte(getSecret())
+// Duración de los tokens, configurable con JWT_ACCESS_TTL y JWT_REFRESH_TTL (formato time.ParseDuration, ej: "15m").
+// Se asignan en CargarClaves.
+var (
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+)
 
-func getSecret() string {
-	s := os.Getenv("JWT_SECRET")
-	if s == "" {
-		return "secreto_por_defecto_muy_seguro"
+func getDuracion(variable string, defecto time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(variable))
+	if err != nil || d <= 0 {
+		return defecto
 	}
-	return s
+	return d
+}
+
+// AccessTTL retorna la duración de los access tokens (para informar expires_in al cliente).
+func AccessTTL() time.Duration {
+	return accessTTL
 }
 
+// GenerateToken genera un access token de corta duración con un jti único, que permite revocarlo en logout.
 func GenerateToken(userID int64, perfilID int64) (string, error) {
+	jti, err := tokenAleatorio(16)
+	if err != nil {
+		return "", err
+	}
+
+	ahora := time.Now()
 	claims := jwt.MapClaims{
 		"user_id":   userID,
 		"perfil_id": perfilID,
-		"exp":       time.Now().Add(time.Hour * 24).Unix(), // 24 horas
+		"jti":       jti,
+		"iat":       ahora.Unix(),
+		"exp":       ahora.Add(accessTTL).Unix(),
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(secretKey)
+	if claveFirma == nil {
+		return "", fmt.Errorf("claves JWT no cargadas") // Se debe llamar a CargarClaves primero si este error ocurre.
+	}
+
+	token := jwt.NewWithClaims(claveFirma.metodo, claims)
+	if claveFirma.kid != "" {
+		token.Header["kid"] = claveFirma.kid
+	}
+	return token.SignedString(claveFirma.firma)
 }
 
+// ValidateToken verifica la firma con la clave del kid del header (ver CargarClaves) y la expiración.
 func ValidateToken(tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("método de firma inesperado: %v", token.Header["alg"])
-		}
-		return secretKey, nil
-	})
+	token, err := jwt.Parse(tokenString, claveParaToken, jwt.WithValidMethods([]string{"HS256", "RS256", "EdDSA"}))
 
 	if err != nil {
 		return nil, err
@@ -53,48 +76,40 @@ func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header requerido"})
-			c.Abort()
+			respuesta.Error(c, http.StatusUnauthorized, respuesta.CodigoNoAutenticado, "Authorization header requerido")
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Formato de token inválido (Bearer <token>)"})
-			c.Abort()
+			respuesta.Error(c, http.StatusUnauthorized, respuesta.CodigoNoAutenticado, "Formato de token inválido (Bearer <token>)")
 			return
 		}
 
 		claims, err := ValidateToken(parts[1])
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido o expirado"})
-			c.Abort()
+			respuesta.Error(c, http.StatusUnauthorized, respuesta.CodigoNoAutenticado, "Token inválido o expirado")
 			return
 		}
 
+		// Verificar que el token no haya sido revocado (logout)
+		jti, _ := claims["jti"].(string)
+		if jti != "" {
+			revocado, err := EstaRevocado(c.Request.Context(), jti)
+			if err != nil {
+				respuesta.Error(c, http.StatusInternalServerError, respuesta.CodigoErrorInterno, "Error verificando token")
+				return
+			}
+			if revocado {
+				respuesta.Error(c, http.StatusUnauthorized, respuesta.CodigoNoAutenticado, "Token revocado")
+				return
+			}
+		}
+
 		// Setear variables en contexto
 		c.Set("user_id", claims["user_id"])
 		c.Set("perfil_id", claims["perfil_id"])
-		c.Next()
-	}
-}
-
-func AdminMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		perfilID, exists := c.Get("perfil_id")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "No autenticado"})
-			c.Abort()
-			return
-		}
-
-		// Convertir a float64 (JWT usa float para números JSON)
-		pID, ok := perfilID.(float64)
-		if !ok || int64(pID) != 1 { // Asumiendo ID 1 es Admin
-			c.JSON(http.StatusForbidden, gin.H{"error": "Acceso denegado: Se requieren permisos de administrador"})
-			c.Abort()
-			return
-		}
+		c.Set("jti", jti)
 		c.Next()
 	}
 }
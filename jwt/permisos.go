@@ -0,0 +1,153 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jgutierrez746/clase_7_gin_bun/config"
+	"github.com/jgutierrez746/clase_7_gin_bun/db"
+	"github.com/jgutierrez746/clase_7_gin_bun/dto"
+	"github.com/jgutierrez746/clase_7_gin_bun/respuesta"
+	"github.com/uptrace/bun"
+)
+
+// PerfilDesdeContexto retorna el perfil_id que AuthMiddleware dejó en el contexto.
+func PerfilDesdeContexto(c *gin.Context) (int64, bool) {
+	perfilID, exists := c.Get("perfil_id")
+	if !exists {
+		return 0, false
+	}
+
+	// Convertir a float64 (JWT usa float para números JSON)
+	pID, ok := perfilID.(float64)
+	if !ok {
+		return 0, false
+	}
+	return int64(pID), true
+}
+
+// UsuarioDesdeContexto retorna el user_id que AuthMiddleware dejó en el contexto.
+func UsuarioDesdeContexto(c *gin.Context) (int64, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return 0, false
+	}
+
+	uID, ok := userID.(float64)
+	if !ok {
+		return 0, false
+	}
+	return int64(uID), true
+}
+
+// TienePermiso indica si el perfil tiene otorgado el permiso con el código indicado.
+func TienePermiso(ctx context.Context, perfilID int64, codigo string) (bool, error) {
+	pm := config.Tablas["pm"]
+	pfp := config.Tablas["pfp"]
+
+	var filas []dto.PermisoSelectDTO
+	joins := []string{fmt.Sprintf("JOIN %s ON %s.permiso_id = %s.id", pfp, pfp, pm)}
+	columnas := []string{pm + ".id"}
+	where := fmt.Sprintf("%s.perfil_id = ? AND %s.codigo = ?", pfp, pm)
+
+	if err := db.SelectConJoin(ctx, pm, joins, columnas, &filas, "", where, perfilID, codigo); err != nil {
+		return false, err
+	}
+	return len(filas) > 0, nil
+}
+
+// RequirePermission permite continuar solo si el perfil del token tiene el permiso indicado.
+// Debe ir después de AuthMiddleware. Ej: group.POST("", RequirePermission("peliculas:write"), handler)
+func RequirePermission(codigo string) gin.HandlerFunc {
+	if _, ok := config.Permisos[codigo]; !ok {
+		panic("RequirePermission: permiso no definido en config.Permisos: " + codigo) // Error de programación, falla al iniciar
+	}
+
+	return func(c *gin.Context) {
+		perfilID, ok := PerfilDesdeContexto(c)
+		if !ok {
+			respuesta.Error(c, http.StatusUnauthorized, respuesta.CodigoNoAutenticado, "No autenticado")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		permitido, err := TienePermiso(ctx, perfilID, codigo)
+		if err != nil {
+			log.Println("Error verificando permiso:", err)
+			respuesta.Error(c, http.StatusInternalServerError, respuesta.CodigoErrorInterno, "Error verificando permisos")
+			return
+		}
+		if !permitido {
+			respuesta.Error(c, http.StatusForbidden, respuesta.CodigoSinPermiso, "Acceso denegado: se requiere el permiso "+codigo)
+			return
+		}
+		c.Next()
+	}
+}
+
+// OtorgarPermisos asigna permisos del catálogo a un perfil sobre idb (DB o una transacción). Los que ya tenía se
+// omiten. Retorna los códigos efectivamente otorgados, o la lista de códigos inexistentes (sin otorgar nada) si
+// alguno no está en la tabla permisos. Para otorgar en paralelo al mismo perfil, bloquear antes su fila.
+func OtorgarPermisos(ctx context.Context, idb bun.IDB, perfilID int64, codigos []string) ([]string, []string, error) {
+	var permisos dto.PermisosAllSelect
+	if err := db.SelectTx(ctx, idb, config.Tablas["pm"], &permisos, "", "codigo IN (?)", bun.In(codigos)); err != nil {
+		return nil, nil, err
+	}
+
+	encontrados := make(map[string]int64, len(permisos))
+	for _, p := range permisos {
+		encontrados[p.Codigo] = p.ID
+	}
+	var desconocidos []string
+	for _, codigo := range codigos {
+		if _, ok := encontrados[codigo]; !ok {
+			desconocidos = append(desconocidos, codigo)
+		}
+	}
+	if len(desconocidos) > 0 {
+		return nil, desconocidos, nil
+	}
+
+	var actuales []dto.PerfilPermisoInsert
+	if err := db.SelectTx(ctx, idb, config.Tablas["pfp"], &actuales, "", "perfil_id = ?", perfilID); err != nil {
+		return nil, nil, err
+	}
+	tenia := make(map[int64]bool, len(actuales))
+	for _, a := range actuales {
+		tenia[a.PermisoID] = true
+	}
+
+	nowChile := time.Now().In(config.Chilelocation)
+	var otorgados []string
+	var asignaciones []dto.PerfilPermisoInsert
+	for _, codigo := range codigos {
+		id := encontrados[codigo]
+		if tenia[id] {
+			continue
+		}
+		tenia[id] = true // Un código repetido en la lista se otorga una vez
+		otorgados = append(otorgados, codigo)
+		asignaciones = append(asignaciones, dto.PerfilPermisoInsert{PerfilID: perfilID, PermisoID: id, CreatedAt: nowChile})
+	}
+	if len(asignaciones) == 0 {
+		return nil, nil, nil
+	}
+
+	if _, err := db.InsertBatchTx(ctx, idb, config.Tablas["pfp"], asignaciones); err != nil {
+		return nil, nil, err
+	}
+	return otorgados, nil, nil
+}
+
+// RevocarPermiso quita un permiso a un perfil sobre idb (DB o una transacción). Retorna las filas eliminadas
+// (0 si no lo tenía).
+func RevocarPermiso(ctx context.Context, idb bun.IDB, perfilID int64, codigo string) (int64, error) {
+	where := fmt.Sprintf("perfil_id = ? AND permiso_id IN (SELECT id FROM %s WHERE codigo = ?)", config.Tablas["pm"])
+	return db.DeleteTx(ctx, idb, config.Tablas["pfp"], where, perfilID, codigo)
+}
@@ -0,0 +1,139 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jgutierrez746/clase_7_gin_bun/config"
+	"github.com/jgutierrez746/clase_7_gin_bun/db"
+	"github.com/jgutierrez746/clase_7_gin_bun/modelos"
+)
+
+// ErrRefreshInvalido se retorna cuando el refresh token no existe, expiró o ya fue usado.
+var ErrRefreshInvalido = errors.New("refresh token inválido o expirado")
+
+// tokenAleatorio genera n bytes aleatorios codificados en base64 URL.
+func tokenAleatorio(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generando token aleatorio: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken retorna el SHA-256 en hex del refresh token, que es lo único que se guarda en BD.
+func hashToken(token string) string {
+	suma := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(suma[:])
+}
+
+// GenerarRefreshToken crea y guarda un refresh token opaco para el usuario.
+func GenerarRefreshToken(ctx context.Context, userID int64) (string, error) {
+	token, err := tokenAleatorio(32)
+	if err != nil {
+		return "", err
+	}
+
+	ahora := time.Now().In(config.Chilelocation)
+	registro := modelos.RefreshTokenModel{
+		UsuarioID: userID,
+		TokenHash: hashToken(token),
+		ExpiresAt: ahora.Add(refreshTTL),
+		CreatedAt: ahora,
+	}
+	if err := db.Insert(ctx, config.Tablas["rt"], &registro); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RotarRefreshToken revoca el refresh token recibido y retorna el usuario dueño para emitir uno nuevo.
+// Si el token ya estaba revocado se asume que fue robado y reutilizado, y se revocan todos los del usuario.
+func RotarRefreshToken(ctx context.Context, token string) (int64, error) {
+	var registro modelos.RefreshTokenModel
+	if err := db.SelectOne(ctx, config.Tablas["rt"], &registro, "token_hash = ?", hashToken(token)); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrRefreshInvalido
+		}
+		return 0, err
+	}
+
+	ahora := time.Now().In(config.Chilelocation)
+	if !registro.RevokedAt.IsZero() {
+		log.Printf("Reutilización de refresh token detectada para usuario %d, se revocan todas sus sesiones", registro.UsuarioID)
+		if err := RevocarRefreshTokensUsuario(ctx, registro.UsuarioID); err != nil {
+			return 0, err
+		}
+		return 0, ErrRefreshInvalido
+	}
+	if ahora.After(registro.ExpiresAt) {
+		return 0, ErrRefreshInvalido
+	}
+
+	// Revocar solo si sigue vigente, así dos refresh concurrentes con el mismo token no pasan ambos
+	filas, err := revocarRefresh(ctx, ahora, "id = ? AND revoked_at IS NULL", registro.ID)
+	if err != nil {
+		return 0, err
+	}
+	if filas == 0 {
+		return 0, ErrRefreshInvalido
+	}
+	return registro.UsuarioID, nil
+}
+
+// RevocarRefreshToken revoca un refresh token (logout). No falla si el token no existe.
+func RevocarRefreshToken(ctx context.Context, token string) error {
+	_, err := revocarRefresh(ctx, time.Now().In(config.Chilelocation), "token_hash = ? AND revoked_at IS NULL", hashToken(token))
+	return err
+}
+
+// RevocarRefreshTokensUsuario revoca todas las sesiones de un usuario (ej: cambio de contraseña).
+func RevocarRefreshTokensUsuario(ctx context.Context, userID int64) error {
+	_, err := revocarRefresh(ctx, time.Now().In(config.Chilelocation), "usuario_id = ? AND revoked_at IS NULL", userID)
+	return err
+}
+
+// refreshRevocado actualiza solo la columna revoked_at.
+type refreshRevocado struct {
+	RevokedAt time.Time `bun:"revoked_at"`
+}
+
+func revocarRefresh(ctx context.Context, ahora time.Time, where string, args ...interface{}) (int64, error) {
+	revocado := refreshRevocado{RevokedAt: ahora}
+	return db.Update(ctx, config.Tablas["rt"], &revocado, where, args...)
+}
+
+// RevocarAccessToken agrega el jti a la lista de tokens revocados hasta su expiración.
+// Aprovecha de borrar las filas de tokens que ya expiraron por sí solos.
+func RevocarAccessToken(ctx context.Context, jti string, expira time.Time) error {
+	// INSERT IGNORE: revocar dos veces el mismo token (ej: logout repetido) no es un error
+	registro := []modelos.TokenRevocadoModel{{JTI: jti, ExpiresAt: expira.In(config.Chilelocation)}}
+	if _, err := db.InsertBatchIgnorar(ctx, config.Tablas["tr"], registro); err != nil {
+		return err
+	}
+
+	if _, err := db.Delete(ctx, config.Tablas["tr"], "expires_at < ?", time.Now().In(config.Chilelocation)); err != nil {
+		log.Printf("Error limpiando tokens revocados expirados: %v", err)
+	}
+	return nil
+}
+
+// EstaRevocado indica si el jti del access token está en la lista de revocados.
+func EstaRevocado(ctx context.Context, jti string) (bool, error) {
+	var registro modelos.TokenRevocadoModel
+	if err := db.SelectOne(ctx, config.Tablas["tr"], &registro, "jti = ?", jti); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
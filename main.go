@@ -4,18 +4,25 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strconv"
 
-	"github.com/gin-gonic/gin"
 	"github.com/jgutierrez746/clase_7_gin_bun/config"
 	"github.com/jgutierrez746/clase_7_gin_bun/db"
-	auth "github.com/jgutierrez746/clase_7_gin_bun/jwt"
-	"github.com/jgutierrez746/clase_7_gin_bun/rutas"
 	"github.com/joho/godotenv"
 )
 
 var prefijo = "/api/v1"
 
+const uso = `Uso: %s <comando> [opciones]
+
+Comandos:
+  serve                                   Inicia el servidor HTTP (comando por defecto)
+  migrate up|down|status                  Aplica, revierte la última o lista las migraciones
+  seed                                    Carga temáticas y películas de ejemplo
+  create-admin --correo X --password Y    Crea un usuario administrador (perfil 1, con todos los permisos)
+  reconcile [--fix] [--min-age 1h]        Busca portadas huérfanas o sin archivo; con --fix las corrige
+  purge [--retention 720h]                Borra definitivamente lo que lleva en la papelera más que la retención
+`
+
 func main() {
 	// Carga Zona horaria Chile
 	config.Init()
@@ -25,13 +32,50 @@ func main() {
 		log.Println("No se encontró .env, usando valores por defecto")
 	}
 
-	// Obtener puerto de .env o default 8085
-	portStr := os.Getenv("PORT")
-	port, err := strconv.Atoi(portStr)
-	if err != nil || port == 0 {
-		port = 8085
+	// Sin argumentos se inicia el servidor, igual que antes de existir los subcomandos
+	comando := "serve"
+	var args []string
+	if len(os.Args) > 1 {
+		comando = os.Args[1]
+		args = os.Args[2:]
 	}
 
+	switch comando {
+	case "serve", "migrate", "seed", "create-admin", "reconcile", "purge":
+	case "help", "-h", "--help":
+		fmt.Printf(uso, os.Args[0])
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Comando desconocido: %s\n\n", comando)
+		fmt.Fprintf(os.Stderr, uso, os.Args[0])
+		os.Exit(2)
+	}
+
+	conectarDB()
+
+	// migrate trabaja con el esquema anterior a la columna deleted_at; serve registra las tablas después de migrar
+	if comando != "migrate" && comando != "serve" {
+		registrarBorradoLogico()
+	}
+
+	switch comando {
+	case "serve":
+		servir()
+	case "migrate":
+		comandoMigrate(args)
+	case "seed":
+		comandoSeed()
+	case "create-admin":
+		comandoCreateAdmin(args)
+	case "reconcile":
+		comandoReconcile(args)
+	case "purge":
+		comandoPurge(args)
+	}
+}
+
+// conectarDB arma el DSN desde las variables de entorno e inicializa db.DB.
+func conectarDB() {
 	dbName := os.Getenv("DB_NAME")
 	dbUser := os.Getenv("DB_USER")
 	dbPassword := os.Getenv("DB_PASSWORD")
@@ -59,139 +103,10 @@ func main() {
 	if err := db.InitDB(dsn); err != nil {
 		log.Fatal("Error initDB: ", err)
 	}
+}
 
-	// Crear tablas en BDD
-	/*
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		if err := db.CreateTable(ctx, &modelos.TematicasModel{}); err != nil {
-			log.Fatal(err)
-		}
-		if err := db.CreateTable(ctx, &modelos.PeliculasModel{}); err != nil {
-			log.Fatal(err)
-		}
-		if err := db.CreateTable(ctx, &modelos.PeliculaTematicaModel{}); err != nil {
-			log.Fatal(err)
-		}
-		if err := db.CreateTable(ctx, &modelos.PortadaPeliculaModel{}); err != nil {
-			log.Fatal(err)
-		}
-		if err := db.CreateTable(ctx, &modelos.PerfilesModel{}); err != nil {
-			log.Fatal(err)
-		}
-		if err := db.CreateTable(ctx, &modelos.UsuariosModel{}); err != nil {
-			log.Fatal(err)
-		}
-	*/
-
-	// Agregar FKs individuales (genérico: llama tantas como necesites)
-	/*
-		if err := db.AgregarFK(ctx, config.Tablas["pt"], "p_id", config.Tablas["pl"], "id", "CASCADE"); err != nil {
-			panic(err)
-		}
-		if err := db.AgregarFK(ctx, config.Tablas["pt"], "tematica_id", config.Tablas["tm"], "id", "CASCADE"); err != nil {
-			panic(err)
-		}
-		if err := db.AgregarFK(ctx, config.Tablas["u"], "perfil_id", config.Tablas["p"], "id", ""); err != nil {
-			panic(err)
-		}
-	*/
-
-	// Configurar Gin en modo release (sin logs verbose)
-	gin.SetMode(gin.ReleaseMode)
-
-	// Crear router
-	router := gin.Default()
-
-	// Definición de Rutas HTTP
-	// Ruta para archivos estaticos
-	router.Static("/fotos", "./public/upload/fotos")
-	router.Static("/imagenes", "./public/upload/portadas")
-
-	// Grupo prefijo
-	apiV1 := router.Group(prefijo)
-	{
-		apiV1.POST("/login", rutas.Login) // Ruta publica
-
-		// Grupo protegido general
-		protected := apiV1.Group("/")
-		protected.Use(auth.AuthMiddleware()) // Middleware de autenticación global para estos grupos
-		{
-
-			tematicasGroup := protected.Group("/tematicas")
-			{
-				tematicasGroup.GET("", rutas.ConsultarTematicas)
-				tematicasGroup.GET("/:id", rutas.ConsultarTematicasPorId)
-				tematicasGroup.POST("", rutas.CrearTematica)
-				tematicasGroup.PUT("/:id", rutas.EditarTematica)
-				tematicasGroup.DELETE("/:id", rutas.EliminarTematica)
-			}
-
-			peliculasGroup := protected.Group("/peliculas")
-			{
-				peliculasGroup.GET("", rutas.ConsultarPeliculas)
-				peliculasGroup.GET("/:id", rutas.ConsultarPeliculaPorId)
-				peliculasGroup.POST("", rutas.CrearPelicula)
-				peliculasGroup.PUT("/:id", rutas.EditarPelicula)
-				peliculasGroup.DELETE("/:id", rutas.EliminarPelicula)
-
-				tematicasPeliculaGroup := peliculasGroup.Group("/:id/tematicas")
-				{
-					tematicasPeliculaGroup.GET("", rutas.ConsultarTematicasPelicula)
-					tematicasPeliculaGroup.POST("", rutas.CrearTematicasPelicula)
-					tematicasPeliculaGroup.DELETE("/:idt", rutas.EliminarTematicaPelicula)
-				}
-
-				portadaPeliculaGroup := peliculasGroup.Group("/:id/portada")
-				{
-					portadaPeliculaGroup.GET("", rutas.ConsultarPortadasPelicula)
-					portadaPeliculaGroup.POST("", rutas.CrearPortada)
-					portadaPeliculaGroup.DELETE("/:idf", rutas.EliminarPortada)
-				}
-			}
-
-			// Grupo Admin
-			adminGroup := protected.Group("/")
-			adminGroup.Use(auth.AdminMiddleware())
-			{
-				perfilesGroup := adminGroup.Group("/perfiles")
-				{
-					perfilesGroup.GET("", rutas.ConsultarPerfiles)
-					perfilesGroup.GET("/:id", rutas.ConsultarPerfilPorId)
-					perfilesGroup.POST("", rutas.CrearPerfil)
-					perfilesGroup.PUT("/:id", rutas.EditarPerfil)
-					perfilesGroup.DELETE("/:id", rutas.EliminarPerfil)
-				}
-
-				usuariosGroup := adminGroup.Group("/usuarios")
-				{
-					usuariosGroup.GET("", rutas.ConsultarUsuarios)
-					usuariosGroup.GET("/:id", rutas.ConsultarUsuarioPorId)
-					usuariosGroup.POST("", rutas.CrearUsuario)
-					usuariosGroup.PUT("/:id", rutas.EditarUsuario)
-					usuariosGroup.DELETE("/:id", rutas.EliminarUsuario)
-				}
-			}
-		}
-		/*
-			// Grupo 1 users
-			usersGroup := apiV1.Group("/users")
-			{
-				usersGroup.GET("", rutas.GetUsers)    // GET /api/v1/users
-				usersGroup.POST("", rutas.CreateUser) // POST /api/v1/users
-			}
-
-			// Grupo 2 admin con middleware
-			adminGroup := apiV1.Group("/admin", adminMiddleware)
-			{
-				adminGroup.GET("/dashboard", rutas.AdminOnly) // GET /admin/dashboard
-			}
-		*/
-	}
-
-	// Iniciar servidor
-	fmt.Printf("servidor iniciado en http://localhost:%d\n", port)
-	if err := router.Run(fmt.Sprintf(":%d", port)); err != nil {
-		log.Fatal("Error al iniciar el servidor: ", err)
-	}
+// registrarBorradoLogico hace que las consultas de db excluyan lo que está en la papelera. Se llama solo con el
+// esquema migrado: antes de la migración 11 las tablas no tienen deleted_at.
+func registrarBorradoLogico() {
+	db.RegistrarBorradoLogico(config.Tablas["pl"], config.Tablas["tm"], config.Tablas["u"])
 }
@@ -0,0 +1,138 @@
+package migraciones
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Copias de los modelos tal como eran al publicarse la migración que crea su tabla. Los structs de modelos siguen
+// cambiando (ej: nuevas columnas que agrega otra migración); si una migración los usara, una instalación nueva y
+// una actualizada terminarían con esquemas distintos. Estos structs no se modifican nunca.
+
+// Versión 1: tablas_iniciales
+
+type tematicasV1 struct {
+	bun.BaseModel `bun:"table:tematicas"`
+
+	ID        int64     `bun:",pk,autoincrement"`
+	Nombre    string    `bun:",type:varchar(100),notnull"`
+	Slug      string    `bun:",type:varchar(100),notnull,unique"`
+	CreatedAt time.Time `bun:",type:timestamp,default:current_timestamp"`
+	UpdatedAt time.Time `bun:",type:timestamp,default:current_timestamp,on_update:current_timestamp"`
+}
+
+type peliculasV1 struct {
+	bun.BaseModel `bun:"table:peliculas"`
+
+	ID          int64     `bun:",pk,autoincrement"`
+	Anio        int       `bun:",type:smallint,nullzero"`
+	Titulo      string    `bun:",type:varchar(255),notnull"`
+	Slug        string    `bun:",type:varchar(255),notnull,unique"`
+	Descripcion string    `bun:",type:text"`
+	Director    string    `bun:",type:varchar(100),notnull"`
+	CreatedAt   time.Time `bun:",type:timestamp,default:current_timestamp"`
+	UpdatedAt   time.Time `bun:",type:timestamp,default:current_timestamp,on_update:current_timestamp"`
+}
+
+type peliculaTematicaV1 struct {
+	bun.BaseModel `bun:"table:pelicula_tematicas"`
+
+	PID        int64     `bun:"p_id,pk"`
+	TematicaID int64     `bun:"tematica_id,pk"`
+	Orden      int       `bun:",nullzero"`
+	CreatedAt  time.Time `bun:",type:timestamp,default:current_timestamp"`
+}
+
+type portadaPeliculaV1 struct {
+	bun.BaseModel `bun:"table:portada_pelicula"`
+
+	ID            int64     `bun:",pk,autoincrement"`
+	PID           int64     `bun:"p_id"`
+	NombreArchivo string    `bun:"nombre_archivo"`
+	CreatedAt     time.Time `bun:",type:timestamp,default:current_timestamp"`
+}
+
+type perfilesV1 struct {
+	bun.BaseModel `bun:"table:perfiles"`
+
+	ID     int64  `bun:",pk,autoincrement"`
+	Nombre string `bun:"nombre,notnull"`
+}
+
+type usuariosV1 struct {
+	bun.BaseModel `bun:"table:usuarios"`
+
+	ID       int64  `bun:",pk,autoincrement"`
+	Nombre   string `bun:"nombre,notnull"`
+	Correo   string `bun:"correo,notnull"`
+	Telefono string `bun:"telefono,notnull"`
+	Password string `bun:"password,notnull"`
+	PerfilID int64  `bun:"perfil_id,notnull"`
+}
+
+// Versión 5: refresh_tokens_y_revocados
+
+type refreshTokenV5 struct {
+	bun.BaseModel `bun:"table:refresh_tokens"`
+
+	ID        int64     `bun:",pk,autoincrement"`
+	UsuarioID int64     `bun:"usuario_id,notnull"`
+	TokenHash string    `bun:",type:char(64),notnull,unique"`
+	ExpiresAt time.Time `bun:",type:timestamp,notnull"`
+	RevokedAt time.Time `bun:",type:timestamp,nullzero"`
+	CreatedAt time.Time `bun:",type:timestamp,default:current_timestamp"`
+}
+
+type tokenRevocadoV5 struct {
+	bun.BaseModel `bun:"table:tokens_revocados"`
+
+	JTI       string    `bun:"jti,pk,type:varchar(64)"`
+	ExpiresAt time.Time `bun:",type:timestamp,notnull"`
+}
+
+// Versión 6: permisos_por_perfil
+
+type permisoV6 struct {
+	bun.BaseModel `bun:"table:permisos"`
+
+	ID          int64  `bun:",pk,autoincrement"`
+	Codigo      string `bun:",type:varchar(100),notnull,unique"`
+	Descripcion string `bun:",type:varchar(255)"`
+}
+
+type perfilPermisoV6 struct {
+	bun.BaseModel `bun:"table:perfil_permisos"`
+
+	PerfilID  int64     `bun:"perfil_id,pk"`
+	PermisoID int64     `bun:"permiso_id,pk"`
+	CreatedAt time.Time `bun:",type:timestamp,default:current_timestamp"`
+}
+
+// Versión 8: slugs_pelicula
+
+type slugPeliculaV8 struct {
+	bun.BaseModel `bun:"table:slugs_pelicula"`
+
+	ID        int64     `bun:",pk,autoincrement"`
+	PID       int64     `bun:"p_id,notnull"`
+	Slug      string    `bun:",type:varchar(255),notnull,unique"`
+	CreatedAt time.Time `bun:",type:timestamp,default:current_timestamp"`
+}
+
+// Versión 12: auditoria
+
+type auditoriaV12 struct {
+	bun.BaseModel `bun:"table:auditoria"`
+
+	ID        int64     `bun:",pk,autoincrement"`
+	UsuarioID int64     `bun:"usuario_id,nullzero"`
+	Accion    string    `bun:",type:varchar(20),notnull"`
+	Entidad   string    `bun:",type:varchar(50),notnull"`
+	EntidadID string    `bun:"entidad_id,type:varchar(100),notnull"`
+	Antes     string    `bun:",type:json,nullzero"`
+	Despues   string    `bun:",type:json,nullzero"`
+	IP        string    `bun:"ip,type:varchar(45)"`
+	RequestID string    `bun:"request_id,type:varchar(64)"`
+	CreatedAt time.Time `bun:",type:timestamp,default:current_timestamp"`
+}
@@ -0,0 +1,357 @@
+package migraciones
+
+import (
+	"context"
+	"fmt"
+
+	"strings"
+
+	"github.com/gosimple/slug"
+	"github.com/jgutierrez746/clase_7_gin_bun/config"
+	"github.com/jgutierrez746/clase_7_gin_bun/db"
+)
+
+// historial contiene todas las migraciones del esquema. Las nuevas se agregan al final con la versión siguiente;
+// nunca se modifica una migración ya publicada. Las tablas se crean con las copias de esquemas.go, no con modelos, y
+// los datos que insertan están escritos en la migración (no se leen de config ni se calculan con helpers).
+var historial = []Migracion{
+	{
+		Version: 1,
+		Nombre:  "tablas_iniciales",
+		Up: func(ctx context.Context) error {
+			for _, modelo := range modelosIniciales() {
+				if err := db.CreateTable(ctx, modelo); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(ctx context.Context) error {
+			tablas := modelosIniciales()
+			for i := len(tablas) - 1; i >= 0; i-- {
+				if err := db.DropTable(ctx, tablas[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 2,
+		Nombre:  "fks_iniciales",
+		Up: func(ctx context.Context) error {
+			if err := db.AgregarFK(ctx, config.Tablas["pt"], "p_id", config.Tablas["pl"], "id", "CASCADE"); err != nil {
+				return err
+			}
+			if err := db.AgregarFK(ctx, config.Tablas["pt"], "tematica_id", config.Tablas["tm"], "id", "CASCADE"); err != nil {
+				return err
+			}
+			return db.AgregarFK(ctx, config.Tablas["u"], "perfil_id", config.Tablas["p"], "id", "")
+		},
+		Down: func(ctx context.Context) error {
+			if err := db.EliminarFK(ctx, config.Tablas["u"], "fk_usuarios_perfil_id"); err != nil {
+				return err
+			}
+			if err := db.EliminarFK(ctx, config.Tablas["pt"], "fk_pelicula_tematicas_tematica_id"); err != nil {
+				return err
+			}
+			return db.EliminarFK(ctx, config.Tablas["pt"], "fk_pelicula_tematicas_p_id")
+		},
+	},
+	{
+		Version: 3,
+		Nombre:  "busqueda_peliculas",
+		Up: func(ctx context.Context) error {
+			if err := db.AgregarColumna(ctx, config.Tablas["pl"], "busqueda", "TEXT"); err != nil {
+				return err
+			}
+			if err := db.AgregarFullText(ctx, config.Tablas["pl"], "ft_peliculas_busqueda", "busqueda"); err != nil {
+				return err
+			}
+			return reindexarBusquedaV3(ctx)
+		},
+		Down: func(ctx context.Context) error {
+			if err := db.EliminarIndice(ctx, config.Tablas["pl"], "ft_peliculas_busqueda"); err != nil {
+				return err
+			}
+			return db.EliminarColumna(ctx, config.Tablas["pl"], "busqueda")
+		},
+	},
+	{
+		Version: 4,
+		Nombre:  "timestamps_usuarios",
+		Up: func(ctx context.Context) error {
+			if err := db.AgregarColumna(ctx, config.Tablas["u"], "created_at", "TIMESTAMP DEFAULT CURRENT_TIMESTAMP"); err != nil {
+				return err
+			}
+			return db.AgregarColumna(ctx, config.Tablas["u"], "updated_at", "TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP")
+		},
+		Down: func(ctx context.Context) error {
+			if err := db.EliminarColumna(ctx, config.Tablas["u"], "updated_at"); err != nil {
+				return err
+			}
+			return db.EliminarColumna(ctx, config.Tablas["u"], "created_at")
+		},
+	},
+	{
+		Version: 5,
+		Nombre:  "refresh_tokens_y_revocados",
+		Up: func(ctx context.Context) error {
+			if err := db.CreateTable(ctx, &refreshTokenV5{}); err != nil {
+				return err
+			}
+			if err := db.CreateTable(ctx, &tokenRevocadoV5{}); err != nil {
+				return err
+			}
+			return db.AgregarFK(ctx, config.Tablas["rt"], "usuario_id", config.Tablas["u"], "id", "CASCADE")
+		},
+		Down: func(ctx context.Context) error {
+			if err := db.DropTable(ctx, &tokenRevocadoV5{}); err != nil {
+				return err
+			}
+			return db.DropTable(ctx, &refreshTokenV5{})
+		},
+	},
+	{
+		Version: 6,
+		Nombre:  "permisos_por_perfil",
+		Up: func(ctx context.Context) error {
+			if err := db.CreateTable(ctx, &permisoV6{}); err != nil {
+				return err
+			}
+			if err := db.CreateTable(ctx, &perfilPermisoV6{}); err != nil {
+				return err
+			}
+			if err := db.AgregarFK(ctx, config.Tablas["pfp"], "perfil_id", config.Tablas["p"], "id", "CASCADE"); err != nil {
+				return err
+			}
+			if err := db.AgregarFK(ctx, config.Tablas["pfp"], "permiso_id", config.Tablas["pm"], "id", "CASCADE"); err != nil {
+				return err
+			}
+
+			if _, err := db.InsertBatchIgnorar(ctx, config.Tablas["pm"], permisosV6); err != nil {
+				return err
+			}
+
+			// Se conserva el acceso que existía antes de los permisos: el perfil 1 (admin) tiene todo
+			// y los demás perfiles pueden leer, escribir y eliminar películas y temáticas.
+			_, err := db.Ejecutar(ctx, fmt.Sprintf(`
+				INSERT IGNORE INTO %s (perfil_id, permiso_id)
+				SELECT p.id, pm.id FROM %s p CROSS JOIN %s pm
+				WHERE p.id = 1 OR pm.codigo LIKE 'peliculas:%%' OR pm.codigo LIKE 'tematicas:%%'
+			`, config.Tablas["pfp"], config.Tablas["p"], config.Tablas["pm"]))
+			return err
+		},
+		Down: func(ctx context.Context) error {
+			if err := db.DropTable(ctx, &perfilPermisoV6{}); err != nil {
+				return err
+			}
+			return db.DropTable(ctx, &permisoV6{})
+		},
+	},
+	{
+		Version: 7,
+		Nombre:  "imagenes_pelicula",
+		Up: func(ctx context.Context) error {
+			pp := config.Tablas["pp"]
+			if err := db.AgregarColumna(ctx, pp, "tipo", "VARCHAR(20) NOT NULL DEFAULT 'poster'"); err != nil {
+				return err
+			}
+			if err := db.AgregarColumna(ctx, pp, "orden", "INT NOT NULL DEFAULT 0"); err != nil {
+				return err
+			}
+			if err := db.AgregarColumna(ctx, pp, "is_primary", "BOOLEAN NOT NULL DEFAULT FALSE"); err != nil {
+				return err
+			}
+			if err := db.AgregarIndice(ctx, pp, "idx_portada_pelicula_p_id_tipo_orden", "p_id", "tipo", "orden"); err != nil {
+				return err
+			}
+
+			// Hasta ahora cada película tenía una sola portada: pasa a ser su imagen principal
+			_, err := db.Ejecutar(ctx, fmt.Sprintf("UPDATE %s SET orden = 1, is_primary = TRUE WHERE orden = 0", pp))
+			return err
+		},
+		Down: func(ctx context.Context) error {
+			pp := config.Tablas["pp"]
+			if err := db.EliminarIndice(ctx, pp, "idx_portada_pelicula_p_id_tipo_orden"); err != nil {
+				return err
+			}
+			for _, columna := range []string{"is_primary", "orden", "tipo"} {
+				if err := db.EliminarColumna(ctx, pp, columna); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 8,
+		Nombre:  "slugs_pelicula",
+		Up: func(ctx context.Context) error {
+			if err := db.CreateTable(ctx, &slugPeliculaV8{}); err != nil {
+				return err
+			}
+			return db.AgregarFK(ctx, config.Tablas["sp"], "p_id", config.Tablas["pl"], "id", "CASCADE")
+		},
+		Down: func(ctx context.Context) error {
+			return db.DropTable(ctx, &slugPeliculaV8{})
+		},
+	},
+	{
+		Version: 9,
+		Nombre:  "usuarios_perfil_restrict",
+		// Con CASCADE, borrar un perfil borraba a sus usuarios. Ahora el DELETE falla mientras tenga usuarios.
+		Up: func(ctx context.Context) error {
+			u := config.Tablas["u"]
+			if err := db.EliminarFK(ctx, u, "fk_usuarios_perfil_id"); err != nil {
+				return err
+			}
+			return db.AgregarFK(ctx, u, "perfil_id", config.Tablas["p"], "id", "RESTRICT")
+		},
+		Down: func(ctx context.Context) error {
+			u := config.Tablas["u"]
+			if err := db.EliminarFK(ctx, u, "fk_usuarios_perfil_id"); err != nil {
+				return err
+			}
+			return db.AgregarFK(ctx, u, "perfil_id", config.Tablas["p"], "id", "CASCADE")
+		},
+	},
+	{
+		Version: 10,
+		Nombre:  "version_peliculas_tematicas",
+		// Control de concurrencia optimista (ETag / If-Match)
+		Up: func(ctx context.Context) error {
+			if err := db.AgregarColumna(ctx, config.Tablas["pl"], "version", "BIGINT NOT NULL DEFAULT 1"); err != nil {
+				return err
+			}
+			return db.AgregarColumna(ctx, config.Tablas["tm"], "version", "BIGINT NOT NULL DEFAULT 1")
+		},
+		Down: func(ctx context.Context) error {
+			if err := db.EliminarColumna(ctx, config.Tablas["pl"], "version"); err != nil {
+				return err
+			}
+			return db.EliminarColumna(ctx, config.Tablas["tm"], "version")
+		},
+	},
+	{
+		Version: 11,
+		Nombre:  "borrado_logico",
+		// Papelera: las películas, temáticas y usuarios eliminados quedan con deleted_at hasta que se purgan
+		Up: func(ctx context.Context) error {
+			for _, t := range []string{config.Tablas["pl"], config.Tablas["tm"], config.Tablas["u"]} {
+				if err := db.AgregarColumna(ctx, t, "deleted_at", "TIMESTAMP NULL DEFAULT NULL"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(ctx context.Context) error {
+			for _, t := range []string{config.Tablas["pl"], config.Tablas["tm"], config.Tablas["u"]} {
+				if err := db.EliminarColumna(ctx, t, "deleted_at"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 12,
+		Nombre:  "auditoria",
+		Up: func(ctx context.Context) error {
+			au := config.Tablas["au"]
+			if err := db.CreateTable(ctx, &auditoriaV12{}); err != nil {
+				return err
+			}
+			if err := db.AgregarIndice(ctx, au, "idx_auditoria_entidad", "entidad", "entidad_id"); err != nil {
+				return err
+			}
+			if err := db.AgregarIndice(ctx, au, "idx_auditoria_usuario_id", "usuario_id"); err != nil {
+				return err
+			}
+			if err := db.AgregarIndice(ctx, au, "idx_auditoria_created_at", "created_at"); err != nil {
+				return err
+			}
+
+			// Permiso para consultarla, solo para el perfil 1 (admin)
+			permiso := []permisoV6{{Codigo: "auditoria:read", Descripcion: "Consultar el registro de auditoría"}}
+			if _, err := db.InsertBatchIgnorar(ctx, config.Tablas["pm"], permiso); err != nil {
+				return err
+			}
+			_, err := db.Ejecutar(ctx, fmt.Sprintf(`
+				INSERT IGNORE INTO %s (perfil_id, permiso_id)
+				SELECT p.id, pm.id FROM %s p CROSS JOIN %s pm
+				WHERE p.id = 1 AND pm.codigo = 'auditoria:read'
+			`, config.Tablas["pfp"], config.Tablas["p"], config.Tablas["pm"]))
+			return err
+		},
+		Down: func(ctx context.Context) error {
+			if _, err := db.Delete(ctx, config.Tablas["pm"], "codigo = ?", "auditoria:read"); err != nil {
+				return err
+			}
+			return db.DropTable(ctx, &auditoriaV12{})
+		},
+	},
+}
+
+// modelosIniciales son las tablas de la versión 1, en orden de creación (padres antes que hijas).
+func modelosIniciales() []interface{} {
+	return []interface{}{
+		&tematicasV1{},
+		&peliculasV1{},
+		&peliculaTematicaV1{},
+		&portadaPeliculaV1{},
+		&perfilesV1{},
+		&usuariosV1{},
+	}
+}
+
+// permisosV6 es el catálogo de permisos al publicarse la versión 6. Los que se agregan después los inserta su propia
+// migración (ej: auditoria:read en la 12), no se leen de config.Permisos.
+var permisosV6 = []permisoV6{
+	{Codigo: "peliculas:read", Descripcion: "Consultar películas, sus temáticas y portadas"},
+	{Codigo: "peliculas:write", Descripcion: "Crear y editar películas, sus temáticas y portadas"},
+	{Codigo: "peliculas:delete", Descripcion: "Eliminar películas, sus temáticas y portadas"},
+	{Codigo: "tematicas:read", Descripcion: "Consultar temáticas"},
+	{Codigo: "tematicas:write", Descripcion: "Crear y editar temáticas"},
+	{Codigo: "tematicas:delete", Descripcion: "Eliminar temáticas"},
+	{Codigo: "perfiles:read", Descripcion: "Consultar perfiles y sus permisos"},
+	{Codigo: "perfiles:write", Descripcion: "Crear y editar perfiles"},
+	{Codigo: "perfiles:delete", Descripcion: "Eliminar perfiles"},
+	{Codigo: "usuarios:read", Descripcion: "Consultar usuarios"},
+	{Codigo: "usuarios:write", Descripcion: "Crear y editar usuarios"},
+	{Codigo: "usuarios:delete", Descripcion: "Eliminar usuarios"},
+	{Codigo: "permisos:write", Descripcion: "Otorgar y revocar permisos a perfiles"},
+}
+
+// reindexarBusquedaV3 llena la columna busqueda de las películas existentes al crear el índice FULLTEXT.
+// Normaliza igual que helpers.NormalizarBusqueda en la versión 3, pero copiado aquí para no depender de su evolución.
+func reindexarBusquedaV3(ctx context.Context) error {
+	if db.DB == nil {
+		return fmt.Errorf("DB no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
+	}
+
+	var peliculas []struct {
+		ID          int64  `bun:"id"`
+		Titulo      string `bun:"titulo"`
+		Descripcion string `bun:"descripcion"`
+		Director    string `bun:"director"`
+	}
+	consulta := fmt.Sprintf("SELECT id, titulo, descripcion, director FROM %s", config.Tablas["pl"])
+	if err := db.DB.NewRaw(consulta).Scan(ctx, &peliculas); err != nil {
+		return fmt.Errorf("error leyendo películas a reindexar: %w", err)
+	}
+
+	actualizar := fmt.Sprintf("UPDATE %s SET busqueda = ? WHERE id = ?", config.Tablas["pl"])
+	for _, p := range peliculas {
+		partes := make([]string, 0, 3)
+		for _, texto := range []string{p.Titulo, p.Descripcion, p.Director} {
+			if s := slug.Make(texto); s != "" {
+				partes = append(partes, strings.ReplaceAll(s, "-", " "))
+			}
+		}
+		if _, err := db.Ejecutar(ctx, actualizar, strings.Join(partes, " "), p.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
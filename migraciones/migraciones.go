@@ -0,0 +1,184 @@
+package migraciones
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/jgutierrez746/clase_7_gin_bun/config"
+	"github.com/jgutierrez746/clase_7_gin_bun/db"
+	"github.com/uptrace/bun"
+)
+
+// Migracion es un cambio de esquema versionado con su reversa.
+// Up y Down deben ser idempotentes (usar db.ExisteColumna, db.AgregarFK, etc.), ya que MySQL
+// no permite DDL transaccional y una migración puede quedar a medias si falla.
+type Migracion struct {
+	Version int64
+	Nombre  string
+	Up      func(ctx context.Context) error
+	Down    func(ctx context.Context) error
+}
+
+// Estado describe si una migración del historial ya fue aplicada.
+type Estado struct {
+	Version   int64      `json:"version"`
+	Nombre    string     `json:"nombre"`
+	Aplicada  bool       `json:"aplicada"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// schemaMigracion es una fila de la tabla de control schema_migrations.
+type schemaMigracion struct {
+	bun.BaseModel `bun:"table:schema_migrations"`
+
+	Version   int64     `bun:",pk"`
+	Nombre    string    `bun:",type:varchar(255),notnull"`
+	AppliedAt time.Time `bun:",type:timestamp,default:current_timestamp"`
+}
+
+// nombreLock es el lock de MySQL (GET_LOCK) que evita que dos réplicas migren a la vez.
+const nombreLock = "schema_migrations"
+
+// Up aplica en orden todas las migraciones pendientes. Retorna cuántas se aplicaron.
+func Up(ctx context.Context) (int, error) {
+	aplicadas := 0
+	err := conLock(ctx, func() error {
+		versiones, err := versionesAplicadas(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range ordenadas() {
+			if _, ok := versiones[m.Version]; ok {
+				continue
+			}
+
+			log.Printf("Aplicando migración %d_%s...", m.Version, m.Nombre)
+			if err := m.Up(ctx); err != nil {
+				return fmt.Errorf("error en migración %d_%s: %w", m.Version, m.Nombre, err)
+			}
+
+			registro := schemaMigracion{
+				Version:   m.Version,
+				Nombre:    m.Nombre,
+				AppliedAt: time.Now().In(config.Chilelocation),
+			}
+			if err := db.Insert(ctx, config.Tablas["sm"], &registro); err != nil {
+				return err
+			}
+			aplicadas++
+		}
+		return nil
+	})
+	return aplicadas, err
+}
+
+// Down revierte la última migración aplicada. Retorna false si no había nada que revertir.
+func Down(ctx context.Context) (bool, error) {
+	revertida := false
+	err := conLock(ctx, func() error {
+		versiones, err := versionesAplicadas(ctx)
+		if err != nil {
+			return err
+		}
+
+		lista := ordenadas()
+		for i := len(lista) - 1; i >= 0; i-- {
+			m := lista[i]
+			if _, ok := versiones[m.Version]; !ok {
+				continue
+			}
+
+			log.Printf("Revirtiendo migración %d_%s...", m.Version, m.Nombre)
+			if err := m.Down(ctx); err != nil {
+				return fmt.Errorf("error revirtiendo migración %d_%s: %w", m.Version, m.Nombre, err)
+			}
+			if _, err := db.Delete(ctx, config.Tablas["sm"], "version = ?", m.Version); err != nil {
+				return err
+			}
+			revertida = true
+			return nil
+		}
+		return nil
+	})
+	return revertida, err
+}
+
+// Status lista todas las migraciones del historial indicando cuáles están aplicadas.
+func Status(ctx context.Context) ([]Estado, error) {
+	if err := db.CreateTable(ctx, &schemaMigracion{}); err != nil {
+		return nil, err
+	}
+
+	var filas []schemaMigracion
+	if err := db.SelectAll(ctx, config.Tablas["sm"], &filas); err != nil {
+		return nil, err
+	}
+	porVersion := make(map[int64]schemaMigracion, len(filas))
+	for _, f := range filas {
+		porVersion[f.Version] = f
+	}
+
+	estados := []Estado{}
+	for _, m := range ordenadas() {
+		e := Estado{Version: m.Version, Nombre: m.Nombre}
+		if f, ok := porVersion[m.Version]; ok {
+			e.Aplicada = true
+			e.AppliedAt = &f.AppliedAt
+		}
+		estados = append(estados, e)
+	}
+	return estados, nil
+}
+
+// versionesAplicadas crea la tabla de control si no existe y retorna las versiones ya aplicadas.
+func versionesAplicadas(ctx context.Context) (map[int64]struct{}, error) {
+	if err := db.CreateTable(ctx, &schemaMigracion{}); err != nil {
+		return nil, err
+	}
+
+	var filas []schemaMigracion
+	if err := db.SelectAll(ctx, config.Tablas["sm"], &filas); err != nil {
+		return nil, err
+	}
+
+	versiones := make(map[int64]struct{}, len(filas))
+	for _, f := range filas {
+		versiones[f.Version] = struct{}{}
+	}
+	return versiones, nil
+}
+
+// ordenadas retorna el historial ordenado por versión.
+func ordenadas() []Migracion {
+	lista := append([]Migracion{}, historial...)
+	sort.Slice(lista, func(i, j int) bool { return lista[i].Version < lista[j].Version })
+	return lista
+}
+
+// conLock ejecuta fn mientras se mantiene el lock de migraciones en una conexión dedicada.
+func conLock(ctx context.Context, fn func() error) error {
+	if db.DB == nil {
+		return fmt.Errorf("DB no inicializada") // Se debe llamar a InitDB primero si este error ocurre.
+	}
+
+	conn, err := db.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error obteniendo conexión para migrar: %w", err)
+	}
+	defer conn.Close()
+
+	var obtenido int
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", nombreLock, 60).Scan(&obtenido); err != nil {
+		return fmt.Errorf("error obteniendo lock de migraciones: %w", err)
+	}
+	if obtenido != 1 {
+		return fmt.Errorf("no se pudo obtener el lock de migraciones (otra instancia está migrando)")
+	}
+	defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", nombreLock)
+
+	return fn()
+}
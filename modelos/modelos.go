@@ -12,8 +12,10 @@ type TematicasModel struct {
 	ID        int64     `bun:",pk,autoincrement"`
 	Nombre    string    `bun:",type:varchar(100),notnull"`
 	Slug      string    `bun:",type:varchar(100),notnull,unique"`
+	Version   int64     `bun:",notnull,default:1"` // Sube con cada escritura, es el ETag de la temática
 	CreatedAt time.Time `bun:",type:timestamp,default:current_timestamp"`
 	UpdatedAt time.Time `bun:",type:timestamp,default:current_timestamp,on_update:current_timestamp"`
+	DeletedAt time.Time `bun:",type:timestamp,nullzero"` // En la papelera desde esta fecha, NULL si no está borrada
 }
 
 type PeliculasModel struct {
@@ -25,8 +27,11 @@ type PeliculasModel struct {
 	Slug        string    `bun:",type:varchar(255),notnull,unique"`
 	Descripcion string    `bun:",type:text"`
 	Director    string    `bun:",type:varchar(100),notnull"`
+	Busqueda    string    `bun:",type:text"`         // Título, descripción y director normalizados para el índice FULLTEXT
+	Version     int64     `bun:",notnull,default:1"` // Sube con cada escritura (también de sus temáticas e imágenes), es el ETag de la película
 	CreatedAt   time.Time `bun:",type:timestamp,default:current_timestamp"`
 	UpdatedAt   time.Time `bun:",type:timestamp,default:current_timestamp,on_update:current_timestamp"`
+	DeletedAt   time.Time `bun:",type:timestamp,nullzero"` // En la papelera desde esta fecha, NULL si no está borrada
 }
 
 type PeliculaTematicaModel struct {
@@ -44,6 +49,9 @@ type PortadaPeliculaModel struct {
 	ID            int64     `bun:",pk,autoincrement"`
 	PID           int64     `bun:"p_id"`
 	NombreArchivo string    `bun:"nombre_archivo"`
+	Tipo          string    `bun:"tipo,notnull,default:'poster'"` // poster, backdrop o still
+	Orden         int       `bun:"orden,notnull,default:0"`
+	IsPrimary     bool      `bun:"is_primary,notnull,default:false"` // Imagen principal (portada) de la película
 	CreatedAt     time.Time `bun:",type:timestamp,default:current_timestamp"`
 }
 
@@ -57,10 +65,75 @@ type PerfilesModel struct {
 type UsuariosModel struct {
 	bun.BaseModel `bun:"table:usuarios"`
 
-	ID       int64  `bun:",pk,autoincrement"`
-	Nombre   string `bun:"nombre,notnull"`
-	Correo   string `bun:"correo,notnull"`
-	Telefono string `bun:"telefono,notnull"`
-	Password string `bun:"password,notnull"`
-	PerfilID int64  `bun:"perfil_id,notnull"`
+	ID        int64     `bun:",pk,autoincrement"`
+	Nombre    string    `bun:"nombre,notnull"`
+	Correo    string    `bun:"correo,notnull"`
+	Telefono  string    `bun:"telefono,notnull"`
+	Password  string    `bun:"password,notnull"`
+	PerfilID  int64     `bun:"perfil_id,notnull"`
+	CreatedAt time.Time `bun:",type:timestamp,default:current_timestamp"`
+	UpdatedAt time.Time `bun:",type:timestamp,default:current_timestamp,on_update:current_timestamp"`
+	DeletedAt time.Time `bun:",type:timestamp,nullzero"` // En la papelera desde esta fecha, NULL si no está borrada
+}
+
+type RefreshTokenModel struct {
+	bun.BaseModel `bun:"table:refresh_tokens"`
+
+	ID        int64     `bun:",pk,autoincrement"`
+	UsuarioID int64     `bun:"usuario_id,notnull"`            // FK a Usuarios.ID
+	TokenHash string    `bun:",type:char(64),notnull,unique"` // SHA-256 del token, nunca se guarda el token en claro
+	ExpiresAt time.Time `bun:",type:timestamp,notnull"`
+	RevokedAt time.Time `bun:",type:timestamp,nullzero"`
+	CreatedAt time.Time `bun:",type:timestamp,default:current_timestamp"`
+}
+
+type TokenRevocadoModel struct {
+	bun.BaseModel `bun:"table:tokens_revocados"`
+
+	JTI       string    `bun:"jti,pk,type:varchar(64)"`
+	ExpiresAt time.Time `bun:",type:timestamp,notnull"` // Pasada esta fecha el token expira solo y la fila se puede borrar
+}
+
+type PermisosModel struct {
+	bun.BaseModel `bun:"table:permisos"`
+
+	ID          int64  `bun:",pk,autoincrement"`
+	Codigo      string `bun:",type:varchar(100),notnull,unique"` // Ej: "peliculas:write"
+	Descripcion string `bun:",type:varchar(255)"`
+}
+
+type PerfilPermisoModel struct {
+	bun.BaseModel `bun:"table:perfil_permisos"`
+
+	PerfilID  int64     `bun:"perfil_id,pk"`  // FK a Perfiles.ID
+	PermisoID int64     `bun:"permiso_id,pk"` // FK a Permisos.ID
+	CreatedAt time.Time `bun:",type:timestamp,default:current_timestamp"`
+}
+
+// SlugPeliculaModel guarda los slugs que tuvo una película antes de cambiar su título,
+// para redirigir las URLs antiguas a la actual.
+type SlugPeliculaModel struct {
+	bun.BaseModel `bun:"table:slugs_pelicula"`
+
+	ID        int64     `bun:",pk,autoincrement"`
+	PID       int64     `bun:"p_id,notnull"`                      // FK a Peliculas.ID
+	Slug      string    `bun:",type:varchar(255),notnull,unique"` // Un slug antiguo apunta a una sola película
+	CreatedAt time.Time `bun:",type:timestamp,default:current_timestamp"`
+}
+
+// AuditoriaModel registra una escritura hecha desde la API: quién la hizo, sobre qué y cómo quedó.
+// No tiene FK a usuarios: el registro se conserva aunque el usuario se purgue.
+type AuditoriaModel struct {
+	bun.BaseModel `bun:"table:auditoria"`
+
+	ID        int64     `bun:",pk,autoincrement"`
+	UsuarioID int64     `bun:"usuario_id,nullzero"`                  // Actor, NULL si no había usuario autenticado
+	Accion    string    `bun:",type:varchar(20),notnull"`            // crear, actualizar, eliminar o restaurar
+	Entidad   string    `bun:",type:varchar(50),notnull"`            // Tabla, ej: "peliculas"
+	EntidadID string    `bun:"entidad_id,type:varchar(100),notnull"` // Ej: "5", o "5:3" en pelicula_tematicas
+	Antes     string    `bun:",type:json,nullzero"`                  // La fila antes de la escritura, NULL al crear
+	Despues   string    `bun:",type:json,nullzero"`                  // La fila después de la escritura, NULL al eliminar
+	IP        string    `bun:"ip,type:varchar(45)"`
+	RequestID string    `bun:"request_id,type:varchar(64)"` // Header X-Request-ID de la respuesta
+	CreatedAt time.Time `bun:",type:timestamp,default:current_timestamp"`
 }
@@ -0,0 +1,127 @@
+package papelera
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jgutierrez746/clase_7_gin_bun/almacenamiento"
+	"github.com/jgutierrez746/clase_7_gin_bun/config"
+	"github.com/jgutierrez746/clase_7_gin_bun/db"
+	"github.com/jgutierrez746/clase_7_gin_bun/dto"
+	"github.com/jgutierrez746/clase_7_gin_bun/helpers"
+	"github.com/uptrace/bun"
+)
+
+// Reporte es el resultado de una purga: filas borradas definitivamente por tabla y archivos de portada borrados.
+type Reporte struct {
+	Peliculas int64
+	Tematicas int64
+	Usuarios  int64
+	Archivos  int
+}
+
+// Total retorna la cantidad de filas purgadas.
+func (r *Reporte) Total() int64 {
+	return r.Peliculas + r.Tematicas + r.Usuarios
+}
+
+// Purgar borra definitivamente las películas, temáticas y usuarios que llevan en la papelera más que retencion.
+// Las FK en CASCADE borran sus asociaciones (temáticas de películas, slugs antiguos, refresh tokens); las imágenes
+// de las películas no tienen FK, se borran sus filas y después sus archivos del almacenamiento.
+func Purgar(ctx context.Context, retencion time.Duration) (*Reporte, error) {
+	if almacenamiento.Portadas == nil {
+		return nil, fmt.Errorf("almacenamiento no inicializado") // Se debe llamar a almacenamiento.Cargar primero si este error ocurre.
+	}
+
+	pl := config.Tablas["pl"]
+	pp := config.Tablas["pp"]
+	limite := time.Now().In(config.Chilelocation).Add(-retencion)
+	reporte := &Reporte{}
+
+	var portadas []dto.PortadaSelectDTO
+	err := db.WithTx(ctx, func(tx bun.IDB) error {
+		var peliculas []struct {
+			ID int64 `bun:"id"`
+		}
+		if err := db.SelectTx(db.IncluirBorrados(ctx), tx, pl, &peliculas, "", "deleted_at < ?", limite); err != nil {
+			return err
+		}
+		if len(peliculas) == 0 {
+			return nil
+		}
+		ids := make([]int64, len(peliculas))
+		for i, p := range peliculas {
+			ids[i] = p.ID
+		}
+
+		if err := db.SelectTx(ctx, tx, pp, &portadas, "", "p_id IN (?)", bun.In(ids)); err != nil {
+			return err
+		}
+		if _, err := db.DeleteTx(ctx, tx, pp, "p_id IN (?)", bun.In(ids)); err != nil {
+			return err
+		}
+
+		var err error
+		reporte.Peliculas, err = db.DeleteTx(ctx, tx, pl, "id IN (?)", bun.In(ids))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error purgando películas: %w", err)
+	}
+
+	// Si falla el borrado de un archivo queda huérfano y lo encuentra la reconciliación de portadas
+	for _, p := range portadas {
+		for _, clave := range helpers.ClavesPortada(p.NombreArchivo) {
+			if err := almacenamiento.Portadas.Delete(ctx, clave); err != nil {
+				return reporte, err
+			}
+			reporte.Archivos++
+		}
+	}
+
+	if reporte.Tematicas, err = db.Delete(ctx, config.Tablas["tm"], "deleted_at < ?", limite); err != nil {
+		return reporte, fmt.Errorf("error purgando temáticas: %w", err)
+	}
+	if reporte.Usuarios, err = db.Delete(ctx, config.Tablas["u"], "deleted_at < ?", limite); err != nil {
+		return reporte, fmt.Errorf("error purgando usuarios: %w", err)
+	}
+	return reporte, nil
+}
+
+// Programar ejecuta Purgar cada intervalo hasta que ctx se cancele, registrando el resultado en el log.
+// El canal retornado se cierra cuando termina, incluida la purga en curso al cancelar ctx.
+func Programar(ctx context.Context, intervalo, retencion time.Duration) <-chan struct{} {
+	terminado := make(chan struct{})
+	go func() {
+		defer close(terminado)
+		ticker := time.NewTicker(intervalo)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				purgarYRegistrar(ctx, retencion)
+			}
+		}
+	}()
+	return terminado
+}
+
+func purgarYRegistrar(ctx context.Context, retencion time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	reporte, err := Purgar(ctx, retencion)
+	if err != nil {
+		log.Printf("Error purgando la papelera: %v", err)
+		return
+	}
+	if reporte.Total() == 0 {
+		return
+	}
+	log.Printf("Purga de la papelera: %d películas, %d temáticas, %d usuarios, %d archivos de portada",
+		reporte.Peliculas, reporte.Tematicas, reporte.Usuarios, reporte.Archivos)
+}
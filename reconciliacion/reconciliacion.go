@@ -0,0 +1,181 @@
+package reconciliacion
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jgutierrez746/clase_7_gin_bun/almacenamiento"
+	"github.com/jgutierrez746/clase_7_gin_bun/config"
+	"github.com/jgutierrez746/clase_7_gin_bun/db"
+	"github.com/jgutierrez746/clase_7_gin_bun/dto"
+	"github.com/jgutierrez746/clase_7_gin_bun/helpers"
+	"github.com/uptrace/bun"
+)
+
+// Opciones de una revisión.
+type Opciones struct {
+	Corregir bool // Si es false solo se reporta
+	// Los archivos y filas más nuevos que esto se ignoran: pueden ser subidas en curso cuya fila aún no se confirma
+	// o cuyos archivos aún no aparecen en el listado.
+	AntiguedadMinima time.Duration
+}
+
+// Reporte es el resultado de comparar el almacenamiento de portadas con la tabla portada_pelicula.
+type Reporte struct {
+	ArchivosRevisados int
+	FilasRevisadas    int
+	ArchivosHuerfanos []string               // Archivos sin fila (ni como original ni como miniatura)
+	FilasSinArchivo   []dto.PortadaSelectDTO // Filas cuyo archivo original no existe (más antiguas que AntiguedadMinima)
+	FilasSinPelicula  []dto.PortadaSelectDTO // Filas de películas que ya no existen
+	Corregido         bool
+}
+
+// Inconsistencias retorna la cantidad total de problemas encontrados.
+func (r *Reporte) Inconsistencias() int {
+	return len(r.ArchivosHuerfanos) + len(r.FilasSinArchivo) + len(r.FilasSinPelicula)
+}
+
+// Revisar busca archivos huérfanos y filas sin archivo o sin película. Con op.Corregir borra los archivos huérfanos,
+// borra las filas inconsistentes (y los archivos de las filas sin película) y reasigna la imagen principal
+// de las películas que quedaron sin una.
+func Revisar(ctx context.Context, op Opciones) (*Reporte, error) {
+	if almacenamiento.Portadas == nil {
+		return nil, fmt.Errorf("almacenamiento no inicializado") // Se debe llamar a almacenamiento.Cargar primero si este error ocurre.
+	}
+
+	pp := config.Tablas["pp"]
+	pl := config.Tablas["pl"]
+
+	// Las filas se leen antes de listar el almacenamiento: una subida guarda sus archivos antes de confirmar su fila,
+	// así que toda fila leída ya tiene sus archivos en el listado
+	var filas []dto.PortadaSelectDTO
+	if err := db.SelectAll(ctx, pp, &filas); err != nil {
+		return nil, fmt.Errorf("error consultando %s: %w", pp, err)
+	}
+	objetos, err := almacenamiento.Portadas.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var sinPelicula []dto.PortadaSelectDTO
+	joins := []string{fmt.Sprintf("LEFT JOIN %s ON %s.id = %s.p_id", pl, pl, pp)}
+	if err := db.SelectConJoin(ctx, pp, joins, nil, &sinPelicula, pp+".id ASC", pl+".id IS NULL"); err != nil {
+		return nil, fmt.Errorf("error consultando filas sin película: %w", err)
+	}
+
+	reporte := &Reporte{
+		ArchivosRevisados: len(objetos),
+		FilasRevisadas:    len(filas),
+		FilasSinPelicula:  sinPelicula,
+	}
+
+	limite := time.Now().Add(-op.AntiguedadMinima)
+	existentes := make(map[string]bool, len(objetos))
+	for _, o := range objetos {
+		existentes[o.Clave] = true
+	}
+	esperados := make(map[string]bool, len(filas)*(len(helpers.AnchosMiniatura)+1))
+	for _, f := range filas {
+		for _, clave := range helpers.ClavesPortada(f.NombreArchivo) {
+			esperados[clave] = true
+		}
+		if !existentes[f.NombreArchivo] && f.CreatedAt.Before(limite) {
+			reporte.FilasSinArchivo = append(reporte.FilasSinArchivo, f)
+		}
+	}
+
+	for _, o := range objetos {
+		if !esperados[o.Clave] && o.Modificado.Before(limite) {
+			reporte.ArchivosHuerfanos = append(reporte.ArchivosHuerfanos, o.Clave)
+		}
+	}
+
+	if op.Corregir && reporte.Inconsistencias() > 0 {
+		if err := corregir(ctx, reporte); err != nil {
+			return reporte, err
+		}
+		reporte.Corregido = true
+	}
+	return reporte, nil
+}
+
+func corregir(ctx context.Context, r *Reporte) error {
+	pp := config.Tablas["pp"]
+
+	for _, clave := range r.ArchivosHuerfanos {
+		if err := almacenamiento.Portadas.Delete(ctx, clave); err != nil {
+			return err
+		}
+	}
+
+	// Una fila puede estar en ambas listas, se borra una vez
+	ids := make(map[int64]bool)
+	for _, f := range append(append([]dto.PortadaSelectDTO{}, r.FilasSinArchivo...), r.FilasSinPelicula...) {
+		ids[f.ID] = true
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	lista := make([]int64, 0, len(ids))
+	for id := range ids {
+		lista = append(lista, id)
+	}
+	if _, err := db.Delete(ctx, pp, "id IN (?)", bun.In(lista)); err != nil {
+		return err
+	}
+
+	// Con las filas ya borradas, sus archivos restantes (miniaturas, o todo si la película no existe) son huérfanos
+	for _, f := range append(append([]dto.PortadaSelectDTO{}, r.FilasSinArchivo...), r.FilasSinPelicula...) {
+		for _, clave := range helpers.ClavesPortada(f.NombreArchivo) {
+			if err := almacenamiento.Portadas.Delete(ctx, clave); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Si se borró la imagen principal de una película, pasa a serlo la más antigua que le quede
+	_, err := db.Ejecutar(ctx, fmt.Sprintf(`
+		UPDATE %s pp
+		JOIN (SELECT p_id, MIN(id) AS id FROM %s GROUP BY p_id HAVING MAX(is_primary) = 0) sin ON sin.id = pp.id
+		SET pp.is_primary = TRUE
+	`, pp, pp))
+	return err
+}
+
+// Programar ejecuta Revisar cada intervalo hasta que ctx se cancele, registrando el resultado en el log.
+// El canal retornado se cierra cuando termina, incluida la revisión en curso al cancelar ctx.
+func Programar(ctx context.Context, intervalo time.Duration, op Opciones) <-chan struct{} {
+	terminado := make(chan struct{})
+	go func() {
+		defer close(terminado)
+		ticker := time.NewTicker(intervalo)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				revisarYRegistrar(ctx, op)
+			}
+		}
+	}()
+	return terminado
+}
+
+func revisarYRegistrar(ctx context.Context, op Opciones) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	reporte, err := Revisar(ctx, op)
+	if err != nil {
+		log.Printf("Error reconciliando portadas: %v", err)
+		return
+	}
+	if reporte.Inconsistencias() == 0 {
+		return
+	}
+	log.Printf("Reconciliación de portadas: %d archivos huérfanos, %d filas sin archivo, %d filas sin película (corregido: %t)",
+		len(reporte.ArchivosHuerfanos), len(reporte.FilasSinArchivo), len(reporte.FilasSinPelicula), reporte.Corregido)
+}
@@ -0,0 +1,188 @@
+// Package respuesta arma los cuerpos JSON de la API con una forma única:
+//
+//	éxito:   {"datos": ..., "mensaje": "...", "meta": {...}}   (mensaje y meta son opcionales)
+//	error:   {"error": {"codigo": "...", "mensaje": "...", "detalles": ...}}   (detalles es opcional)
+//
+// Los listados siempre entregan "datos" como arreglo (nunca null) y la paginación en "meta".
+package respuesta
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jgutierrez746/clase_7_gin_bun/db"
+	"github.com/jgutierrez746/clase_7_gin_bun/helpers"
+)
+
+// Códigos de error que no vienen de la base de datos (esos están en db.Codigo*).
+const (
+	CodigoSolicitudInvalida = "solicitud_invalida" // 400: parámetros o JSON mal formados
+	CodigoValidacion        = "validacion"         // 422: el JSON es válido pero no cumple las reglas
+	CodigoNoAutenticado     = "no_autenticado"     // 401
+	CodigoSinPermiso        = "sin_permiso"        // 403
+	CodigoNoEncontrado      = "no_encontrado"      // 404
+	CodigoMetodoNoPermitido = "metodo_no_permitido"
+	CodigoPrecondicion      = "precondicion_fallida" // 412: If-Match no coincide con la versión actual
+	CodigoMuyGrande         = "muy_grande"           // 413
+	CodigoTipoNoSoportado   = "tipo_no_soportado"    // 415
+	CodigoEntidadInvalida   = "entidad_invalida"     // 422: ej: imagen corrupta, ids que no corresponden
+	CodigoErrorInterno      = db.CodigoErrorInterno  // 500
+)
+
+// Exito es el cuerpo de una respuesta exitosa.
+type Exito struct {
+	Datos   interface{} `json:"datos"`
+	Mensaje string      `json:"mensaje,omitempty"`
+	Meta    interface{} `json:"meta,omitempty"`
+}
+
+// Fallo es el cuerpo de una respuesta de error.
+type Fallo struct {
+	Error DetalleError `json:"error"`
+}
+
+type DetalleError struct {
+	Codigo   string      `json:"codigo"`
+	Mensaje  string      `json:"mensaje"`
+	Detalles interface{} `json:"detalles,omitempty"`
+}
+
+// MetaPaginada es la "meta" de un listado paginado por ?page=. next y prev son null en los extremos.
+type MetaPaginada struct {
+	Total     int     `json:"total"`
+	Pagina    int     `json:"page"`
+	PorPagina int     `json:"per_page"`
+	Next      *string `json:"next"`
+	Prev      *string `json:"prev"`
+}
+
+// MetaCursor es la "meta" de un listado paginado por keyset. next_cursor y next son null si no quedan filas.
+type MetaCursor struct {
+	Limite     int     `json:"limit"`
+	NextCursor *string `json:"next_cursor"`
+	Next       *string `json:"next"`
+}
+
+// OK responde 200 con los datos.
+func OK(c *gin.Context, datos interface{}) {
+	c.JSON(http.StatusOK, Exito{Datos: normalizar(datos)})
+}
+
+// OKConMensaje responde 200 con los datos y un mensaje, ej: al editar o eliminar.
+func OKConMensaje(c *gin.Context, mensaje string, datos interface{}) {
+	c.JSON(http.StatusOK, Exito{Datos: normalizar(datos), Mensaje: mensaje})
+}
+
+// Creado responde 201 con el recurso creado.
+func Creado(c *gin.Context, mensaje string, datos interface{}) {
+	c.JSON(http.StatusCreated, Exito{Datos: normalizar(datos), Mensaje: mensaje})
+}
+
+// Eliminado responde 200 con la cantidad de filas eliminadas.
+func Eliminado(c *gin.Context, mensaje string, eliminados int64) {
+	OKConMensaje(c, mensaje, gin.H{"eliminados": eliminados})
+}
+
+// Lista responde 200 con un listado sin paginar.
+func Lista(c *gin.Context, datos interface{}) {
+	c.JSON(http.StatusOK, Exito{Datos: normalizar(datos), Meta: gin.H{"total": reflect.ValueOf(datos).Len()}})
+}
+
+// Paginada responde un listado paginado por ?page= con enlaces a la página siguiente y anterior.
+func Paginada(c *gin.Context, datos interface{}, total int, p db.Paginacion) {
+	meta := MetaPaginada{Total: total, Pagina: p.Pagina, PorPagina: p.PorPagina}
+	if p.Pagina*p.PorPagina < total {
+		next := helpers.URLConParametros(c, map[string]string{"page": strconv.Itoa(p.Pagina + 1)})
+		meta.Next = &next
+	}
+	if p.Pagina > 1 {
+		prev := helpers.URLConParametros(c, map[string]string{"page": strconv.Itoa(p.Pagina - 1)})
+		meta.Prev = &prev
+	}
+	c.JSON(http.StatusOK, Exito{Datos: normalizar(datos), Meta: meta})
+}
+
+// Cursor responde un listado paginado por keyset (?after=, ?limit=).
+func Cursor(c *gin.Context, datos interface{}, siguiente *db.Cursor, p db.PaginacionCursor) {
+	meta := MetaCursor{Limite: p.Limite}
+	if siguiente != nil {
+		token := helpers.CodificarCursor(siguiente)
+		next := helpers.URLConParametros(c, map[string]string{"after": token, "limit": strconv.Itoa(p.Limite)})
+		meta.NextCursor = &token
+		meta.Next = &next
+	}
+	c.JSON(http.StatusOK, Exito{Datos: normalizar(datos), Meta: meta})
+}
+
+// Error responde el error y corta la cadena de handlers (sirve también en middlewares).
+func Error(c *gin.Context, estado int, codigo, mensaje string) {
+	ErrorConDetalles(c, estado, codigo, mensaje, nil)
+}
+
+// ErrorConDetalles es Error con información adicional, ej: los errores por campo o por fila.
+func ErrorConDetalles(c *gin.Context, estado int, codigo, mensaje string, detalles interface{}) {
+	c.AbortWithStatusJSON(estado, Fallo{Error: DetalleError{Codigo: codigo, Mensaje: mensaje, Detalles: detalles}})
+}
+
+// SolicitudInvalida responde 400, ej: un parámetro de la URL que no es un número.
+func SolicitudInvalida(c *gin.Context, mensaje string) {
+	Error(c, http.StatusBadRequest, CodigoSolicitudInvalida, mensaje)
+}
+
+// NoEncontrado responde 404.
+func NoEncontrado(c *gin.Context, mensaje string) {
+	Error(c, http.StatusNotFound, CodigoNoEncontrado, mensaje)
+}
+
+// ErrorInterno responde 500 sin detalles. El error solo va al log.
+func ErrorInterno(c *gin.Context, mensaje string, err error) {
+	log.Printf("%s: %v", mensaje, err)
+	Error(c, http.StatusInternalServerError, CodigoErrorInterno, mensaje)
+}
+
+// ErrorBind responde el error de ShouldBindJSON/ShouldBind: 422 con un detalle por campo si el cuerpo
+// no cumple las reglas de validación, 413 si superó un http.MaxBytesReader, 400 si ni siquiera se pudo leer.
+func ErrorBind(c *gin.Context, err error) {
+	var errs validator.ValidationErrors
+	var campos helpers.ErrorCampos
+	if errors.As(err, &errs) || errors.As(err, &campos) {
+		ErrorConDetalles(c, http.StatusUnprocessableEntity, CodigoValidacion, "Los datos enviados no son válidos", helpers.ErroresValidacion(err))
+		return
+	}
+	var maxBytes *http.MaxBytesError
+	if errors.As(err, &maxBytes) {
+		Error(c, http.StatusRequestEntityTooLarge, CodigoMuyGrande, "El cuerpo supera el tamaño máximo de "+strconv.FormatInt(maxBytes.Limit, 10)+" bytes")
+		return
+	}
+	Error(c, http.StatusBadRequest, CodigoSolicitudInvalida, "Error al procesar el cuerpo: "+err.Error())
+}
+
+// ErrorBD responde un error de la base de datos con el status que le corresponde (ver db.TraducirError).
+// El texto del driver solo va al log, nunca al cliente.
+// - mensaje: qué se estaba haciendo, ej: "Error eliminando perfil"
+func ErrorBD(c *gin.Context, mensaje string, err error) {
+	e := db.TraducirError(err)
+	log.Printf("%s [%s]: %v", mensaje, e.Codigo, err)
+
+	if e.Mensaje != "" {
+		mensaje += ": " + e.Mensaje
+	}
+	if e.Estado == http.StatusServiceUnavailable {
+		c.Header("Retry-After", "1")
+	}
+	Error(c, e.Estado, e.Codigo, mensaje)
+}
+
+// normalizar convierte un slice nil en uno vacío, para que "datos" sea [] y no null.
+func normalizar(datos interface{}) interface{} {
+	v := reflect.ValueOf(datos)
+	if v.Kind() == reflect.Slice && v.IsNil() {
+		return reflect.MakeSlice(v.Type(), 0, 0).Interface()
+	}
+	return datos
+}
@@ -0,0 +1,147 @@
+package rutas
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jgutierrez746/clase_7_gin_bun/config"
+	"github.com/jgutierrez746/clase_7_gin_bun/db"
+	"github.com/jgutierrez746/clase_7_gin_bun/dto"
+	"github.com/jgutierrez746/clase_7_gin_bun/helpers"
+	jwtPkg "github.com/jgutierrez746/clase_7_gin_bun/jwt"
+	"github.com/jgutierrez746/clase_7_gin_bun/respuesta"
+	"github.com/uptrace/bun"
+)
+
+// Acciones del registro de auditoría
+const (
+	accionCrear      = "crear"
+	accionActualizar = "actualizar"
+	accionEliminar   = "eliminar"
+	accionRestaurar  = "restaurar"
+)
+
+// camposAuditoria es la lista blanca de campos por los que se puede ordenar y filtrar el registro.
+var camposAuditoria = map[string]string{
+	"id":         "id",
+	"usuario_id": "usuario_id",
+	"accion":     "accion",
+	"entidad":    "entidad",
+	"entidad_id": "entidad_id",
+	"request_id": "request_id",
+	"created_at": "created_at",
+}
+
+// campoAuditoria valida ?campo=, que se usa como ruta JSON ($.campo) al buscar en antes/después.
+var campoAuditoria = regexp.MustCompile(`^[a-z_]{1,50}$`)
+
+// nuevaAuditoria arma el registro de una escritura con el actor, la IP y el X-Request-ID de la solicitud.
+// antes y despues se guardan como JSON (nil = NULL); id puede ser compuesto, ej: "5:3".
+func nuevaAuditoria(c *gin.Context, accion, entidad string, id interface{}, antes, despues interface{}) (dto.AuditoriaInsert, error) {
+	registro := dto.AuditoriaInsert{
+		Accion:    accion,
+		Entidad:   entidad,
+		EntidadID: fmt.Sprint(id),
+		IP:        c.ClientIP(),
+		RequestID: helpers.IDSolicitud(c),
+		CreatedAt: time.Now().In(config.Chilelocation),
+	}
+	registro.UsuarioID, _ = jwtPkg.UsuarioDesdeContexto(c)
+
+	for destino, valor := range map[*string]interface{}{&registro.Antes: antes, &registro.Despues: despues} {
+		if valor == nil {
+			continue
+		}
+		b, err := json.Marshal(valor)
+		if err != nil {
+			return registro, err
+		}
+		*destino = string(b)
+	}
+	return registro, nil
+}
+
+// auditarTx registra la escritura en la misma transacción que la hace: si el registro falla, la escritura
+// tampoco se confirma.
+func auditarTx(ctx context.Context, c *gin.Context, tx bun.IDB, accion, entidad string, id interface{}, antes, despues interface{}) error {
+	registro, err := nuevaAuditoria(c, accion, entidad, id, antes, despues)
+	if err != nil {
+		return err
+	}
+	return db.InsertTx(ctx, tx, config.Tablas["au"], &registro)
+}
+
+// ConsultarAuditoria lista el registro de auditoría, por defecto lo más reciente primero.
+// Filtros: los de camposAuditoria (ej: ?entidad=peliculas&entidad_id=5), ?desde= y ?hasta= (fecha o RFC 3339)
+// y ?campo=titulo para ver solo las escrituras que cambiaron ese campo.
+func ConsultarAuditoria(c *gin.Context) {
+	paginacion, err := helpers.ParsearPaginacion(c, camposAuditoria, "id DESC")
+	if err != nil {
+		respuesta.SolicitudInvalida(c, err.Error())
+		return
+	}
+
+	var condiciones []string
+	var args []interface{}
+	if v := c.Query("desde"); v != "" {
+		desde, _, err := parsearFechaAuditoria(v)
+		if err != nil {
+			respuesta.SolicitudInvalida(c, "parámetro desde inválido: "+v)
+			return
+		}
+		condiciones = append(condiciones, "created_at >= ?")
+		args = append(args, desde)
+	}
+	if v := c.Query("hasta"); v != "" {
+		hasta, soloFecha, err := parsearFechaAuditoria(v)
+		if err != nil {
+			respuesta.SolicitudInvalida(c, "parámetro hasta inválido: "+v)
+			return
+		}
+		if soloFecha { // Incluye todo ese día
+			condiciones = append(condiciones, "created_at < ?")
+			args = append(args, hasta.AddDate(0, 0, 1))
+		} else {
+			condiciones = append(condiciones, "created_at <= ?")
+			args = append(args, hasta)
+		}
+	}
+	if campo := c.Query("campo"); campo != "" {
+		if !campoAuditoria.MatchString(campo) {
+			respuesta.SolicitudInvalida(c, "parámetro campo inválido: "+campo)
+			return
+		}
+		// <=> compara también los NULL, ej: al crear o eliminar
+		condiciones = append(condiciones, "NOT (JSON_EXTRACT(antes, ?) <=> JSON_EXTRACT(despues, ?))")
+		args = append(args, "$."+campo, "$."+campo)
+	}
+
+	where := strings.Join(condiciones, " AND ")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	registros := []dto.AuditoriaSelectDTO{}
+	total, err := db.SelectConJoinPaginado(ctx, config.Tablas["au"], nil, nil, &registros, paginacion, where, args...)
+	if err != nil {
+		respuesta.ErrorBD(c, "Error consultando auditoría", err)
+		return
+	}
+
+	respuesta.Paginada(c, registros, total, paginacion)
+}
+
+// parsearFechaAuditoria acepta una fecha (2006-01-02, en hora de Chile) o una fecha y hora RFC 3339.
+// soloFecha indica que vino sin hora.
+func parsearFechaAuditoria(v string) (fecha time.Time, soloFecha bool, err error) {
+	if fecha, err := time.ParseInLocation("2006-01-02", v, config.Chilelocation); err == nil {
+		return fecha, true, nil
+	}
+	fecha, err = time.Parse(time.RFC3339, v)
+	return fecha, false, err
+}
@@ -0,0 +1,74 @@
+package rutas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jgutierrez746/clase_7_gin_bun/config"
+	"github.com/jgutierrez746/clase_7_gin_bun/db"
+	"github.com/jgutierrez746/clase_7_gin_bun/dto"
+	"github.com/jgutierrez746/clase_7_gin_bun/helpers"
+	"github.com/jgutierrez746/clase_7_gin_bun/respuesta"
+)
+
+// columnasBusquedaPeliculas son las columnas del índice FULLTEXT ft_peliculas_busqueda
+var columnasBusquedaPeliculas = []string{"busqueda"}
+
+// BuscarPeliculas responde GET /peliculas/search?q= buscando en título, descripción y director.
+// Los resultados vienen ordenados por relevancia y paginados con ?page= y ?per_page=.
+func BuscarPeliculas(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	palabras := helpers.PalabrasBusqueda(q)
+	if len(palabras) == 0 {
+		respuesta.SolicitudInvalida(c, "Debe ingresar un texto de búsqueda (?q=).")
+		return
+	}
+	consulta := helpers.ConsultaBooleana(q)
+
+	// Sin campos ordenables: el orden lo define la relevancia, con id como desempate
+	paginacion, err := helpers.ParsearPaginacion(c, map[string]string{}, config.Tablas["pl"]+".id DESC")
+	if err != nil {
+		respuesta.SolicitudInvalida(c, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	resultados := []dto.PeliculaBusquedaDTO{}
+	var total int
+	if consulta != "" {
+		total, err = db.BuscarFullText(ctx, config.Tablas["pl"], columnasBusquedaPeliculas, consulta, &resultados, paginacion)
+	} else {
+		// Solo palabras que el índice no tiene (ej: "it"): se buscan con LIKE, sin relevancia
+		total, err = buscarPeliculasLike(ctx, palabras, &resultados, paginacion)
+	}
+	if err != nil {
+		respuesta.ErrorBD(c, "Error buscando películas", err)
+		return
+	}
+
+	log.Printf("Búsqueda %q: %d de %d películas.", q, len(resultados), total)
+	respuesta.Paginada(c, resultados, total, paginacion)
+}
+
+// buscarPeliculasLike busca películas cuya columna busqueda contiene todas las palabras (ya normalizadas, sin
+// comodines de LIKE).
+func buscarPeliculasLike(ctx context.Context, palabras []string, dest *[]dto.PeliculaBusquedaDTO, paginacion db.Paginacion) (int, error) {
+	pl := config.Tablas["pl"]
+	condiciones := make([]string, len(palabras))
+	args := make([]interface{}, len(palabras))
+	for i, palabra := range palabras {
+		condiciones[i] = pl + ".busqueda LIKE ?"
+		args[i] = "%" + palabra + "%"
+	}
+	// Sin relevancia: no hay MATCH que la calcule
+	columnas := []string{
+		fmt.Sprintf("%s.id, %s.anio, %s.titulo, %s.slug, %s.descripcion, %s.director, %s.version, %s.created_at, %s.updated_at", pl, pl, pl, pl, pl, pl, pl, pl, pl),
+	}
+	return db.SelectConJoinPaginado(ctx, pl, nil, columnas, dest, paginacion, strings.Join(condiciones, " AND "), args...)
+}
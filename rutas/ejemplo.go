@@ -1,22 +1,19 @@
 package rutas
 
 import (
-	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jgutierrez746/clase_7_gin_bun/respuesta"
 )
 
 // Funciones de ejemplo, para ver funcionamiento de Gin
 // Grupo usuarios
 // Este será manejado como GET /users
 func GetUsers(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"mensaje": "Lista de usuarios",
-		"users":   []string{"Juan", "María", "Pedro"},
-	})
+	respuesta.OKConMensaje(c, "Lista de usuarios", []string{"Juan", "María", "Pedro"})
 }
 
 // Este será manejado como POST /users
@@ -27,63 +24,46 @@ func CreateUser(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&jsonData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		respuesta.ErrorBind(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"mensaje": "Usuario creado",
-		"user":    jsonData,
-	})
+	respuesta.Creado(c, "Usuario creado", jsonData)
 }
 
 // Grupo admin
 // AdminOnly se maneja con GET /admin/dashboard (ejemploc con middleware simulado)
 func AdminOnly(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"mensaje": "Dashboard admin - acceso restringido",
-	})
+	respuesta.OKConMensaje(c, "Dashboard admin - acceso restringido", nil)
 }
 
 // Ruta general sin grupo
 // Función Saludar
 func Saludar(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"mensaje": "Hola desde Gin!",
-	})
+	respuesta.OKConMensaje(c, "Hola desde Gin!", nil)
 }
 
 func Saludar_con_nombre(c *gin.Context) {
 	nombre := c.Param("nombre")
 	if strings.TrimSpace(nombre) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"mensaje": "Debes enviar un nombre como parametro!",
-		})
+		respuesta.SolicitudInvalida(c, "Debes enviar un nombre como parametro!")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"mensaje": "Hola " + strings.TrimSpace(nombre),
-	})
+	respuesta.OKConMensaje(c, "Hola "+strings.TrimSpace(nombre), nil)
 }
 
 func Query_string(c *gin.Context) {
 	id := c.Query("id")
 	slug := c.Query("slug")
-	c.JSON(http.StatusOK, gin.H{
-		"mensaje": "Parámetros Query String | id = " + id + " | slug = " + slug,
-	})
+	respuesta.OKConMensaje(c, "Parámetros Query String", gin.H{"id": id, "slug": slug})
 }
 
 // Ejemplo de carga de archivos al servidor
 func Ejemplo_upload(c *gin.Context) {
 	file, err := c.FormFile("foto")
 	if err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"mensaje": "Ocurrió un error inesperado!",
-		})
+		respuesta.SolicitudInvalida(c, "Ocurrió un error inesperado!")
 		return
 	}
 
@@ -94,8 +74,5 @@ func Ejemplo_upload(c *gin.Context) {
 
 	c.SaveUploadedFile(file, archivo)
 
-	c.JSON(http.StatusOK, gin.H{
-		"mensaje":     "Archivo cargado exitosamente",
-		"nombre_foto": nombreArchivo,
-	})
+	respuesta.OKConMensaje(c, "Archivo cargado exitosamente", gin.H{"nombre_foto": nombreArchivo})
 }
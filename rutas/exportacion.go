@@ -0,0 +1,261 @@
+package rutas
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jgutierrez746/clase_7_gin_bun/config"
+	"github.com/jgutierrez746/clase_7_gin_bun/db"
+	"github.com/jgutierrez746/clase_7_gin_bun/dto"
+	"github.com/jgutierrez746/clase_7_gin_bun/helpers"
+	"github.com/jgutierrez746/clase_7_gin_bun/respuesta"
+)
+
+// Cada cuántas películas se envía al cliente lo acumulado en los buffers.
+const filasPorFlushExportacion = 200
+
+// Columnas de la exportación en CSV y XLSX. Las primeras coinciden con las de POST /peliculas/import.
+var columnasExportacion = []string{"id", "anio", "titulo", "slug", "descripcion", "director", "tematicas", "portada_url", "portada_archivo", "created_at", "updated_at"}
+
+// escritorExportacion escribe las películas en un formato de exportación.
+type escritorExportacion interface {
+	escribir(p dto.PeliculaExport) error
+	vaciar() error // Escribe en la respuesta lo que esté en buffer
+	cerrar() error // Termina el archivo, no cierra la respuesta
+}
+
+// ExportarPeliculas descarga el catálogo completo (GET /peliculas/export?format=csv|ndjson|xlsx) con las temáticas
+// y la imagen principal de cada película. Las filas se leen con un cursor de la BD y se escriben a medida que llegan,
+// así el consumo de memoria no depende del tamaño del catálogo.
+// portada_url es la misma URL firmada del resto de la API y vence tras IMAGENES_URL_TTL; para un archivo que se
+// guarda y se usa después, portada_archivo trae el nombre del archivo, que no cambia y sirve para pedir una URL nueva
+// (ej: GET /peliculas/:id/portada). No se firma con un TTL más largo para no dejar enlaces válidos por semanas.
+func ExportarPeliculas(c *gin.Context) {
+	formato := c.DefaultQuery("format", "csv")
+
+	var tipoContenido string
+	switch formato {
+	case "csv":
+		tipoContenido = "text/csv; charset=utf-8"
+	case "ndjson":
+		tipoContenido = "application/x-ndjson"
+	case "xlsx":
+		tipoContenido = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		respuesta.SolicitudInvalida(c, "Formato no soportado, usar ?format=csv|ndjson|xlsx")
+		return
+	}
+
+	// Un catálogo grande tarda más que los 5 segundos de las consultas normales
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Minute)
+	defer cancel()
+	// y que el WriteTimeout del servidor
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(10 * time.Minute)); err != nil {
+		log.Printf("No se pudo extender el plazo de escritura de la exportación: %v", err)
+	}
+
+	nombre := fmt.Sprintf("peliculas-%s.%s", time.Now().In(config.Chilelocation).Format("20060102"), formato)
+	c.Header("Content-Type", tipoContenido)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, nombre))
+	c.Status(http.StatusOK)
+
+	var escritor escritorExportacion
+	switch formato {
+	case "csv":
+		escritor = nuevoExportadorCSV(c.Writer)
+	case "ndjson":
+		escritor = &exportadorNDJSON{w: bufio.NewWriter(c.Writer)}
+	case "xlsx":
+		x, err := helpers.NuevoEscritorXLSX(c.Writer, "Películas")
+		if err == nil {
+			err = x.Fila(stringsComoCeldas(columnasExportacion)...)
+		}
+		if err != nil {
+			log.Printf("Error iniciando exportación XLSX: %v", err)
+			return
+		}
+		escritor = &exportadorXLSX{x: x}
+	}
+
+	total, err := recorrerPeliculasExportacion(ctx, c, func(p dto.PeliculaExport, n int) error {
+		if err := escritor.escribir(p); err != nil {
+			return err
+		}
+		if n%filasPorFlushExportacion == 0 {
+			if err := escritor.vaciar(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = escritor.cerrar()
+	}
+	if err != nil {
+		// Los headers ya se enviaron, no se puede responder un error: el cliente recibe un archivo truncado
+		log.Printf("Error exportando películas en %s después de %d filas: %v", formato, total, err)
+		return
+	}
+
+	log.Printf("Se exportaron %d películas en %s.", total, formato)
+}
+
+// recorrerPeliculasExportacion recorre las películas por id con sus temáticas e imagen principal, llamando a fn con
+// cada película completa y su posición (desde 1). Retorna la cantidad de películas entregadas.
+func recorrerPeliculasExportacion(ctx context.Context, c *gin.Context, fn func(p dto.PeliculaExport, n int) error) (int, error) {
+	pl := config.Tablas["pl"]
+	pt := config.Tablas["pt"]
+	tm := config.Tablas["tm"]
+	pp := config.Tablas["pp"]
+
+	joins := []string{
+		fmt.Sprintf("LEFT JOIN %s ON %s.p_id = %s.id", pt, pt, pl),
+		fmt.Sprintf("LEFT JOIN %s ON %s.id = %s.tematica_id AND %s.deleted_at IS NULL", tm, tm, pt, tm),
+		fmt.Sprintf("LEFT JOIN %s ON %s.p_id = %s.id AND %s.is_primary = TRUE", pp, pp, pl, pp),
+	}
+	columnas := []string{
+		fmt.Sprintf("%s.id, %s.anio, %s.titulo, %s.slug, %s.descripcion, %s.director, %s.created_at, %s.updated_at", pl, pl, pl, pl, pl, pl, pl, pl),
+		fmt.Sprintf("%s.id AS tematica_id, %s.slug AS slug_tem, %s.orden", tm, tm, pt),
+		fmt.Sprintf("%s.nombre_archivo AS portada", pp),
+	}
+
+	// Las filas de una película llegan seguidas por el ORDER BY, se acumulan hasta que cambia el id
+	var actual *dto.PeliculaExport
+	total := 0
+	err := db.RecorrerConJoin(ctx, pl, joins, columnas, pl+".id ASC, "+pt+".orden ASC", func(r dto.PeliculaExportRow) error {
+		if actual != nil && actual.ID != r.ID {
+			total++
+			if err := fn(*actual, total); err != nil {
+				return err
+			}
+			actual = nil
+		}
+		if actual == nil {
+			actual = &dto.PeliculaExport{
+				ID:          r.ID,
+				Anio:        r.Anio,
+				Titulo:      r.Titulo,
+				Slug:        r.Slug,
+				Descripcion: r.Descripcion,
+				Director:    r.Director,
+				Tematicas:   []string{},
+				CreatedAt:   r.CreatedAt,
+				UpdatedAt:   r.UpdatedAt,
+			}
+			if r.Portada != "" {
+				actual.PortadaUrl = urlPortada(c, r.Portada)
+				actual.PortadaArchivo = r.Portada
+			}
+		}
+		if r.TematicaID != 0 {
+			actual.Tematicas = append(actual.Tematicas, r.SlugTem)
+		}
+		return nil
+	}, "")
+	if err != nil {
+		return total, err
+	}
+	if actual != nil {
+		total++
+		if err := fn(*actual, total); err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+type exportadorCSV struct {
+	w *csv.Writer
+}
+
+func nuevoExportadorCSV(w http.ResponseWriter) *exportadorCSV {
+	e := &exportadorCSV{w: csv.NewWriter(w)}
+	e.w.Write(columnasExportacion)
+	return e
+}
+
+func (e *exportadorCSV) escribir(p dto.PeliculaExport) error {
+	return e.w.Write([]string{
+		strconv.FormatInt(p.ID, 10),
+		strconv.Itoa(p.Anio),
+		textoCSV(p.Titulo),
+		p.Slug,
+		textoCSV(p.Descripcion),
+		textoCSV(p.Director),
+		strings.Join(p.Tematicas, "|"),
+		p.PortadaUrl,
+		p.PortadaArchivo,
+		p.CreatedAt.Format(time.RFC3339),
+		p.UpdatedAt.Format(time.RFC3339),
+	})
+}
+
+func (e *exportadorCSV) vaciar() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *exportadorCSV) cerrar() error {
+	return e.vaciar()
+}
+
+// textoCSV antepone un apóstrofo a los textos que Excel interpretaría como fórmula (ej: "=HYPERLINK(...)").
+func textoCSV(texto string) string {
+	if texto != "" && strings.ContainsRune("=+-@\t\r", rune(texto[0])) {
+		return "'" + texto
+	}
+	return texto
+}
+
+type exportadorNDJSON struct {
+	w *bufio.Writer
+}
+
+func (e *exportadorNDJSON) escribir(p dto.PeliculaExport) error {
+	// Encode agrega el salto de línea que separa los objetos
+	return json.NewEncoder(e.w).Encode(p)
+}
+
+func (e *exportadorNDJSON) vaciar() error {
+	return e.w.Flush()
+}
+
+func (e *exportadorNDJSON) cerrar() error {
+	return e.vaciar()
+}
+
+type exportadorXLSX struct {
+	x *helpers.EscritorXLSX
+}
+
+func (e *exportadorXLSX) escribir(p dto.PeliculaExport) error {
+	return e.x.Fila(p.ID, p.Anio, p.Titulo, p.Slug, p.Descripcion, p.Director,
+		strings.Join(p.Tematicas, "|"), p.PortadaUrl, p.PortadaArchivo, p.CreatedAt, p.UpdatedAt)
+}
+
+// vaciar no hace nada: el zip no se puede abrir hasta que está completo, no sirve enviarlo por partes.
+func (e *exportadorXLSX) vaciar() error {
+	return nil
+}
+
+func (e *exportadorXLSX) cerrar() error {
+	return e.x.Cerrar()
+}
+
+func stringsComoCeldas(textos []string) []interface{} {
+	celdas := make([]interface{}, len(textos))
+	for i, t := range textos {
+		celdas[i] = t
+	}
+	return celdas
+}
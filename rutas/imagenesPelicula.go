@@ -0,0 +1,415 @@
+package rutas
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jgutierrez746/clase_7_gin_bun/config"
+	"github.com/jgutierrez746/clase_7_gin_bun/db"
+	"github.com/jgutierrez746/clase_7_gin_bun/dto"
+	"github.com/jgutierrez746/clase_7_gin_bun/helpers"
+	"github.com/jgutierrez746/clase_7_gin_bun/respuesta"
+	"github.com/uptrace/bun"
+)
+
+// Una película tiene varias imágenes (tabla portada_pelicula) de tipo poster, backdrop o still,
+// ordenadas por tipo y orden. Una sola es la principal (is_primary) y es la que se entrega como portada.
+
+var (
+	errPeliculaNoEncontrada = errors.New("película no encontrada")
+	errImagenNoEncontrada   = errors.New("imagen no encontrada")
+	errImagenesAjenas       = errors.New("uno o más ids no corresponden a imágenes de la película")
+)
+
+// imagenPrincipal e imagenOrden actualizan una sola columna de portada_pelicula.
+type imagenPrincipal struct {
+	IsPrimary bool `bun:"is_primary"`
+}
+
+type imagenOrden struct {
+	Orden int `bun:"orden"`
+}
+
+// bloquearPelicula toma un lock sobre la fila de la película hasta el fin de la transacción,
+// así las operaciones concurrentes sobre sus imágenes no se pisan (ej: dos principales).
+// También sube la versión de la película: sus imágenes y temáticas son parte de ella, y su ETag debe cambiar.
+func bloquearPelicula(ctx context.Context, tx bun.IDB, id int64) error {
+	var pelicula struct {
+		ID      int64 `bun:"id"`
+		Version int64 `bun:"version"`
+	}
+	if err := db.SelectOneTx(ctx, tx, config.Tablas["pl"], &pelicula, true, "id = ?", id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errPeliculaNoEncontrada
+		}
+		return err
+	}
+	_, err := db.UpdateConVersionTx(ctx, tx, config.Tablas["pl"], &map[string]interface{}{}, pelicula.Version, "id = ?", id)
+	return err
+}
+
+// responderErrorImagen traduce los errores de las transacciones de imágenes a la respuesta HTTP.
+func responderErrorImagen(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errPeliculaNoEncontrada), errors.Is(err, errImagenNoEncontrada):
+		respuesta.NoEncontrado(c, err.Error())
+	case errors.Is(err, errImagenesAjenas):
+		respuesta.Error(c, http.StatusUnprocessableEntity, respuesta.CodigoEntidadInvalida, err.Error())
+	default:
+		respuesta.ErrorBD(c, "Error registrando en BD", err)
+	}
+}
+
+func parsearIDsImagen(c *gin.Context) (int64, int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
+		return 0, 0, false
+	}
+	idf := int64(0)
+	if c.Param("idf") != "" {
+		if idf, err = strconv.ParseInt(c.Param("idf"), 10, 64); err != nil {
+			respuesta.SolicitudInvalida(c, "Parámetro inválido.")
+			return 0, 0, false
+		}
+	}
+	return id, idf, true
+}
+
+// subirImagenPelicula valida el archivo del campo "file", lo guarda con sus miniaturas y lo registra al final
+// de las imágenes de su tipo. Queda como principal si principal es true o si la película no tenía principal.
+// Si algo falla responde el error y retorna false.
+func subirImagenPelicula(c *gin.Context, id int64, tipo string, principal bool) (dto.PortadaSelectDTO, bool) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		respuesta.SolicitudInvalida(c, "No se subió ningún archivo: "+err.Error())
+		return dto.PortadaSelectDTO{}, false
+	}
+
+	if file.Size > helpers.TamanoMaximoImagen {
+		respuesta.Error(c, http.StatusRequestEntityTooLarge, respuesta.CodigoMuyGrande, helpers.ErrImagenMuyGrande.Error())
+		return dto.PortadaSelectDTO{}, false
+	}
+
+	// Validar y normalizar la imagen (tipo por contenido, límites, sin EXIF, miniaturas)
+	origen, err := file.Open()
+	if err != nil {
+		respuesta.ErrorInterno(c, "Error leyendo archivo", err)
+		return dto.PortadaSelectDTO{}, false
+	}
+	defer origen.Close()
+
+	imagen, err := helpers.ProcesarImagen(origen)
+	if err != nil {
+		switch {
+		case errors.Is(err, helpers.ErrImagenMuyGrande):
+			respuesta.Error(c, http.StatusRequestEntityTooLarge, respuesta.CodigoMuyGrande, err.Error())
+		case errors.Is(err, helpers.ErrFormatoImagen):
+			respuesta.Error(c, http.StatusUnsupportedMediaType, respuesta.CodigoTipoNoSoportado, err.Error())
+		case errors.Is(err, helpers.ErrDimensionesImagen):
+			respuesta.Error(c, http.StatusUnprocessableEntity, respuesta.CodigoEntidadInvalida, err.Error())
+		default:
+			respuesta.ErrorInterno(c, "Error procesando imagen", err)
+		}
+		return dto.PortadaSelectDTO{}, false
+	}
+
+	// La extensión sale del formato real, no del nombre enviado por el cliente
+	nuevoNombre := fmt.Sprintf("%d_%d%s", id, time.Now().UnixNano(), imagen.Extension)
+
+	// Guardar archivos antes de tocar la BD; si la transacción falla se borran
+	if err := guardarArchivosPortada(c.Request.Context(), nuevoNombre, imagen); err != nil {
+		respuesta.ErrorInterno(c, "Error guardando archivo", err)
+		return dto.PortadaSelectDTO{}, false
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	nueva := dto.PortadaInsertDTO{
+		PID:           id,
+		NombreArchivo: nuevoNombre,
+		Tipo:          tipo,
+		CreatedAt:     time.Now().In(config.Chilelocation),
+	}
+	err = db.WithTx(ctx, func(tx bun.IDB) error {
+		if err := bloquearPelicula(ctx, tx, id); err != nil {
+			return err
+		}
+
+		var existentes []dto.PortadaSelectDTO
+		if err := db.SelectTx(ctx, tx, config.Tablas["pp"], &existentes, "", "p_id = ?", id); err != nil {
+			return err
+		}
+		tienePrincipal := false
+		for _, e := range existentes {
+			if e.Tipo == tipo && e.Orden > nueva.Orden {
+				nueva.Orden = e.Orden
+			}
+			tienePrincipal = tienePrincipal || e.IsPrimary
+		}
+		nueva.Orden++
+		nueva.IsPrimary = principal || !tienePrincipal
+
+		if nueva.IsPrimary && tienePrincipal {
+			if _, err := db.UpdateTx(ctx, tx, config.Tablas["pp"], &imagenPrincipal{IsPrimary: false}, "p_id = ?", id); err != nil {
+				return err
+			}
+		}
+		if err := db.InsertTx(ctx, tx, config.Tablas["pp"], &nueva); err != nil {
+			return err
+		}
+		return auditarTx(ctx, c, tx, accionCrear, config.Tablas["pp"], nueva.ID, nil, nueva)
+	})
+	if err != nil {
+		borrarArchivosPortada(c.Request.Context(), nuevoNombre)
+		responderErrorImagen(c, err)
+		return dto.PortadaSelectDTO{}, false
+	}
+
+	subida := dto.PortadaSelectDTO{
+		ID:            nueva.ID,
+		PID:           nueva.PID,
+		NombreArchivo: nueva.NombreArchivo,
+		Tipo:          nueva.Tipo,
+		Orden:         nueva.Orden,
+		IsPrimary:     nueva.IsPrimary,
+		CreatedAt:     nueva.CreatedAt,
+	}
+	completarPortada(c, &subida)
+	return subida, true
+}
+
+// consultarImagenes retorna las imágenes de la película ordenadas por tipo y orden, con sus URLs.
+func consultarImagenes(ctx context.Context, c *gin.Context, id int64, tipo string) ([]dto.PortadaSelectDTO, error) {
+	where := "p_id = ?"
+	args := []interface{}{id}
+	if tipo != "" {
+		where += " AND tipo = ?"
+		args = append(args, tipo)
+	}
+
+	imagenes := []dto.PortadaSelectDTO{}
+	if err := db.SelectConJoin(ctx, config.Tablas["pp"], nil, nil, &imagenes, "tipo ASC, orden ASC, id ASC", where, args...); err != nil {
+		return nil, err
+	}
+	for i := range imagenes {
+		completarPortada(c, &imagenes[i])
+	}
+	return imagenes, nil
+}
+
+// ConsultarImagenesPelicula lista la galería de la película. Acepta ?tipo=poster|backdrop|still.
+func ConsultarImagenesPelicula(c *gin.Context) {
+	id, _, ok := parsearIDsImagen(c)
+	if !ok {
+		return
+	}
+
+	tipo := c.Query("tipo")
+	switch tipo {
+	case "", "poster", "backdrop", "still":
+	default:
+		respuesta.SolicitudInvalida(c, "Tipo inválido (opciones: poster, backdrop, still)")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	imagenes, err := consultarImagenes(ctx, c, id, tipo)
+	if err != nil {
+		respuesta.ErrorBD(c, "Error consultando imágenes", err)
+		return
+	}
+
+	respuesta.Lista(c, imagenes)
+}
+
+// CrearImagenPelicula agrega una imagen a la galería (multipart: file, tipo y opcionalmente principal=true).
+func CrearImagenPelicula(c *gin.Context) {
+	id, _, ok := parsearIDsImagen(c)
+	if !ok {
+		return
+	}
+
+	var form dto.ImagenInsertForm
+	if err := c.ShouldBind(&form); err != nil {
+		respuesta.ErrorBind(c, err)
+		return
+	}
+
+	imagen, ok := subirImagenPelicula(c, id, form.Tipo, form.Principal)
+	if !ok {
+		return
+	}
+
+	respuesta.Creado(c, "Imagen subida correctamente", imagen)
+}
+
+// OrdenarImagenesPelicula asigna orden = posición + 1 a cada id enviado. Los ids no enviados conservan su orden.
+func OrdenarImagenesPelicula(c *gin.Context) {
+	id, _, ok := parsearIDsImagen(c)
+	if !ok {
+		return
+	}
+
+	var input dto.ImagenesOrden
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respuesta.ErrorBind(c, err)
+		return
+	}
+
+	unicos := make(map[int64]bool, len(input.IDs))
+	for _, idf := range input.IDs {
+		if unicos[idf] {
+			respuesta.SolicitudInvalida(c, fmt.Sprintf("id %d repetido", idf))
+			return
+		}
+		unicos[idf] = true
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	err := db.WithTx(ctx, func(tx bun.IDB) error {
+		if err := bloquearPelicula(ctx, tx, id); err != nil {
+			return err
+		}
+
+		var propias []dto.PortadaSelectDTO
+		if err := db.SelectTx(ctx, tx, config.Tablas["pp"], &propias, "", "p_id = ? AND id IN (?)", id, bun.In(input.IDs)); err != nil {
+			return err
+		}
+		if len(propias) != len(input.IDs) {
+			return errImagenesAjenas
+		}
+
+		for i, idf := range input.IDs {
+			if _, err := db.UpdateTx(ctx, tx, config.Tablas["pp"], &imagenOrden{Orden: i + 1}, "id = ?", idf); err != nil {
+				return err
+			}
+		}
+
+		var ordenadas []dto.PortadaSelectDTO
+		if err := db.SelectTx(ctx, tx, config.Tablas["pp"], &ordenadas, "", "p_id = ? AND id IN (?)", id, bun.In(input.IDs)); err != nil {
+			return err
+		}
+		return auditarTx(ctx, c, tx, accionActualizar, config.Tablas["pp"], id, propias, ordenadas)
+	})
+	if err != nil {
+		responderErrorImagen(c, err)
+		return
+	}
+
+	imagenes, err := consultarImagenes(ctx, c, id, "")
+	if err != nil {
+		respuesta.ErrorBD(c, "Error consultando imágenes", err)
+		return
+	}
+
+	respuesta.OKConMensaje(c, "Imágenes reordenadas correctamente", imagenes)
+}
+
+// PromoverImagenPelicula deja la imagen :idf como principal (portada) de la película.
+func PromoverImagenPelicula(c *gin.Context) {
+	id, idf, ok := parsearIDsImagen(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	err := db.WithTx(ctx, func(tx bun.IDB) error {
+		if err := bloquearPelicula(ctx, tx, id); err != nil {
+			return err
+		}
+
+		var imagen dto.PortadaSelectDTO
+		if err := db.SelectOneTx(ctx, tx, config.Tablas["pp"], &imagen, false, "id = ? AND p_id = ?", idf, id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errImagenNoEncontrada
+			}
+			return err
+		}
+
+		if _, err := db.UpdateTx(ctx, tx, config.Tablas["pp"], &imagenPrincipal{IsPrimary: false}, "p_id = ?", id); err != nil {
+			return err
+		}
+		if _, err := db.UpdateTx(ctx, tx, config.Tablas["pp"], &imagenPrincipal{IsPrimary: true}, "id = ?", idf); err != nil {
+			return err
+		}
+		promovida := imagen
+		promovida.IsPrimary = true
+		return auditarTx(ctx, c, tx, accionActualizar, config.Tablas["pp"], idf, imagen, promovida)
+	})
+	if err != nil {
+		responderErrorImagen(c, err)
+		return
+	}
+
+	respuesta.OKConMensaje(c, "Imagen principal actualizada correctamente", gin.H{"id": idf, "p_id": id})
+}
+
+// EliminarImagenPelicula borra la imagen :idf de la película. Si era la principal,
+// pasa a serlo el primer poster restante (o la primera imagen si no quedan posters).
+func EliminarImagenPelicula(c *gin.Context) {
+	id, idf, ok := parsearIDsImagen(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	var imagen dto.PortadaSelectDTO
+	err := db.WithTx(ctx, func(tx bun.IDB) error {
+		if err := bloquearPelicula(ctx, tx, id); err != nil {
+			return err
+		}
+
+		if err := db.SelectOneTx(ctx, tx, config.Tablas["pp"], &imagen, false, "id = ? AND p_id = ?", idf, id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errImagenNoEncontrada
+			}
+			return err
+		}
+		if _, err := db.DeleteTx(ctx, tx, config.Tablas["pp"], "id = ?", idf); err != nil {
+			return err
+		}
+		if err := auditarTx(ctx, c, tx, accionEliminar, config.Tablas["pp"], idf, imagen, nil); err != nil {
+			return err
+		}
+		if !imagen.IsPrimary {
+			return nil
+		}
+
+		var restantes []dto.PortadaSelectDTO
+		if err := db.SelectTx(ctx, tx, config.Tablas["pp"], &restantes, "tipo = 'poster' DESC, orden ASC, id ASC", "p_id = ?", id); err != nil {
+			return err
+		}
+		if len(restantes) == 0 {
+			return nil
+		}
+		_, err := db.UpdateTx(ctx, tx, config.Tablas["pp"], &imagenPrincipal{IsPrimary: true}, "id = ?", restantes[0].ID)
+		return err
+	})
+	if err != nil {
+		responderErrorImagen(c, err)
+		return
+	}
+
+	// Con la transacción confirmada se borran el archivo y sus miniaturas
+	borrarArchivosPortada(c.Request.Context(), imagen.NombreArchivo)
+
+	log.Printf("Imagen %d eliminada de la película %d", idf, id)
+	respuesta.Eliminado(c, "Imagen eliminada correctamente", 1)
+}
@@ -0,0 +1,388 @@
+package rutas
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gosimple/slug"
+	"github.com/jgutierrez746/clase_7_gin_bun/config"
+	"github.com/jgutierrez746/clase_7_gin_bun/db"
+	"github.com/jgutierrez746/clase_7_gin_bun/dto"
+	"github.com/jgutierrez746/clase_7_gin_bun/helpers"
+	"github.com/jgutierrez746/clase_7_gin_bun/respuesta"
+	"github.com/uptrace/bun"
+)
+
+// Límites de POST /peliculas/import.
+const (
+	tamanoMaximoImportacion = 20 << 20 // 20 MB
+	filasMaximasImportacion = 10000
+	loteImportacion         = 500 // Filas por INSERT
+)
+
+// filaImportada es una fila ya leída del archivo, con su número de línea para reportar errores.
+type filaImportada struct {
+	linea    int
+	pelicula dto.PeliculaImport
+	errores  []string
+	ilegible bool // La línea no se pudo decodificar, no se valida nada más
+}
+
+// ImportarPeliculas carga películas en lote desde CSV o NDJSON (POST /peliculas/import).
+//   - El formato se toma de ?format=csv|ndjson o del Content-Type (text/csv, application/x-ndjson).
+//   - CSV: la primera fila es el encabezado con anio, titulo, descripcion, director y opcionalmente tematicas
+//     (slugs separados por "|").
+//   - Cada fila se valida con las mismas reglas que CrearPelicula y se revisa que las temáticas existan.
+//     Los títulos repetidos (en la BD o en el archivo) reciben otro slug, igual que en CrearPelicula.
+//   - Con ?dry_run=true solo se valida. Si no, se inserta todo en una transacción, y si alguna fila
+//     tiene errores no se inserta ninguna.
+func ImportarPeliculas(c *gin.Context) {
+	formato := c.Query("format")
+	if formato == "" {
+		tipo, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		switch tipo {
+		case "text/csv":
+			formato = "csv"
+		case "application/x-ndjson", "application/ndjson", "application/jsonl":
+			formato = "ndjson"
+		}
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, tamanoMaximoImportacion)
+
+	var filas []filaImportada
+	var err error
+	switch formato {
+	case "csv":
+		filas, err = leerCSVPeliculas(c.Request.Body)
+	case "ndjson":
+		filas, err = leerNDJSONPeliculas(c.Request.Body)
+	default:
+		respuesta.Error(c, http.StatusUnsupportedMediaType, respuesta.CodigoTipoNoSoportado, "Formato no soportado, usar ?format=csv|ndjson o Content-Type text/csv o application/x-ndjson")
+		return
+	}
+	if err != nil {
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			respuesta.Error(c, http.StatusRequestEntityTooLarge, respuesta.CodigoMuyGrande, fmt.Sprintf("El archivo supera el máximo de %d MB", tamanoMaximoImportacion>>20))
+			return
+		}
+		respuesta.SolicitudInvalida(c, "Error leyendo archivo: "+err.Error())
+		return
+	}
+	if len(filas) == 0 {
+		respuesta.SolicitudInvalida(c, "El archivo no tiene filas")
+		return
+	}
+	if len(filas) > filasMaximasImportacion {
+		respuesta.Error(c, http.StatusRequestEntityTooLarge, respuesta.CodigoMuyGrande, fmt.Sprintf("El archivo supera el máximo de %d filas", filasMaximasImportacion))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Minute)
+	defer cancel()
+
+	idsTematicas, err := validarFilasImportadas(ctx, filas)
+	if err != nil {
+		respuesta.ErrorBD(c, "Error validando filas", err)
+		return
+	}
+
+	errores := []dto.ErrorImportacion{}
+	for _, f := range filas {
+		if len(f.errores) > 0 {
+			errores = append(errores, dto.ErrorImportacion{Fila: f.linea, Titulo: f.pelicula.Titulo, Errores: f.errores})
+		}
+	}
+
+	if c.Query("dry_run") == "true" {
+		respuesta.OK(c, gin.H{
+			"dry_run": true,
+			"total":   len(filas),
+			"validas": len(filas) - len(errores),
+			"errores": errores,
+		})
+		return
+	}
+
+	if len(errores) > 0 {
+		respuesta.ErrorConDetalles(c, http.StatusUnprocessableEntity, respuesta.CodigoValidacion,
+			fmt.Sprintf("El archivo tiene %d filas inválidas de %d, no se importó ninguna", len(errores), len(filas)), errores)
+		return
+	}
+
+	asociadas, err := insertarFilasImportadas(ctx, c, filas, idsTematicas)
+	if err != nil {
+		respuesta.ErrorBD(c, "Error importando películas", err)
+		return
+	}
+
+	log.Printf("Importación de películas: %d insertadas", len(filas))
+	respuesta.Creado(c, "Películas importadas correctamente", gin.H{
+		"insertadas":          len(filas),
+		"tematicas_asociadas": asociadas,
+	})
+}
+
+// leerCSVPeliculas lee un CSV con encabezado. Los errores de una fila (ej: anio no numérico) quedan en la fila;
+// solo un CSV mal formado o un encabezado inválido detienen la lectura.
+func leerCSVPeliculas(r io.Reader) ([]filaImportada, error) {
+	lector := csv.NewReader(r)
+	lector.TrimLeadingSpace = true
+
+	encabezado, err := lector.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columnas := make(map[string]int, len(encabezado))
+	for i, nombre := range encabezado {
+		nombre = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(nombre, "\ufeff"))) // Excel agrega BOM
+		switch nombre {
+		case "anio", "titulo", "descripcion", "director", "tematicas":
+			columnas[nombre] = i
+		default:
+			return nil, fmt.Errorf("columna desconocida %q (columnas: anio, titulo, descripcion, director, tematicas)", nombre)
+		}
+	}
+	for _, requerida := range []string{"anio", "titulo", "descripcion", "director"} {
+		if _, ok := columnas[requerida]; !ok {
+			return nil, fmt.Errorf("falta la columna %q", requerida)
+		}
+	}
+
+	campo := func(registro []string, nombre string) string {
+		if i, ok := columnas[nombre]; ok && i < len(registro) {
+			return strings.TrimSpace(registro[i])
+		}
+		return ""
+	}
+
+	var filas []filaImportada
+	for {
+		registro, err := lector.Read()
+		if err == io.EOF {
+			return filas, nil
+		}
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			return nil, err
+		}
+		linea, _ := lector.FieldPos(0)
+		if err != nil {
+			// La fila se lee igual; se reporta como error de esa fila sin abortar el archivo
+			filas = append(filas, filaImportada{linea: linea, ilegible: true,
+				errores: []string{fmt.Sprintf("se esperaban %d columnas, hay %d", len(encabezado), len(registro))}})
+			continue
+		}
+
+		f := filaImportada{linea: linea}
+		f.pelicula.Titulo = campo(registro, "titulo")
+		f.pelicula.Descripcion = campo(registro, "descripcion")
+		f.pelicula.Director = campo(registro, "director")
+		if anio := campo(registro, "anio"); anio != "" {
+			if f.pelicula.Anio, err = strconv.Atoi(anio); err != nil {
+				f.errores = append(f.errores, fmt.Sprintf("anio: %q no es un número", anio))
+			}
+		}
+		if tematicas := campo(registro, "tematicas"); tematicas != "" {
+			f.pelicula.Tematicas = strings.Split(tematicas, "|")
+		}
+		filas = append(filas, f)
+	}
+}
+
+// leerNDJSONPeliculas lee un objeto JSON por línea, ignorando líneas vacías.
+func leerNDJSONPeliculas(r io.Reader) ([]filaImportada, error) {
+	lector := bufio.NewScanner(r)
+	lector.Buffer(make([]byte, 64*1024), 1<<20) // Líneas de hasta 1 MB
+
+	var filas []filaImportada
+	for linea := 1; lector.Scan(); linea++ {
+		texto := bytes.TrimSpace(lector.Bytes())
+		if len(texto) == 0 {
+			continue
+		}
+
+		f := filaImportada{linea: linea}
+		decoder := json.NewDecoder(bytes.NewReader(texto))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&f.pelicula); err != nil {
+			f.errores = append(f.errores, "JSON inválido: "+err.Error())
+			f.ilegible = true
+		}
+		filas = append(filas, f)
+	}
+	return filas, lector.Err()
+}
+
+// validarFilasImportadas completa cada fila (búsqueda, timestamps) y agrega sus errores de validación.
+// Retorna el id de cada temática referenciada, por slug.
+func validarFilasImportadas(ctx context.Context, filas []filaImportada) (map[string]int64, error) {
+	nowChile := time.Now().In(config.Chilelocation)
+
+	slugsTematicas := []string{}
+	for i := range filas {
+		p := &filas[i].pelicula
+		p.ID = 0    // El id lo asigna la BD aunque venga en el archivo
+		p.Slug = "" // El slug se asigna al insertar (ver asignarSlugsImportados)
+		p.Busqueda = helpers.NormalizarBusqueda(p.Titulo, p.Descripcion, p.Director)
+		p.CreatedAt = nowChile
+		p.UpdatedAt = nowChile
+
+		for j, t := range p.Tematicas {
+			p.Tematicas[j] = slug.Make(t) // Acepta tanto "ciencia-ficcion" como "Ciencia Ficción"
+			slugsTematicas = append(slugsTematicas, p.Tematicas[j])
+		}
+	}
+
+	idsTematicas := make(map[string]int64)
+	if len(slugsTematicas) > 0 {
+		var tematicas []struct {
+			ID   int64  `bun:"id"`
+			Slug string `bun:"slug"`
+		}
+		if err := db.SelectConJoin(ctx, config.Tablas["tm"], nil, []string{"id", "slug"}, &tematicas, "", "slug IN (?)", bun.In(slugsTematicas)); err != nil {
+			return nil, err
+		}
+		for _, t := range tematicas {
+			idsTematicas[t.Slug] = t.ID
+		}
+	}
+
+	for i := range filas {
+		f := &filas[i]
+		if f.ilegible {
+			continue
+		}
+		f.errores = append(f.errores, helpers.Validar(&f.pelicula)...)
+
+		vistas := make(map[string]bool, len(f.pelicula.Tematicas))
+		for _, t := range f.pelicula.Tematicas {
+			switch {
+			case t == "":
+				f.errores = append(f.errores, "tematicas: slug vacío")
+			case vistas[t]:
+				f.errores = append(f.errores, fmt.Sprintf("tematicas: %q repetida", t))
+			case idsTematicas[t] == 0:
+				f.errores = append(f.errores, fmt.Sprintf("tematicas: no existe la temática %q", t))
+			}
+			vistas[t] = true
+		}
+	}
+	return idsTematicas, nil
+}
+
+// asignarSlugsImportados asigna a cada película un slug libre igual que CrearPelicula (ver slugPelicula), sin repetir
+// los que ya tomaron filas anteriores del mismo archivo.
+func asignarSlugsImportados(ctx context.Context, tx bun.IDB, filas []filaImportada) error {
+	usados := make(map[string]bool)      // Slugs ocupados en la BD y los ya asignados en el archivo
+	consultadas := make(map[string]bool) // Bases cuyos slugs de la BD ya están en usados
+	for i := range filas {
+		p := &filas[i].pelicula
+		base, alternativas := candidatosSlugPelicula(p.Titulo, p.Anio)
+		if !consultadas[base] {
+			if err := slugsOcupadosPelicula(ctx, tx, base, 0, usados); err != nil {
+				return err
+			}
+			consultadas[base] = true
+		}
+		p.Slug = db.SlugLibre(usados, base, alternativas...)
+		usados[p.Slug] = true
+	}
+	return nil
+}
+
+// insertarFilasImportadas inserta las películas y sus temáticas en una sola transacción, con un registro de
+// auditoría por película. Retorna la cantidad de temáticas asociadas.
+func insertarFilasImportadas(ctx context.Context, c *gin.Context, filas []filaImportada, idsTematicas map[string]int64) (int64, error) {
+	var asociadas int64
+	err := conReintentoSlug(func() error {
+		asociadas = 0
+		return db.WithTx(ctx, func(tx bun.IDB) error {
+			if err := asignarSlugsImportados(ctx, tx, filas); err != nil {
+				return err
+			}
+
+			peliculas := make([]dto.PeliculaInsert, len(filas))
+			slugs := make([]string, len(filas))
+			for i, f := range filas {
+				peliculas[i] = f.pelicula.PeliculaInsert
+				slugs[i] = f.pelicula.Slug
+			}
+			for inicio := 0; inicio < len(peliculas); inicio += loteImportacion {
+				fin := min(inicio+loteImportacion, len(peliculas))
+				if _, err := db.InsertBatchTx(ctx, tx, config.Tablas["pl"], peliculas[inicio:fin]); err != nil {
+					return err
+				}
+			}
+
+			// Los ids se buscan por slug (único), que no depende de cómo el driver reporte los ids de un INSERT múltiple
+			var insertadas []struct {
+				ID   int64  `bun:"id"`
+				Slug string `bun:"slug"`
+			}
+			if err := db.SelectTx(ctx, tx, config.Tablas["pl"], &insertadas, "", "slug IN (?)", bun.In(slugs)); err != nil {
+				return err
+			}
+			idPorSlug := make(map[string]int64, len(insertadas))
+			for _, p := range insertadas {
+				idPorSlug[p.Slug] = p.ID
+			}
+
+			nowChile := time.Now().In(config.Chilelocation)
+			var asociaciones []dto.PeliculaTematicasInsert
+			for _, f := range filas {
+				for orden, t := range f.pelicula.Tematicas {
+					asociaciones = append(asociaciones, dto.PeliculaTematicasInsert{
+						PID:        idPorSlug[f.pelicula.Slug],
+						TematicaID: idsTematicas[t],
+						Orden:      orden + 1,
+						CreatedAt:  nowChile,
+					})
+				}
+			}
+			for inicio := 0; inicio < len(asociaciones); inicio += loteImportacion {
+				fin := min(inicio+loteImportacion, len(asociaciones))
+				n, err := db.InsertBatchTx(ctx, tx, config.Tablas["pt"], asociaciones[inicio:fin])
+				if err != nil {
+					return err
+				}
+				asociadas += n
+			}
+
+			registros := make([]dto.AuditoriaInsert, len(filas))
+			for i, f := range filas {
+				f.pelicula.ID = idPorSlug[f.pelicula.Slug]
+				registro, err := nuevaAuditoria(c, accionCrear, config.Tablas["pl"], f.pelicula.ID, nil, f.pelicula)
+				if err != nil {
+					return err
+				}
+				registros[i] = registro
+			}
+			for inicio := 0; inicio < len(registros); inicio += loteImportacion {
+				fin := min(inicio+loteImportacion, len(registros))
+				if _, err := db.InsertBatchTx(ctx, tx, config.Tablas["au"], registros[inicio:fin]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	return asociadas, err
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,15 +13,14 @@ import (
 	"github.com/jgutierrez746/clase_7_gin_bun/db"
 	"github.com/jgutierrez746/clase_7_gin_bun/dto"
 	jwtPkg "github.com/jgutierrez746/clase_7_gin_bun/jwt"
+	"github.com/jgutierrez746/clase_7_gin_bun/respuesta"
 	"golang.org/x/crypto/bcrypt"
 )
 
 func Login(c *gin.Context) {
 	var input dto.LoginDTO
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Error de validación: " + err.Error(),
-		})
+		respuesta.ErrorBind(c, err)
 		return
 	}
 
@@ -43,28 +43,127 @@ func Login(c *gin.Context) {
 
 	if err := db.SelectOne(ctx, config.Tablas["u"], &userDB, "correo = ?", input.Correo); err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Credenciales inválidas"}) // Usuario no encontrado
+			respuesta.Error(c, http.StatusUnauthorized, respuesta.CodigoNoAutenticado, "Credenciales inválidas") // Usuario no encontrado
 			return
 		}
 		log.Println("Error buscando usuario:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error interno"})
+		respuesta.Error(c, http.StatusInternalServerError, respuesta.CodigoErrorInterno, "Error interno")
 		return
 	}
 
 	// Verificar password
 	if err := bcrypt.CompareHashAndPassword([]byte(userDB.Password), []byte(input.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Credenciales inválidas"}) // Password incorrecto
+		respuesta.Error(c, http.StatusUnauthorized, respuesta.CodigoNoAutenticado, "Credenciales inválidas") // Password incorrecto
 		return
 	}
 
-	// Generar Token
-	token, err := jwtPkg.GenerateToken(userDB.ID, userDB.PerfilID)
+	// Generar Tokens
+	tokens, err := emitirTokens(ctx, userDB.ID, userDB.PerfilID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo generar el token"})
+		respuesta.Error(c, http.StatusInternalServerError, respuesta.CodigoErrorInterno, "No se pudo generar el token")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-	})
+	respuesta.OK(c, tokens)
+}
+
+// Refrescar canjea un refresh token vigente por un nuevo access token y un nuevo refresh token (rotación).
+// El refresh token usado queda revocado.
+func Refrescar(c *gin.Context) {
+	var input dto.RefreshDTO
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respuesta.ErrorBind(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, err := jwtPkg.RotarRefreshToken(ctx, input.RefreshToken)
+	if err != nil {
+		if err == jwtPkg.ErrRefreshInvalido {
+			respuesta.Error(c, http.StatusUnauthorized, respuesta.CodigoNoAutenticado, err.Error())
+			return
+		}
+		log.Println("Error rotando refresh token:", err)
+		respuesta.Error(c, http.StatusInternalServerError, respuesta.CodigoErrorInterno, "Error interno")
+		return
+	}
+
+	// Se vuelve a leer el usuario: si fue eliminado no puede refrescar, y el perfil puede haber cambiado
+	var userDB dto.PerfilUsuarioToken
+	if err := db.SelectOne(ctx, config.Tablas["u"], &userDB, "id = ?", userID); err != nil {
+		if err == sql.ErrNoRows {
+			respuesta.Error(c, http.StatusUnauthorized, respuesta.CodigoNoAutenticado, jwtPkg.ErrRefreshInvalido.Error())
+			return
+		}
+		log.Println("Error buscando usuario:", err)
+		respuesta.Error(c, http.StatusInternalServerError, respuesta.CodigoErrorInterno, "Error interno")
+		return
+	}
+
+	tokens, err := emitirTokens(ctx, userDB.ID, userDB.PerfilID)
+	if err != nil {
+		respuesta.Error(c, http.StatusInternalServerError, respuesta.CodigoErrorInterno, "No se pudo generar el token")
+		return
+	}
+
+	respuesta.OK(c, tokens)
+}
+
+// Logout revoca el refresh token enviado y, si viene el header Authorization, también el access token actual.
+// No requiere un access token vigente, para poder cerrar sesión aunque haya expirado.
+func Logout(c *gin.Context) {
+	var input dto.RefreshDTO
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respuesta.ErrorBind(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := jwtPkg.RevocarRefreshToken(ctx, input.RefreshToken); err != nil {
+		log.Println("Error revocando refresh token:", err)
+		respuesta.Error(c, http.StatusInternalServerError, respuesta.CodigoErrorInterno, "Error interno")
+		return
+	}
+
+	// Revocar el access token hasta su expiración natural
+	if tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		if claims, err := jwtPkg.ValidateToken(tokenString); err == nil {
+			jti, _ := claims["jti"].(string)
+			exp, err := claims.GetExpirationTime()
+			if jti != "" && err == nil && exp != nil {
+				if err := jwtPkg.RevocarAccessToken(ctx, jti, exp.Time); err != nil {
+					log.Println("Error revocando access token:", err)
+					respuesta.Error(c, http.StatusInternalServerError, respuesta.CodigoErrorInterno, "Error interno")
+					return
+				}
+			}
+		}
+	}
+
+	respuesta.OKConMensaje(c, "Sesión cerrada correctamente", nil)
+}
+
+// emitirTokens genera el par access token + refresh token de una sesión.
+func emitirTokens(ctx context.Context, userID, perfilID int64) (gin.H, error) {
+	token, err := jwtPkg.GenerateToken(userID, perfilID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := jwtPkg.GenerarRefreshToken(ctx, userID)
+	if err != nil {
+		log.Println("Error guardando refresh token:", err)
+		return nil, err
+	}
+
+	return gin.H{
+		"token":         token,
+		"token_type":    "Bearer",
+		"expires_in":    int(jwtPkg.AccessTTL().Seconds()),
+		"refresh_token": refreshToken,
+	}, nil
 }
@@ -0,0 +1,199 @@
+package rutas
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jgutierrez746/clase_7_gin_bun/config"
+	"github.com/jgutierrez746/clase_7_gin_bun/db"
+	"github.com/jgutierrez746/clase_7_gin_bun/dto"
+	"github.com/jgutierrez746/clase_7_gin_bun/helpers"
+	"github.com/jgutierrez746/clase_7_gin_bun/respuesta"
+	"github.com/uptrace/bun"
+)
+
+// Las películas, temáticas y usuarios eliminados quedan en la papelera (deleted_at) y las consultas de db los
+// excluyen. Desde aquí se listan y se restauran; papelera.Purgar los borra definitivamente tras la retención.
+
+// consultarPapelera responde el listado paginado de las filas en la papelera de table, por defecto las últimas
+// eliminadas primero. Se puede ordenar y filtrar por los campos del listado normal y por deleted_at.
+func consultarPapelera(c *gin.Context, table string, campos map[string]string, joins, columnas []string, dest interface{}) {
+	camposPapelera := map[string]string{"deleted_at": table + ".deleted_at"}
+	for campo, columna := range campos {
+		camposPapelera[campo] = columna
+	}
+
+	paginacion, err := helpers.ParsearPaginacion(c, camposPapelera, table+".deleted_at DESC")
+	if err != nil {
+		respuesta.SolicitudInvalida(c, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	total, err := db.SelectConJoinPaginado(db.IncluirBorrados(ctx), table, joins, columnas, dest, paginacion, table+".deleted_at IS NOT NULL")
+	if err != nil {
+		respuesta.ErrorBD(c, "Error consultando la papelera", err)
+		return
+	}
+
+	respuesta.Paginada(c, dest, total, paginacion)
+}
+
+func ConsultarPapeleraPeliculas(c *gin.Context) {
+	peliculas := dto.PeliculasAllSelect{}
+	consultarPapelera(c, config.Tablas["pl"], camposPeliculas, nil, nil, &peliculas)
+}
+
+func ConsultarPapeleraTematicas(c *gin.Context) {
+	tematicas := dto.TemticasAllSelect{}
+	consultarPapelera(c, config.Tablas["tm"], camposTematicas, nil, nil, &tematicas)
+}
+
+func ConsultarPapeleraUsuarios(c *gin.Context) {
+	u := config.Tablas["u"]
+	p := config.Tablas["p"]
+
+	var tablasJoin = []string{
+		"JOIN " + p + " ON " + u + ".perfil_id = " + p + ".id",
+	}
+
+	var columnas = []string{
+		u + ".id", u + ".nombre", u + ".correo", u + ".telefono", u + ".perfil_id", u + ".created_at", u + ".updated_at", u + ".deleted_at",
+		p + ".nombre AS perfil_nombre",
+	}
+
+	usuarios := []dto.UsuarioPerfilDTO{}
+	consultarPapelera(c, u, camposUsuarios, tablasJoin, columnas, &usuarios)
+}
+
+// restaurar quita de la papelera la fila id de table (con columna version), sube su versión y lo audita.
+// dest recibe la fila; version retorna su versión y restaurada la deja como quedó (sin deleted_at, versión nueva).
+func restaurar(ctx context.Context, c *gin.Context, table string, id int, dest interface{}, version func() int64, restaurada func(version int64)) error {
+	return db.WithTx(ctx, func(tx bun.IDB) error {
+		if err := db.SelectOneTx(db.IncluirBorrados(ctx), tx, table, dest, true, "id = ? AND deleted_at IS NOT NULL", id); err != nil {
+			return err
+		}
+		antes, err := json.Marshal(dest)
+		if err != nil {
+			return err
+		}
+
+		restaurado := map[string]interface{}{"deleted_at": nil}
+		if _, err := db.UpdateConVersionTx(ctx, tx, table, &restaurado, version(), "id = ?", id); err != nil {
+			return err
+		}
+		restaurada(version() + 1)
+		return auditarTx(ctx, c, tx, accionRestaurar, table, id, json.RawMessage(antes), dest)
+	})
+}
+
+func RestaurarPelicula(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	var pelicula dto.PeliculaSelectDTO
+	err = restaurar(ctx, c, config.Tablas["pl"], id, &pelicula, func() int64 { return pelicula.Version }, func(version int64) {
+		pelicula.DeletedAt, pelicula.Version = nil, version
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respuesta.NoEncontrado(c, "Película no encontrada en la papelera")
+		return
+	}
+	if err != nil {
+		respuesta.ErrorBD(c, "Error restaurando película", err)
+		return
+	}
+
+	c.Header("ETag", helpers.ETag(pelicula.Version))
+	respuesta.OKConMensaje(c, "Película restaurada correctamente", pelicula)
+}
+
+func RestaurarTematica(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	var tematica dto.TematicasSelectOne
+	err = restaurar(ctx, c, config.Tablas["tm"], id, &tematica, func() int64 { return tematica.Version }, func(version int64) {
+		tematica.DeletedAt, tematica.Version = nil, version
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respuesta.NoEncontrado(c, "Temática no encontrada en la papelera")
+		return
+	}
+	if err != nil {
+		respuesta.ErrorBD(c, "Error restaurando temática", err)
+		return
+	}
+
+	c.Header("ETag", helpers.ETag(tematica.Version))
+	respuesta.OKConMensaje(c, "Temática restaurada correctamente", tematica)
+}
+
+func RestaurarUsuario(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	err = db.WithTx(ctx, func(tx bun.IDB) error {
+		var antes, despues usuarioAuditado
+		if err := db.SelectOneTx(db.IncluirBorrados(ctx), tx, config.Tablas["u"], &antes, true, "id = ? AND deleted_at IS NOT NULL", id); err != nil {
+			return err
+		}
+
+		restaurado := map[string]interface{}{"deleted_at": nil}
+		if _, err := db.UpdateColumnasTx(ctx, tx, config.Tablas["u"], restaurado, "id = ?", id); err != nil {
+			return err
+		}
+		if err := db.SelectOneTx(ctx, tx, config.Tablas["u"], &despues, false, "id = ?", id); err != nil {
+			return err
+		}
+		return auditarTx(ctx, c, tx, accionRestaurar, config.Tablas["u"], id, antes, despues)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respuesta.NoEncontrado(c, "Usuario no encontrado en la papelera")
+		return
+	}
+	if err != nil {
+		respuesta.ErrorBD(c, "Error restaurando usuario", err)
+		return
+	}
+
+	usuario, err := consultarUsuario(ctx, id)
+	if err != nil {
+		respuesta.ErrorBD(c, "Error consultando usuario", err)
+		return
+	}
+	if usuario == nil {
+		respuesta.NoEncontrado(c, "Usuario no encontrado")
+		return
+	}
+
+	respuesta.OKConMensaje(c, "Usuario restaurado correctamente", usuario)
+}
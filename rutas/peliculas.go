@@ -3,6 +3,8 @@ package rutas
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
@@ -10,224 +12,305 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/gosimple/slug"
 	"github.com/jgutierrez746/clase_7_gin_bun/config"
 	"github.com/jgutierrez746/clase_7_gin_bun/db"
 	"github.com/jgutierrez746/clase_7_gin_bun/dto"
+	"github.com/jgutierrez746/clase_7_gin_bun/helpers"
+	"github.com/jgutierrez746/clase_7_gin_bun/respuesta"
+	"github.com/uptrace/bun"
 )
 
+// camposPeliculas es la lista blanca de campos por los que se puede ordenar y filtrar el listado.
+// Las columnas van calificadas con la tabla porque ?include=tematicas reutiliza el orden en un JOIN.
+var camposPeliculas = map[string]string{
+	"id":         config.Tablas["pl"] + ".id",
+	"anio":       config.Tablas["pl"] + ".anio",
+	"titulo":     config.Tablas["pl"] + ".titulo",
+	"slug":       config.Tablas["pl"] + ".slug",
+	"director":   config.Tablas["pl"] + ".director",
+	"created_at": config.Tablas["pl"] + ".created_at",
+	"updated_at": config.Tablas["pl"] + ".updated_at",
+}
+
+// Relaciones que se pueden pedir con ?include= en los endpoints de películas
+var includesPeliculas = []string{"tematicas", "portada"}
+
 func ConsultarPeliculas(c *gin.Context) {
+	// ?after= o ?limit= activan la paginación por cursor (keyset)
+	if helpers.EsModoCursor(c) {
+		consultarPeliculasCursor(c)
+		return
+	}
+
+	paginacion, err := helpers.ParsearPaginacion(c, camposPeliculas, config.Tablas["pl"]+".id DESC")
+	if err != nil {
+		respuesta.SolicitudInvalida(c, err.Error())
+		return
+	}
+
+	include, err := helpers.ParsearInclude(c, includesPeliculas...)
+	if err != nil {
+		respuesta.SolicitudInvalida(c, err.Error())
+		return
+	}
+
 	// Definir contexto con tiempo de espera de solo 5 segundos
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	var peliculas dto.PeliculasAllSelect
-	if err := db.SelectAll(ctx, config.Tablas["pl"], &peliculas); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error consultando películas: " + err.Error(),
-		})
+	peliculas := dto.PeliculasAllSelect{}
+	total, err := db.SelectPaginado(ctx, config.Tablas["pl"], &peliculas, paginacion)
+	if err != nil {
+		respuesta.ErrorBD(c, "Error consultando películas", err)
 		return
 	}
 
-	// Si no hay datos, responde vacío
-	if len(peliculas) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"tematicas": []interface{}{},
-			"mensaje":   "No hay películas registradas",
-		})
+	peliculas, err = cargarRelacionesPeliculas(ctx, c, peliculas, include, paginacion.Orden)
+	if err != nil {
+		respuesta.ErrorBD(c, "Error consultando relaciones de películas", err)
 		return
 	}
 
-	log.Printf("Se consultaron %d películas.", len(peliculas))
-	c.JSON(http.StatusOK, gin.H{
-		"peliculas": peliculas, // JSON con todos los campos (ID, Nombre, Slug)
-		"total":     len(peliculas),
-	})
+	log.Printf("Se consultaron %d de %d películas.", len(peliculas), total)
+	respuesta.Paginada(c, peliculas, total, paginacion)
+}
+
+// consultarPeliculasCursor responde el listado paginado por keyset, estable ante inserciones concurrentes.
+func consultarPeliculasCursor(c *gin.Context) {
+	paginacion, err := helpers.ParsearCursor(c, camposPeliculas, config.Tablas["pl"]+".")
+	if err != nil {
+		respuesta.SolicitudInvalida(c, err.Error())
+		return
+	}
+
+	include, err := helpers.ParsearInclude(c, includesPeliculas...)
+	if err != nil {
+		respuesta.SolicitudInvalida(c, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	peliculas := dto.PeliculasAllSelect{}
+	siguiente, err := db.SelectCursor(ctx, config.Tablas["pl"], &peliculas, paginacion)
+	if err != nil {
+		respuesta.ErrorBD(c, "Error consultando películas", err)
+		return
+	}
+
+	peliculas, err = cargarRelacionesPeliculas(ctx, c, peliculas, include, paginacion.Orden())
+	if err != nil {
+		respuesta.ErrorBD(c, "Error consultando relaciones de películas", err)
+		return
+	}
+
+	respuesta.Cursor(c, peliculas, siguiente, paginacion)
 }
 
 func ConsultarPeliculaPorId(c *gin.Context) {
 	id := c.Param("id")
 	if strings.TrimSpace(id) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "No se ingresó parámetro solicitado.",
-		})
+		respuesta.SolicitudInvalida(c, "No se ingresó parámetro solicitado.")
 		return
 	}
+
+	include, err := helpers.ParsearInclude(c, includesPeliculas...)
+	if err != nil {
+		respuesta.SolicitudInvalida(c, err.Error())
+		return
+	}
+
 	// Definir contexto con tiempo de espera de solo 5 segundos
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	// tematicas := new(dto.AllTematicasSalida)a
 	var pelicula dto.PeliculaSelectDTO
 	if err := db.SelectOne(ctx, config.Tablas["pl"], &pelicula, "id = ?", id); err != nil {
 		if err == sql.ErrNoRows { // Si no existe, 404
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "Película no encontrada",
-			})
+			respuesta.NoEncontrado(c, "Película no encontrada")
 			return
 		}
-		log.Printf("Error en SelectOne: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error consultando película: " + err.Error(),
-		})
+		respuesta.ErrorBD(c, "Error consultando película", err)
 		return
 	}
 
-	log.Printf("Se consultó temática con ID: %s ", id)
-	c.JSON(http.StatusOK, gin.H{
-		"película": pelicula, // JSON con todos los campos (ID, Nombre, Slug...)
-	})
+	log.Printf("Se consultó película con ID: %s ", id)
+	responderPelicula(ctx, c, pelicula, include)
 }
 
-/*
-func ConsultarPeliculas(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
-	defer cancel()
+// ConsultarPeliculaPorSlug busca la película por su slug actual. Si el slug es uno que tuvo antes de cambiar
+// de título, redirige con 301 a la URL con el slug actual (conservando el query string).
+func ConsultarPeliculaPorSlug(c *gin.Context) {
+	s := c.Param("slug")
 
-	var modelo []dto.PeliculaJoinRow
+	include, err := helpers.ParsearInclude(c, includesPeliculas...)
+	if err != nil {
+		respuesta.SolicitudInvalida(c, err.Error())
+		return
+	}
 
-	tm := config.Tablas["tm"]
-	pl := config.Tablas["pl"]
-	pt := config.Tablas["pt"]
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
 
-	var tablasJoin = []string{
-		fmt.Sprintf("LEFT JOIN %s ON %s.p_id = %s.id", pt, pt, pl),
-		fmt.Sprintf("LEFT JOIN %s ON %s.tematica_id = %s.id", tm, pt, tm),
+	var pelicula dto.PeliculaSelectDTO
+	err = db.SelectOne(ctx, config.Tablas["pl"], &pelicula, "slug = ?", s)
+	if err == sql.ErrNoRows {
+		pl := config.Tablas["pl"]
+		sp := config.Tablas["sp"]
+		joins := []string{fmt.Sprintf("JOIN %s ON %s.p_id = %s.id", sp, sp, pl)}
+		err = db.SelectConJoin(ctx, pl, joins, []string{pl + ".slug"}, &pelicula, "", sp+".slug = ?", s)
+		if err == nil {
+			destino := strings.TrimSuffix(c.Request.URL.Path, s) + pelicula.Slug
+			if c.Request.URL.RawQuery != "" {
+				destino += "?" + c.Request.URL.RawQuery
+			}
+			c.Redirect(http.StatusMovedPermanently, destino)
+			return
+		}
 	}
-
-	var columnas = []string{
-		fmt.Sprintf("%s.id, %s.anio, %s.titulo, %s.slug, %s.descripcion, %s.director, %s.created_at, %s.updated_at", pl, pl, pl, pl, pl, pl, pl, pl),
-		fmt.Sprintf("%s.id AS tematica_id, %s.nombre, %s.slug AS slug_tem, %s.created_at AS t_created_at, %s.updated_at AS t_updated_at", tm, tm, tm, tm, tm),
-		fmt.Sprintf("%s.orden", pt),
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respuesta.NoEncontrado(c, "Película no encontrada")
+			return
+		}
+		respuesta.ErrorBD(c, "Error consultando película", err)
+		return
 	}
 
-	order := fmt.Sprintf("%s.id DESC, %s.orden ASC", pl, pt)
+	log.Printf("Se consultó película con slug: %s ", s)
+	responderPelicula(ctx, c, pelicula, include)
+}
 
-	if err := db.SelectConJoin(ctx, config.Tablas["pl"], tablasJoin, columnas, &modelo, order, "", nil); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error consultando películas: " + err.Error(),
-		})
+// responderPelicula carga las relaciones pedidas en ?include= y responde la película.
+// Con If-None-Match igual a la versión actual responde 304, salvo que se pidan relaciones: renombrar o enviar a la
+// papelera una temática cambia lo incluido sin cambiar la versión de la película.
+func responderPelicula(ctx context.Context, c *gin.Context, pelicula dto.PeliculaSelectDTO, include map[string]bool) {
+	if len(include) > 0 {
+		c.Header("ETag", helpers.ETag(pelicula.Version)) // Sigue sirviendo para el If-Match de las escrituras
+	} else if responderNoModificado(c, pelicula.Version) {
 		return
 	}
 
-	if len(modelo) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"peliculas": []interface{}{},
-			"mensaje":   "No hay películas registradas",
-		})
+	peliculas, err := cargarRelacionesPeliculas(ctx, c, dto.PeliculasAllSelect{pelicula}, include, nil)
+	if err != nil {
+		respuesta.ErrorBD(c, "Error consultando relaciones de la película", err)
 		return
 	}
 
-	peliculas := helpers.AgruparPeliculas(modelo)
-
-	c.JSON(http.StatusOK, gin.H{
-		"peliculas": peliculas,
-		"total":     len(peliculas),
-	})
+	respuesta.OK(c, peliculas[0])
 }
-*/
-/*
-func ConsultarPeliculaPorId(c *gin.Context) {
-	id := c.Param("id")
-	if strings.TrimSpace(id) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "No se ingresó parámetro solicitado.",
-		})
-		return
-	}
 
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
-	defer cancel()
+// cargarRelacionesPeliculas completa las películas con las relaciones pedidas en ?include=,
+// usando una consulta por relación en vez de una por película (evita N+1).
+// - orden: ORDER BY de la consulta original, el JOIN de temáticas lo repite para conservar el orden.
+func cargarRelacionesPeliculas(ctx context.Context, c *gin.Context, peliculas dto.PeliculasAllSelect, include map[string]bool, orden []string) (dto.PeliculasAllSelect, error) {
+	if len(peliculas) == 0 || len(include) == 0 {
+		return peliculas, nil
+	}
 
-	var modelo []dto.PeliculaJoinRow
+	ids := make([]int64, len(peliculas))
+	for i, p := range peliculas {
+		ids[i] = p.ID
+	}
 
 	tm := config.Tablas["tm"]
 	pl := config.Tablas["pl"]
 	pt := config.Tablas["pt"]
+	pp := config.Tablas["pp"]
 
-	var tablasJoin = []string{
-		fmt.Sprintf("LEFT JOIN %s ON %s.p_id = %s.id", pt, pt, pl),
-		fmt.Sprintf("LEFT JOIN %s ON %s.tematica_id = %s.id", tm, pt, tm),
-	}
+	if include["tematicas"] {
+		var modelo []dto.PeliculaJoinRow
 
-	var columnas = []string{
-		fmt.Sprintf("%s.id, %s.anio, %s.titulo, %s.slug, %s.descripcion, %s.director, %s.created_at, %s.updated_at", pl, pl, pl, pl, pl, pl, pl, pl),
-		fmt.Sprintf("%s.id AS tematica_id, %s.nombre, %s.slug AS slug_tem, %s.created_at AS t_created_at, %s.updated_at AS t_updated_at", tm, tm, tm, tm, tm),
-		fmt.Sprintf("%s.orden", pt),
-	}
+		var tablasJoin = []string{
+			fmt.Sprintf("LEFT JOIN %s ON %s.p_id = %s.id", pt, pt, pl),
+			fmt.Sprintf("LEFT JOIN %s ON %s.tematica_id = %s.id AND %s.deleted_at IS NULL", tm, pt, tm, tm), // Sin las temáticas en la papelera
+		}
 
-	where := fmt.Sprintf("%s.id = ?", pl)
+		var columnas = []string{
+			fmt.Sprintf("%s.id, %s.anio, %s.titulo, %s.slug, %s.descripcion, %s.director, %s.created_at, %s.updated_at", pl, pl, pl, pl, pl, pl, pl, pl),
+			fmt.Sprintf("%s.id AS tematica_id, %s.nombre, %s.slug AS slug_tem, %s.created_at AS t_created_at, %s.updated_at AS t_updated_at", tm, tm, tm, tm, tm),
+			fmt.Sprintf("%s.orden", pt),
+		}
 
-	order := fmt.Sprintf("%s.id DESC, %s.orden ASC", pl, pt)
+		order := strings.Join(append(append([]string{}, orden...), pt+".orden ASC"), ", ")
+		where := fmt.Sprintf("%s.id IN (?)", pl)
 
-	if err := db.SelectConJoin(ctx, config.Tablas["pl"], tablasJoin, columnas, &modelo, order, where, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error consultando películas: " + err.Error(),
-		})
-		return
+		if err := db.SelectConJoin(ctx, pl, tablasJoin, columnas, &modelo, order, where, bun.In(ids)); err != nil {
+			return nil, err
+		}
+		peliculas = helpers.AgruparPeliculas(modelo)
 	}
 
-	peliculas := helpers.AgruparPeliculas(modelo)
+	if include["portada"] {
+		var portadas []dto.PortadaSelectDTO
+		if err := db.SelectConJoin(ctx, pp, nil, nil, &portadas, "", "p_id IN (?) AND is_primary = ?", bun.In(ids), true); err != nil {
+			return nil, err
+		}
 
-	if len(peliculas) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Película no encontrada",
-		})
-		return
+		porPelicula := make(map[int64]dto.PortadaSelectDTO, len(portadas))
+		for _, portada := range portadas {
+			completarPortada(c, &portada)
+			porPelicula[portada.PID] = portada
+		}
+		for i := range peliculas {
+			if portada, ok := porPelicula[peliculas[i].ID]; ok {
+				peliculas[i].Portada = &portada
+			}
+		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"pelicula": peliculas[0],
-	})
+	return peliculas, nil
 }
-*/
 
 func CrearPelicula(c *gin.Context) {
 	var pelicula dto.PeliculaInsert
 	if err := c.ShouldBindJSON(&pelicula); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Error al procesar el JSON " + err.Error(),
-		})
+		respuesta.ErrorBind(c, err)
 		return
 	}
 
 	nowChile := time.Now().In(config.Chilelocation)
-	pelicula.Slug = slug.Make(pelicula.Titulo)
+	pelicula.Busqueda = helpers.NormalizarBusqueda(pelicula.Titulo, pelicula.Descripcion, pelicula.Director)
 	pelicula.CreatedAt = nowChile
 	pelicula.UpdatedAt = nowChile
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	if err := db.Insert(ctx, config.Tablas["pl"], &pelicula); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
+	// Dos películas con el mismo título (ej: remakes) reciben "dracula" y "dracula-1992"
+	err := conReintentoSlug(func() error {
+		return db.WithTx(ctx, func(tx bun.IDB) error {
+			var err error
+			if pelicula.Slug, err = slugPelicula(ctx, tx, pelicula.Titulo, pelicula.Anio, 0); err != nil {
+				return err
+			}
+			if err := db.InsertTx(ctx, tx, config.Tablas["pl"], &pelicula); err != nil {
+				return err
+			}
+			return auditarTx(ctx, c, tx, accionCrear, config.Tablas["pl"], pelicula.ID, nil, pelicula)
 		})
+	})
+	if err != nil {
+		respuesta.ErrorBD(c, "Error creando película", err)
 		return
 	}
 
 	// Se envía respuesta con el modelo actualizado
-	c.JSON(http.StatusCreated, gin.H{
-		"mensaje":  "Pelicula creada en Base de Datos",
-		"pelicula": pelicula,
-	})
+	respuesta.Creado(c, "Película creada", pelicula)
 }
 
 func EditarPelicula(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Parámetro inválido.",
-		})
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
 		return
 	}
 
 	var input dto.PeliculaUpdate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Error al procesar el JSON " + err.Error(),
-		})
+		respuesta.ErrorBind(c, err)
 		return
 	}
 
@@ -235,64 +318,172 @@ func EditarPelicula(c *gin.Context) {
 	defer cancel()
 
 	input.ID = int64(id)
-	if input.Titulo != "" {
-		input.Slug = slug.Make(input.Titulo)
-	}
+	input.Busqueda = helpers.NormalizarBusqueda(input.Titulo, input.Descripcion, input.Director)
 	input.UpdatedAt = time.Now().In(config.Chilelocation)
 
-	// Ejecutamos el Update
-	filasAfectadas, err := db.Update(ctx, config.Tablas["pl"], &input, "id = ?", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error en update: " + err.Error(),
+	var version int64
+	err = conReintentoSlug(func() error {
+		return db.WithTx(ctx, func(tx bun.IDB) error {
+			var actual dto.PeliculaSelectDTO
+			if err := db.SelectOneTx(ctx, tx, config.Tablas["pl"], &actual, true, "id = ?", id); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return errPeliculaNoEncontrada
+				}
+				return err
+			}
+			if err := verificarIfMatch(c, actual.Version); err != nil {
+				return err
+			}
+
+			var err error
+			if input.Slug, err = nuevoSlugPelicula(ctx, tx, actual, input.Titulo, input.Anio); err != nil {
+				return err
+			}
+
+			if _, err = db.UpdateConVersionTx(ctx, tx, config.Tablas["pl"], &input, actual.Version, "id = ?", id); err != nil {
+				return err
+			}
+			version = actual.Version + 1
+			return auditarTx(ctx, c, tx, accionActualizar, config.Tablas["pl"], id, actual, input)
 		})
+	})
+	if errors.Is(err, errPeliculaNoEncontrada) {
+		respuesta.NoEncontrado(c, "Película no encontrada")
 		return
 	}
-
-	if filasAfectadas == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Película no encontrada",
-		})
+	if responderPrecondicion(c, err) {
+		return
+	}
+	if err != nil {
+		respuesta.ErrorBD(c, "Error en update", err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"mensaje":  "Película editada correctamente",
-		"pelicula": input,
-	})
+	c.Header("ETag", helpers.ETag(version))
+	respuesta.OKConMensaje(c, "Película editada correctamente", input)
 }
 
-func EliminarPelicula(c *gin.Context) {
+// ModificarPelicula actualiza solo los campos que vienen en el cuerpo (PATCH con JSON Merge Patch, ver dto.Campo).
+// Un null en anio o descripcion los deja en NULL. Responde la película completa ya modificada.
+func ModificarPelicula(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Parámetro inválido.",
-		})
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
+		return
+	}
+
+	var patch dto.PeliculaPatch
+	if err := helpers.ParsearPatch(c, &patch); err != nil {
+		respuesta.ErrorBind(c, err)
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	// Ejecutamos Delete
-	filasAfectadas, err := db.Delete(ctx, config.Tablas["pl"], "id = ?", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error eliminando: " + err.Error(),
+	var pelicula dto.PeliculaSelectDTO
+	err = conReintentoSlug(func() error {
+		return db.WithTx(ctx, func(tx bun.IDB) error {
+			var actual dto.PeliculaSelectDTO
+			if err := db.SelectOneTx(ctx, tx, config.Tablas["pl"], &actual, true, "id = ?", id); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return errPeliculaNoEncontrada
+				}
+				return err
+			}
+
+			if err := verificarIfMatch(c, actual.Version); err != nil {
+				return err
+			}
+
+			pelicula = actual
+			columnas := helpers.ColumnasPatch(&patch)
+			if len(columnas) == 0 {
+				return nil // Un patch vacío no modifica nada
+			}
+
+			// El slug y el texto de búsqueda se calculan con la película ya modificada
+			patch.Anio.Aplicar(&pelicula.Anio)
+			patch.Titulo.Aplicar(&pelicula.Titulo)
+			patch.Descripcion.Aplicar(&pelicula.Descripcion)
+			patch.Director.Aplicar(&pelicula.Director)
+
+			var err error
+			if pelicula.Slug, err = nuevoSlugPelicula(ctx, tx, actual, pelicula.Titulo, pelicula.Anio); err != nil {
+				return err
+			}
+			pelicula.UpdatedAt = time.Now().In(config.Chilelocation)
+			pelicula.Version++
+
+			columnas["slug"] = pelicula.Slug
+			columnas["busqueda"] = helpers.NormalizarBusqueda(pelicula.Titulo, pelicula.Descripcion, pelicula.Director)
+			columnas["updated_at"] = pelicula.UpdatedAt
+			if _, err = db.UpdateConVersionTx(ctx, tx, config.Tablas["pl"], &columnas, actual.Version, "id = ?", id); err != nil {
+				return err
+			}
+			return auditarTx(ctx, c, tx, accionActualizar, config.Tablas["pl"], id, actual, pelicula)
 		})
+	})
+	if errors.Is(err, errPeliculaNoEncontrada) {
+		respuesta.NoEncontrado(c, "Película no encontrada")
+		return
+	}
+	if responderPrecondicion(c, err) {
+		return
+	}
+	if err != nil {
+		respuesta.ErrorBD(c, "Error en update", err)
 		return
 	}
 
-	if filasAfectadas == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Película no encontrada",
-		})
+	c.Header("ETag", helpers.ETag(pelicula.Version))
+	respuesta.OKConMensaje(c, "Película modificada correctamente", pelicula)
+}
+
+func EliminarPelicula(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"mensaje":    "Película eliminada correctamente",
-		"eliminados": filasAfectadas,
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	var filasAfectadas int64
+	err = db.WithTx(ctx, func(tx bun.IDB) error {
+		var actual dto.PeliculaSelectDTO
+		if err := db.SelectOneTx(ctx, tx, config.Tablas["pl"], &actual, true, "id = ?", id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errPeliculaNoEncontrada
+			}
+			return err
+		}
+		if err := verificarIfMatch(c, actual.Version); err != nil {
+			return err
+		}
+
+		// Queda en la papelera hasta que se restaure o se purgue (ver papelera.Purgar)
+		borrado := map[string]interface{}{"deleted_at": time.Now().In(config.Chilelocation)}
+		var err error
+		if filasAfectadas, err = db.UpdateConVersionTx(ctx, tx, config.Tablas["pl"], &borrado, actual.Version, "id = ?", id); err != nil {
+			return err
+		}
+		return auditarTx(ctx, c, tx, accionEliminar, config.Tablas["pl"], id, actual, nil)
 	})
+	if errors.Is(err, errPeliculaNoEncontrada) {
+		respuesta.NoEncontrado(c, "Película no encontrada")
+		return
+	}
+	if responderPrecondicion(c, err) {
+		return
+	}
+	if err != nil {
+		respuesta.ErrorBD(c, "Error eliminando", err)
+		return
+	}
+
+	respuesta.Eliminado(c, "Película enviada a la papelera", filasAfectadas)
 }
@@ -3,7 +3,7 @@ package rutas
 import (
 	"context"
 	"database/sql"
-	"net/http"
+	"errors"
 	"strconv"
 	"strings"
 	"time"
@@ -12,40 +12,41 @@ import (
 	"github.com/jgutierrez746/clase_7_gin_bun/config"
 	"github.com/jgutierrez746/clase_7_gin_bun/db"
 	"github.com/jgutierrez746/clase_7_gin_bun/dto"
+	"github.com/jgutierrez746/clase_7_gin_bun/helpers"
+	"github.com/jgutierrez746/clase_7_gin_bun/respuesta"
+	"github.com/uptrace/bun"
 )
 
-func ConsultarPerfiles(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
-	defer cancel()
+// camposPerfiles es la lista blanca de campos por los que se puede ordenar y filtrar el listado.
+var camposPerfiles = map[string]string{
+	"id":     "id",
+	"nombre": "nombre",
+}
 
-	var perfiles dto.PerfilesAllSelect
-	if err := db.SelectAll(ctx, config.Tablas["p"], &perfiles); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error consultando perfiles: " + err.Error(),
-		})
+func ConsultarPerfiles(c *gin.Context) {
+	paginacion, err := helpers.ParsearPaginacion(c, camposPerfiles, "id DESC")
+	if err != nil {
+		respuesta.SolicitudInvalida(c, err.Error())
 		return
 	}
 
-	if len(perfiles) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"perfiles": []interface{}{},
-			"mensaje":  "No hay perfiles registrados",
-		})
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	perfiles := dto.PerfilesAllSelect{}
+	total, err := db.SelectPaginado(ctx, config.Tablas["p"], &perfiles, paginacion)
+	if err != nil {
+		respuesta.ErrorBD(c, "Error consultando perfiles", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"perfiles": perfiles,
-		"total":    len(perfiles),
-	})
+	respuesta.Paginada(c, perfiles, total, paginacion)
 }
 
 func ConsultarPerfilPorId(c *gin.Context) {
 	id := c.Param("id")
 	if strings.TrimSpace(id) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "No se ingresó parámetro solicitado.",
-		})
+		respuesta.SolicitudInvalida(c, "No se ingresó parámetro solicitado.")
 		return
 	}
 
@@ -55,62 +56,51 @@ func ConsultarPerfilPorId(c *gin.Context) {
 	var perfil dto.PerfilesSelectDTO
 	if err := db.SelectOne(ctx, config.Tablas["p"], &perfil, "id = ?", id); err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "Perfil no encontrado",
-			})
+			respuesta.NoEncontrado(c, "Perfil no encontrado")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error consultando perfil: " + err.Error(),
-		})
+		respuesta.ErrorBD(c, "Error consultando perfil", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"perfil": perfil,
-	})
+	respuesta.OK(c, perfil)
 }
 
 func CrearPerfil(c *gin.Context) {
 	var input dto.PerfilesInsert
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Error al procesar el JSON: " + err.Error(),
-		})
+		respuesta.ErrorBind(c, err)
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	if err := db.Insert(ctx, config.Tablas["p"], &input); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+	err := db.WithTx(ctx, func(tx bun.IDB) error {
+		if err := db.InsertTx(ctx, tx, config.Tablas["p"], &input); err != nil {
+			return err
+		}
+		return auditarTx(ctx, c, tx, accionCrear, config.Tablas["p"], input.ID, nil, input)
+	})
+	if err != nil {
+		respuesta.ErrorBD(c, "Error creando perfil", err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"mensaje": "Perfil creado exitosamente",
-		"perfil":  input,
-	})
+	respuesta.Creado(c, "Perfil creado exitosamente", input)
 }
 
 func EditarPerfil(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Parámetro inválido.",
-		})
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
 		return
 	}
 
 	var input dto.PerfilesUpdate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Error al procesar el JSON: " + err.Error(),
-		})
+		respuesta.ErrorBind(c, err)
 		return
 	}
 
@@ -119,57 +109,107 @@ func EditarPerfil(c *gin.Context) {
 
 	input.ID = int64(id)
 
-	filasAfectadas, err := db.Update(ctx, config.Tablas["p"], &input, "id = ?", id)
+	err = db.WithTx(ctx, func(tx bun.IDB) error {
+		var antes dto.PerfilesSelectDTO
+		if err := db.SelectOneTx(ctx, tx, config.Tablas["p"], &antes, true, "id = ?", id); err != nil {
+			return err
+		}
+		if _, err := db.UpdateTx(ctx, tx, config.Tablas["p"], &input, "id = ?", id); err != nil {
+			return err
+		}
+		return auditarTx(ctx, c, tx, accionActualizar, config.Tablas["p"], id, antes, input)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respuesta.NoEncontrado(c, "Perfil no encontrado")
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error al actualizar perfil: " + err.Error(),
-		})
+		respuesta.ErrorBD(c, "Error al actualizar perfil", err)
 		return
 	}
 
-	if filasAfectadas == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Perfil no encontrado",
-		})
+	respuesta.OKConMensaje(c, "Perfil actualizado correctamente", input)
+}
+
+// ModificarPerfil actualiza solo los campos que vienen en el cuerpo (PATCH con JSON Merge Patch, ver dto.Campo).
+func ModificarPerfil(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"mensaje": "Perfil actualizado correctamente",
-		"perfil":  input,
+	var patch dto.PerfilPatch
+	if err := helpers.ParsearPatch(c, &patch); err != nil {
+		respuesta.ErrorBind(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	var perfil dto.PerfilesSelectDTO
+	err = db.WithTx(ctx, func(tx bun.IDB) error {
+		if err := db.SelectOneTx(ctx, tx, config.Tablas["p"], &perfil, true, "id = ?", id); err != nil {
+			return err
+		}
+
+		columnas := helpers.ColumnasPatch(&patch)
+		if len(columnas) == 0 {
+			return nil
+		}
+		antes := perfil
+		patch.Nombre.Aplicar(&perfil.Nombre)
+
+		if _, err := db.UpdateColumnasTx(ctx, tx, config.Tablas["p"], columnas, "id = ?", id); err != nil {
+			return err
+		}
+		return auditarTx(ctx, c, tx, accionActualizar, config.Tablas["p"], id, antes, perfil)
 	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respuesta.NoEncontrado(c, "Perfil no encontrado")
+		return
+	}
+	if err != nil {
+		respuesta.ErrorBD(c, "Error al actualizar perfil", err)
+		return
+	}
+
+	respuesta.OKConMensaje(c, "Perfil modificado correctamente", perfil)
 }
 
 func EliminarPerfil(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Parámetro inválido.",
-		})
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	filasAfectadas, err := db.Delete(ctx, config.Tablas["p"], "id = ?", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error eliminando perfil: " + err.Error(),
-		})
+	var filasAfectadas int64
+	err = db.WithTx(ctx, func(tx bun.IDB) error {
+		var antes dto.PerfilesSelectDTO
+		if err := db.SelectOneTx(ctx, tx, config.Tablas["p"], &antes, true, "id = ?", id); err != nil {
+			return err
+		}
+		var err error
+		if filasAfectadas, err = db.DeleteTx(ctx, tx, config.Tablas["p"], "id = ?", id); err != nil {
+			return err
+		}
+		return auditarTx(ctx, c, tx, accionEliminar, config.Tablas["p"], id, antes, nil)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respuesta.NoEncontrado(c, "Perfil no encontrado")
 		return
 	}
-
-	if filasAfectadas == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Perfil no encontrado",
-		})
+	if err != nil {
+		respuesta.ErrorBD(c, "Error eliminando perfil", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"mensaje":    "Perfil eliminado correctamente",
-		"eliminados": filasAfectadas,
-	})
+	respuesta.Eliminado(c, "Perfil eliminado correctamente", filasAfectadas)
 }
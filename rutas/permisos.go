@@ -0,0 +1,164 @@
+package rutas
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jgutierrez746/clase_7_gin_bun/config"
+	"github.com/jgutierrez746/clase_7_gin_bun/db"
+	"github.com/jgutierrez746/clase_7_gin_bun/dto"
+	jwtPkg "github.com/jgutierrez746/clase_7_gin_bun/jwt"
+	"github.com/jgutierrez746/clase_7_gin_bun/respuesta"
+	"github.com/uptrace/bun"
+)
+
+// ConsultarPermisos lista el catálogo completo de permisos.
+func ConsultarPermisos(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	permisos := dto.PermisosAllSelect{}
+	if err := db.SelectConJoin(ctx, config.Tablas["pm"], nil, nil, &permisos, "codigo ASC", ""); err != nil {
+		respuesta.ErrorBD(c, "Error consultando permisos", err)
+		return
+	}
+
+	respuesta.Lista(c, permisos)
+}
+
+// ConsultarPermisosPerfil lista los permisos otorgados a un perfil.
+func ConsultarPermisosPerfil(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if !perfilExiste(ctx, c, id) {
+		return
+	}
+
+	pm := config.Tablas["pm"]
+	pfp := config.Tablas["pfp"]
+
+	permisos := dto.PermisosAllSelect{}
+	joins := []string{fmt.Sprintf("JOIN %s ON %s.permiso_id = %s.id", pfp, pfp, pm)}
+	columnas := []string{pm + ".id", pm + ".codigo", pm + ".descripcion"}
+	where := pfp + ".perfil_id = ?"
+
+	if err := db.SelectConJoin(ctx, pm, joins, columnas, &permisos, pm+".codigo ASC", where, id); err != nil {
+		respuesta.ErrorBD(c, "Error consultando permisos del perfil", err)
+		return
+	}
+
+	respuesta.Lista(c, permisos)
+}
+
+// OtorgarPermisosPerfil otorga uno o más permisos a un perfil. Body: {"permisos": ["peliculas:write"]}
+func OtorgarPermisosPerfil(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
+		return
+	}
+
+	var input dto.PermisosAsignar
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respuesta.ErrorBind(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	// Otorgar y auditar en una transacción, con el perfil bloqueado para que otra solicitud no otorgue lo mismo
+	var otorgados, desconocidos []string
+	err = db.WithTx(ctx, func(tx bun.IDB) error {
+		var perfil dto.PerfilesSelectDTO
+		if err := db.SelectOneTx(ctx, tx, config.Tablas["p"], &perfil, true, "id = ?", id); err != nil {
+			return err
+		}
+
+		var err error
+		if otorgados, desconocidos, err = jwtPkg.OtorgarPermisos(ctx, tx, int64(id), input.Permisos); err != nil {
+			return err
+		}
+		if len(otorgados) == 0 {
+			return nil
+		}
+		return auditarTx(ctx, c, tx, accionCrear, config.Tablas["pfp"], id, nil, gin.H{"perfil_id": id, "permisos": otorgados})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respuesta.NoEncontrado(c, "Perfil no encontrado")
+		return
+	}
+	if err != nil {
+		respuesta.ErrorBD(c, "Error otorgando permisos", err)
+		return
+	}
+	if len(desconocidos) > 0 {
+		respuesta.SolicitudInvalida(c, "Permisos inexistentes: "+strings.Join(desconocidos, ", "))
+		return
+	}
+
+	respuesta.Creado(c, "Permisos otorgados correctamente", len(otorgados))
+}
+
+// RevocarPermisoPerfil quita un permiso a un perfil. Ej: DELETE /perfiles/2/permisos/peliculas:delete
+func RevocarPermisoPerfil(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
+		return
+	}
+	codigo := c.Param("codigo")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	var filasAfectadas int64
+	err = db.WithTx(ctx, func(tx bun.IDB) error {
+		var err error
+		if filasAfectadas, err = jwtPkg.RevocarPermiso(ctx, tx, int64(id), codigo); err != nil || filasAfectadas == 0 {
+			return err
+		}
+		return auditarTx(ctx, c, tx, accionEliminar, config.Tablas["pfp"], fmt.Sprintf("%d:%s", id, codigo), gin.H{"perfil_id": id, "permiso": codigo}, nil)
+	})
+	if err != nil {
+		respuesta.ErrorBD(c, "Error revocando permiso", err)
+		return
+	}
+
+	if filasAfectadas == 0 {
+		respuesta.NoEncontrado(c, "El perfil no tiene el permiso "+codigo)
+		return
+	}
+
+	respuesta.Eliminado(c, "Permiso revocado correctamente", filasAfectadas)
+}
+
+// perfilExiste responde 404 (o 500) y retorna false si el perfil no existe.
+func perfilExiste(ctx context.Context, c *gin.Context, id int) bool {
+	var perfil dto.PerfilesSelectDTO
+	if err := db.SelectOne(ctx, config.Tablas["p"], &perfil, "id = ?", id); err != nil {
+		if err == sql.ErrNoRows {
+			respuesta.NoEncontrado(c, "Perfil no encontrado")
+			return false
+		}
+		respuesta.ErrorBD(c, "Error consultando perfil", err)
+		return false
+	}
+	return true
+}
@@ -1,27 +1,32 @@
 package rutas
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"log"
+	"mime"
 	"net/http"
-	"os"
-	"path/filepath"
+	"path"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jgutierrez746/clase_7_gin_bun/almacenamiento"
 	"github.com/jgutierrez746/clase_7_gin_bun/config"
 	"github.com/jgutierrez746/clase_7_gin_bun/db"
 	"github.com/jgutierrez746/clase_7_gin_bun/dto"
+	"github.com/jgutierrez746/clase_7_gin_bun/helpers"
+	"github.com/jgutierrez746/clase_7_gin_bun/respuesta"
 )
 
 func ConsultarPortadasPelicula(c *gin.Context) {
 	id := c.Param("id")
 	if strings.TrimSpace(id) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "No se ingresó parámetro solicitado.",
-		})
+		respuesta.SolicitudInvalida(c, "No se ingresó parámetro solicitado.")
 		return
 	}
 
@@ -29,149 +34,117 @@ func ConsultarPortadasPelicula(c *gin.Context) {
 	defer cancel()
 
 	var portada dto.PortadaSelectDTO
-	// Usamos SelectOne para traer una sola portada
-	if err := db.SelectOne(ctx, config.Tablas["pp"], &portada, "p_id = ?", id); err != nil {
-		fmt.Printf("Error buscando portada para p_id=%s: %v\n", id, err) // Debug log
-		c.JSON(http.StatusOK, gin.H{
-			"mensaje": "No hay portada registrada para esta película",
-		})
+	// La portada es la imagen principal de la película
+	if err := db.SelectOne(ctx, config.Tablas["pp"], &portada, "p_id = ? AND is_primary = ?", id, true); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respuesta.NoEncontrado(c, "No hay portada registrada para esta película")
+			return
+		}
+		respuesta.ErrorBD(c, "Error buscando portada", err)
 		return
 	}
 
-	// Construir URL completa
-	// Asumimos que el host es el mismo del request, o se puede configurar en .env
+	completarPortada(c, &portada)
+
+	respuesta.OK(c, portada)
+}
+
+// urlPortada construye la URL completa de una portada según el almacenamiento configurado.
+// Si el almacenamiento retorna una ruta relativa (servida por ServirImagen) se firma con expiración
+// y se asume el host del request. Las absolutas ya vienen firmadas por el almacenamiento (ej: S3 prefirmada).
+func urlPortada(c *gin.Context, nombreArchivo string) string {
+	url := almacenamiento.Portadas.URL(nombreArchivo)
+	if !strings.HasPrefix(url, "/") {
+		return url
+	}
 	scheme := "http"
 	if c.Request.TLS != nil {
 		scheme = "https"
 	}
-	portada.Url = fmt.Sprintf("%s://%s/imagenes/%s", scheme, c.Request.Host, portada.NombreArchivo)
-
-	c.JSON(http.StatusOK, gin.H{
-		"portada": portada,
-	})
+	return fmt.Sprintf("%s://%s%s", scheme, c.Request.Host, almacenamiento.FirmarURL(url, nombreArchivo, time.Now()))
 }
 
-func CrearPortada(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Parámetro inválido.",
-		})
-		return
+// completarPortada asigna las URLs calculadas de la portada y sus miniaturas.
+func completarPortada(c *gin.Context, portada *dto.PortadaSelectDTO) {
+	portada.Url = urlPortada(c, portada.NombreArchivo)
+	portada.Miniaturas = make(map[int]string, len(helpers.AnchosMiniatura))
+	for _, ancho := range helpers.AnchosMiniatura {
+		portada.Miniaturas[ancho] = urlPortada(c, helpers.ClaveMiniatura(portada.NombreArchivo, ancho))
 	}
+}
 
-	// 1. Recibir archivo
-	file, err := c.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "No se subió ningún archivo: " + err.Error(),
-		})
-		return
+// guardarArchivosPortada sube la imagen y sus miniaturas. Si alguna falla borra las que alcanzó a subir.
+func guardarArchivosPortada(ctx context.Context, nombreArchivo string, imagen *helpers.ImagenProcesada) error {
+	archivos := map[string][]byte{nombreArchivo: imagen.Original}
+	for ancho, miniatura := range imagen.Miniaturas {
+		archivos[helpers.ClaveMiniatura(nombreArchivo, ancho)] = miniatura
 	}
 
-	// 2. Definir ruta y nombre
-	ext := filepath.Ext(file.Filename)
-	nuevoNombre := fmt.Sprintf("%d_%d%s", id, time.Now().UnixNano(), ext)
-	// Cambio de directorio a "portadas"
-	rutaDestino := filepath.Join("public", "upload", "portadas", nuevoNombre)
-
-	// Asegurar que directorio existe
-	if err := os.MkdirAll(filepath.Dir(rutaDestino), 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error creando directorio: " + err.Error(),
-		})
-		return
+	for clave, contenido := range archivos {
+		if err := almacenamiento.Portadas.Put(ctx, clave, bytes.NewReader(contenido), imagen.TipoContenido); err != nil {
+			borrarArchivosPortada(ctx, nombreArchivo)
+			return err
+		}
 	}
+	return nil
+}
 
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
-	defer cancel()
-
-	// 3. Verificar si ya existe portada para esta película y borrarla (regla de única portada)
-	var portadaExistente dto.PortadaSelectDTO
-	if err := db.SelectOne(ctx, config.Tablas["pp"], &portadaExistente, "p_id = ?", id); err == nil {
-		// Existe, borrar archivo viejo
-		rutaVieja := filepath.Join("public", "upload", "portadas", portadaExistente.NombreArchivo)
-		os.Remove(rutaVieja)
-		// Borrar registro viejo
-		db.Delete(ctx, config.Tablas["pp"], "id = ?", portadaExistente.ID)
+// borrarArchivosPortada borra la imagen y sus miniaturas. Los errores solo se registran en el log.
+func borrarArchivosPortada(ctx context.Context, nombreArchivo string) {
+	for _, clave := range helpers.ClavesPortada(nombreArchivo) {
+		if err := almacenamiento.Portadas.Delete(ctx, clave); err != nil {
+			log.Printf("Error borrando archivo %s: %v", clave, err)
+		}
 	}
+}
 
-	// 4. Guardar archivo nuevo
-	if err := c.SaveUploadedFile(file, rutaDestino); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error guardando archivo: " + err.Error(),
-		})
-		return
-	}
+// ServirImagen entrega un archivo del almacenamiento de portadas (GET /imagenes/*clave?exp=&sig=).
+// Solo responde URLs firmadas y vigentes generadas por urlPortada, así las imágenes no se pueden enlazar
+// desde otros sitios ni adivinar por su nombre.
+func ServirImagen(c *gin.Context) {
+	clave := strings.TrimPrefix(c.Param("clave"), "/")
 
-	// 5. Guardar en BD
-	portada := dto.PortadaInsertDTO{
-		PID:           int64(id),
-		NombreArchivo: nuevoNombre,
-		CreatedAt:     time.Now().In(config.Chilelocation),
+	expira, err := almacenamiento.VerificarFirma(clave, c.Query("exp"), c.Query("sig"), time.Now())
+	if err != nil {
+		respuesta.Error(c, http.StatusForbidden, respuesta.CodigoSinPermiso, err.Error())
+		return
 	}
 
-	if err := db.Insert(ctx, config.Tablas["pp"], &portada); err != nil {
-		os.Remove(rutaDestino)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error registrando en BD: " + err.Error(),
-		})
+	archivo, err := almacenamiento.Portadas.Get(c.Request.Context(), clave)
+	if err != nil {
+		if errors.Is(err, almacenamiento.ErrNoExiste) {
+			respuesta.NoEncontrado(c, "Imagen no encontrada")
+			return
+		}
+		log.Printf("Error leyendo imagen %s: %v", clave, err)
+		respuesta.Error(c, http.StatusInternalServerError, respuesta.CodigoErrorInterno, "Error leyendo imagen")
 		return
 	}
+	defer archivo.Close()
 
-	// Construir URL para respuesta
-	scheme := "http"
-	if c.Request.TLS != nil {
-		scheme = "https"
+	tipoContenido := mime.TypeByExtension(path.Ext(clave))
+	if tipoContenido == "" {
+		tipoContenido = "application/octet-stream"
 	}
-	url := fmt.Sprintf("%s://%s/imagenes/%s", scheme, c.Request.Host, nuevoNombre)
-
-	c.JSON(http.StatusCreated, gin.H{
-		"mensaje": "Portada subida correctamente",
-		"url":     url,
+	// El contenido de una clave no cambia (<pid>_<unixnano>.ext), se puede cachear mientras la URL esté vigente
+	c.DataFromReader(http.StatusOK, -1, tipoContenido, archivo, map[string]string{
+		"Cache-Control": fmt.Sprintf("private, max-age=%d", int(time.Until(expira).Seconds())),
 	})
 }
 
-func EliminarPortada(c *gin.Context) {
-	// idf: id de la foto/portada
-	idf := c.Param("idf")
-
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
-	defer cancel()
-
-	// 1. Obtener nombre archivo
-	var portada dto.PortadaSelectDTO
-	if err := db.SelectOne(ctx, config.Tablas["pp"], &portada, "id = ?", idf); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Portada no encontrada",
-		})
-		return
-	}
-
-	// 2. Borrar de BD
-	filas, err := db.Delete(ctx, config.Tablas["pp"], "id = ?", idf)
+// CrearPortada sube un poster y lo deja como imagen principal de la película.
+// Las imágenes anteriores se conservan en la galería (ver imagenesPelicula.go).
+func CrearPortada(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error eliminando de BD: " + err.Error(),
-		})
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
 		return
 	}
 
-	if filas == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Portada no encontrada al intentar borrar",
-		})
+	portada, ok := subirImagenPelicula(c, id, "poster", true)
+	if !ok {
 		return
 	}
 
-	// 3. Borrar archivo físico (ruta actualizada)
-	rutaArchivo := filepath.Join("public", "upload", "portadas", portada.NombreArchivo)
-	if err := os.Remove(rutaArchivo); err != nil {
-		fmt.Printf("Error borrando archivo físico %s: %v\n", rutaArchivo, err)
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"mensaje": "Portada eliminada correctamente",
-	})
+	respuesta.Creado(c, "Portada subida correctamente", portada)
 }
@@ -0,0 +1,113 @@
+package rutas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gosimple/slug"
+	"github.com/jgutierrez746/clase_7_gin_bun/config"
+	"github.com/jgutierrez746/clase_7_gin_bun/db"
+	"github.com/jgutierrez746/clase_7_gin_bun/dto"
+	"github.com/uptrace/bun"
+)
+
+// Veces que se reintenta una escritura cuando otra request tomó el mismo slug entre la consulta y el INSERT/UPDATE.
+const intentosSlug = 3
+
+// conReintentoSlug ejecuta fn (normalmente una transacción completa) y la repite si falla por clave duplicada.
+func conReintentoSlug(fn func() error) error {
+	var err error
+	for intento := 1; intento <= intentosSlug; intento++ {
+		if err = fn(); err == nil || !db.EsDuplicado(err) {
+			return err
+		}
+		log.Printf("Slug duplicado por escritura concurrente (intento %d de %d)", intento, intentosSlug)
+	}
+	return err
+}
+
+// slugBase es el slug del texto, o porDefecto si el texto no tiene letras ni números (ej: "???").
+func slugBase(texto, porDefecto string) string {
+	if s := slug.Make(texto); s != "" {
+		return s
+	}
+	return porDefecto
+}
+
+// slugPelicula retorna un slug libre para el título: "dracula", si está ocupado "dracula-1931" y luego "dracula-2", ...
+// excluirID es la película que se está editando (0 al crear).
+// Sin año (anio NULL) se pasa directo a "dracula-2".
+func slugPelicula(ctx context.Context, tx bun.IDB, titulo string, anio int, excluirID int64) (string, error) {
+	base, alternativas := candidatosSlugPelicula(titulo, anio)
+	usados := make(map[string]bool)
+	if err := slugsOcupadosPelicula(ctx, tx, base, excluirID, usados); err != nil {
+		return "", err
+	}
+	return db.SlugLibre(usados, base, alternativas...), nil
+}
+
+// candidatosSlugPelicula retorna la base del slug del título y la alternativa con el año, si lo hay.
+func candidatosSlugPelicula(titulo string, anio int) (string, []string) {
+	base := slugBase(titulo, "pelicula")
+	if anio == 0 {
+		return base, nil
+	}
+	return base, []string{fmt.Sprintf("%s-%d", base, anio)}
+}
+
+// slugsOcupadosPelicula agrega a usados los slugs de películas que compiten con base: los actuales y los antiguos
+// que redirigen a otra película (los de excluirID los puede recuperar).
+func slugsOcupadosPelicula(ctx context.Context, tx bun.IDB, base string, excluirID int64, usados map[string]bool) error {
+	if err := db.SlugsOcupados(ctx, tx, config.Tablas["pl"], "id", base, excluirID, usados); err != nil {
+		return err
+	}
+	return db.SlugsOcupados(ctx, tx, config.Tablas["sp"], "p_id", base, excluirID, usados)
+}
+
+// nuevoSlugPelicula retorna el slug de la película actual después de cambiar su título: el slug solo cambia si
+// cambia el título (no basta con una tilde o una mayúscula), y el anterior queda redirigiendo al nuevo.
+func nuevoSlugPelicula(ctx context.Context, tx bun.IDB, actual dto.PeliculaSelectDTO, titulo string, anio int) (string, error) {
+	if slugBase(titulo, "pelicula") == slugBase(actual.Titulo, "pelicula") {
+		return actual.Slug, nil
+	}
+	nuevo, err := slugPelicula(ctx, tx, titulo, anio, actual.ID)
+	if err != nil {
+		return "", err
+	}
+	if nuevo != actual.Slug {
+		if err := guardarSlugAntiguo(ctx, tx, actual.ID, actual.Slug, nuevo); err != nil {
+			return "", err
+		}
+	}
+	return nuevo, nil
+}
+
+// slugTematica retorna un slug libre para el nombre: "drama", si está ocupado "drama-2", ...
+func slugTematica(ctx context.Context, tx bun.IDB, nombre string, excluirID int64) (string, error) {
+	return db.SlugDisponible(ctx, tx, config.Tablas["tm"], slugBase(nombre, "tematica"), excluirID)
+}
+
+// nuevoSlugTematica retorna el slug de la temática actual después de cambiar su nombre. Igual que en películas,
+// el slug se conserva si el nombre normalizado no cambia.
+func nuevoSlugTematica(ctx context.Context, tx bun.IDB, actual dto.TematicasSelectOne, nombre string) (string, error) {
+	if slugBase(nombre, "tematica") == slugBase(actual.Nombre, "tematica") {
+		return actual.Slug, nil
+	}
+	return slugTematica(ctx, tx, nombre, actual.ID)
+}
+
+// guardarSlugAntiguo registra que la película id tuvo el slug anterior, para redirigirlo al actual.
+// Si la película recupera un slug que tuvo antes, ese deja de ser antiguo.
+func guardarSlugAntiguo(ctx context.Context, tx bun.IDB, id int64, anterior, actual string) error {
+	sp := config.Tablas["sp"]
+	if _, err := db.DeleteTx(ctx, tx, sp, "slug IN (?)", bun.In([]string{anterior, actual})); err != nil {
+		return err
+	}
+	return db.InsertTx(ctx, tx, sp, &dto.SlugPeliculaInsert{
+		PID:       id,
+		Slug:      anterior,
+		CreatedAt: time.Now().In(config.Chilelocation),
+	})
+}
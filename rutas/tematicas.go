@@ -3,54 +3,85 @@ package rutas
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"log"
-	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/gosimple/slug"
 	"github.com/jgutierrez746/clase_7_gin_bun/config"
 	"github.com/jgutierrez746/clase_7_gin_bun/db"
 	"github.com/jgutierrez746/clase_7_gin_bun/dto"
+	"github.com/jgutierrez746/clase_7_gin_bun/helpers"
+	"github.com/jgutierrez746/clase_7_gin_bun/respuesta"
+	"github.com/uptrace/bun"
 )
 
+var errTematicaNoEncontrada = errors.New("temática no encontrada")
+
+// camposTematicas es la lista blanca de campos por los que se puede ordenar y filtrar el listado.
+var camposTematicas = map[string]string{
+	"id":         "id",
+	"nombre":     "nombre",
+	"slug":       "slug",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
 func ConsultarTematicas(c *gin.Context) {
+	// ?after= o ?limit= activan la paginación por cursor (keyset)
+	if helpers.EsModoCursor(c) {
+		consultarTematicasCursor(c)
+		return
+	}
+
+	paginacion, err := helpers.ParsearPaginacion(c, camposTematicas, "id DESC")
+	if err != nil {
+		respuesta.SolicitudInvalida(c, err.Error())
+		return
+	}
+
 	// Definir contexto con tiempo de espera de solo 5 segundos
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	var tematicas dto.TemticasAllSelect
-	if err := db.SelectAll(ctx, config.Tablas["tm"], &tematicas); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error consultando temáticas: " + err.Error(),
-		})
+	tematicas := dto.TemticasAllSelect{}
+	total, err := db.SelectPaginado(ctx, config.Tablas["tm"], &tematicas, paginacion)
+	if err != nil {
+		respuesta.ErrorBD(c, "Error consultando temáticas", err)
 		return
 	}
 
-	// Si no hay datos, responde vacío
-	if len(tematicas) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"tematicas": []interface{}{},
-			"mensaje":   "No hay temáticas registradas",
-		})
+	log.Printf("Se consultaron %d de %d temáticas.", len(tematicas), total)
+	respuesta.Paginada(c, tematicas, total, paginacion)
+}
+
+// consultarTematicasCursor responde el listado paginado por keyset, estable ante inserciones concurrentes.
+func consultarTematicasCursor(c *gin.Context) {
+	paginacion, err := helpers.ParsearCursor(c, camposTematicas, "")
+	if err != nil {
+		respuesta.SolicitudInvalida(c, err.Error())
 		return
 	}
 
-	log.Printf("Se consultaron %d temáticas.", len(tematicas))
-	c.JSON(http.StatusOK, gin.H{
-		"tematicas": tematicas, // JSON con todos los campos (ID, Nombre, Slug)
-		"total":     len(tematicas),
-	})
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	tematicas := dto.TemticasAllSelect{}
+	siguiente, err := db.SelectCursor(ctx, config.Tablas["tm"], &tematicas, paginacion)
+	if err != nil {
+		respuesta.ErrorBD(c, "Error consultando temáticas", err)
+		return
+	}
+
+	respuesta.Cursor(c, tematicas, siguiente, paginacion)
 }
 
 func ConsultarTematicasPorId(c *gin.Context) {
 	id := c.Param("id")
 	if strings.TrimSpace(id) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "No se ingresó parámetro solicitado.",
-		})
+		respuesta.SolicitudInvalida(c, "No se ingresó parámetro solicitado.")
 		return
 	}
 	// Definir contexto con tiempo de espera de solo 5 segundos
@@ -61,70 +92,89 @@ func ConsultarTematicasPorId(c *gin.Context) {
 	var tematica dto.TematicasSelectOne
 	if err := db.SelectOne(ctx, config.Tablas["tm"], &tematica, "id = ?", id); err != nil {
 		if err == sql.ErrNoRows { // Si no existe, 404
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "Temática no encontrada",
-			})
+			respuesta.NoEncontrado(c, "Temática no encontrada")
 			return
 		}
-		log.Printf("Error en SelectOne: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error consultando temática: " + err.Error(),
-		})
+		respuesta.ErrorBD(c, "Error consultando temática", err)
 		return
 	}
 
 	log.Printf("Se consultó temática con ID: %s ", id)
-	c.JSON(http.StatusOK, gin.H{
-		"tematica": tematica, // JSON con todos los campos (ID, Nombre, Slug...)
-	})
+	if responderNoModificado(c, tematica.Version) {
+		return
+	}
+	respuesta.OK(c, tematica)
+}
+
+func ConsultarTematicaPorSlug(c *gin.Context) {
+	s := c.Param("slug")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	var tematica dto.TematicasSelectOne
+	if err := db.SelectOne(ctx, config.Tablas["tm"], &tematica, "slug = ?", s); err != nil {
+		if err == sql.ErrNoRows {
+			respuesta.NoEncontrado(c, "Temática no encontrada")
+			return
+		}
+		respuesta.ErrorBD(c, "Error consultando temática", err)
+		return
+	}
+
+	log.Printf("Se consultó temática con slug: %s ", s)
+	if responderNoModificado(c, tematica.Version) {
+		return
+	}
+	respuesta.OK(c, tematica)
 }
 
 func CrearTematica(c *gin.Context) {
 	var tematica dto.TematicasInsert
 	if err := c.ShouldBindJSON(&tematica); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Error al procesar el JSON " + err.Error(),
-		})
+		respuesta.ErrorBind(c, err)
 		return
 	}
 
 	nowChile := time.Now().In(config.Chilelocation)
-	tematica.Slug = slug.Make(tematica.Nombre)
 	tematica.CreatedAt = nowChile
 	tematica.UpdatedAt = nowChile
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	if err := db.Insert(ctx, config.Tablas["tm"], &tematica); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
+	err := conReintentoSlug(func() error {
+		return db.WithTx(ctx, func(tx bun.IDB) error {
+			var err error
+			if tematica.Slug, err = slugTematica(ctx, tx, tematica.Nombre, 0); err != nil {
+				return err
+			}
+			if err := db.InsertTx(ctx, tx, config.Tablas["tm"], &tematica); err != nil {
+				return err
+			}
+			return auditarTx(ctx, c, tx, accionCrear, config.Tablas["tm"], tematica.ID, nil, tematica)
 		})
+	})
+	if err != nil {
+		respuesta.ErrorBD(c, "Error creando temática", err)
 		return
 	}
 
 	// Se envía respuesta con el modelo actualizado
-	c.JSON(http.StatusCreated, gin.H{
-		"mensaje":  "Temática creada en Base de Datos",
-		"tematica": tematica,
-	})
+	respuesta.Creado(c, "Temática creada en Base de Datos", tematica)
 }
 
 func EditarTematica(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Parámetro inválido.",
-		})
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
 		return
 	}
 
 	var input dto.TematicasUpdate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Error al procesar el JSON " + err.Error(),
-		})
+		respuesta.ErrorBind(c, err)
 		return
 	}
 
@@ -132,62 +182,165 @@ func EditarTematica(c *gin.Context) {
 	defer cancel()
 
 	input.ID = int64(id)
-	input.Slug = slug.Make(input.Nombre)
 	input.UpdatedAt = time.Now().In(config.Chilelocation)
 
-	// Ejecutamos el Update
-	filasAfectadas, err := db.Update(ctx, config.Tablas["tm"], &input, "id = ?", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error en update: " + err.Error(),
+	var version int64
+	err = conReintentoSlug(func() error {
+		return db.WithTx(ctx, func(tx bun.IDB) error {
+			var actual dto.TematicasSelectOne
+			if err := db.SelectOneTx(ctx, tx, config.Tablas["tm"], &actual, true, "id = ?", id); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return errTematicaNoEncontrada
+				}
+				return err
+			}
+			if err := verificarIfMatch(c, actual.Version); err != nil {
+				return err
+			}
+
+			var err error
+			if input.Slug, err = nuevoSlugTematica(ctx, tx, actual, input.Nombre); err != nil {
+				return err
+			}
+
+			if _, err = db.UpdateConVersionTx(ctx, tx, config.Tablas["tm"], &input, actual.Version, "id = ?", id); err != nil {
+				return err
+			}
+			version = actual.Version + 1
+			return auditarTx(ctx, c, tx, accionActualizar, config.Tablas["tm"], id, actual, input)
 		})
+	})
+	if errors.Is(err, errTematicaNoEncontrada) {
+		respuesta.NoEncontrado(c, "Temática no encontrada")
 		return
 	}
-
-	if filasAfectadas == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Temática no encontrada",
-		})
+	if responderPrecondicion(c, err) {
+		return
+	}
+	if err != nil {
+		respuesta.ErrorBD(c, "Error en update", err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"mensaje":  "Temática editada correctamente",
-		"tematica": input,
-	})
+	c.Header("ETag", helpers.ETag(version))
+	respuesta.OKConMensaje(c, "Temática editada correctamente", input)
 }
 
-func EliminarTematica(c *gin.Context) {
+// ModificarTematica actualiza solo los campos que vienen en el cuerpo (PATCH con JSON Merge Patch, ver dto.Campo).
+func ModificarTematica(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Parámetro inválido.",
-		})
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
+		return
+	}
+
+	var patch dto.TematicaPatch
+	if err := helpers.ParsearPatch(c, &patch); err != nil {
+		respuesta.ErrorBind(c, err)
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	// Ejecutamos Delete
-	filasAfectadas, err := db.Delete(ctx, config.Tablas["tm"], "id = ?", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error eliminando: " + err.Error(),
+	var tematica dto.TematicasSelectOne
+	err = conReintentoSlug(func() error {
+		return db.WithTx(ctx, func(tx bun.IDB) error {
+			var actual dto.TematicasSelectOne
+			if err := db.SelectOneTx(ctx, tx, config.Tablas["tm"], &actual, true, "id = ?", id); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return errTematicaNoEncontrada
+				}
+				return err
+			}
+
+			if err := verificarIfMatch(c, actual.Version); err != nil {
+				return err
+			}
+
+			tematica = actual
+			columnas := helpers.ColumnasPatch(&patch)
+			if len(columnas) == 0 {
+				return nil
+			}
+
+			patch.Nombre.Aplicar(&tematica.Nombre)
+
+			var err error
+			if tematica.Slug, err = nuevoSlugTematica(ctx, tx, actual, tematica.Nombre); err != nil {
+				return err
+			}
+			tematica.UpdatedAt = time.Now().In(config.Chilelocation)
+			tematica.Version++
+
+			columnas["slug"] = tematica.Slug
+			columnas["updated_at"] = tematica.UpdatedAt
+			if _, err = db.UpdateConVersionTx(ctx, tx, config.Tablas["tm"], &columnas, actual.Version, "id = ?", id); err != nil {
+				return err
+			}
+			return auditarTx(ctx, c, tx, accionActualizar, config.Tablas["tm"], id, actual, tematica)
 		})
+	})
+	if errors.Is(err, errTematicaNoEncontrada) {
+		respuesta.NoEncontrado(c, "Temática no encontrada")
+		return
+	}
+	if responderPrecondicion(c, err) {
+		return
+	}
+	if err != nil {
+		respuesta.ErrorBD(c, "Error en update", err)
 		return
 	}
 
-	if filasAfectadas == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Temática no encontrada",
-		})
+	c.Header("ETag", helpers.ETag(tematica.Version))
+	respuesta.OKConMensaje(c, "Temática modificada correctamente", tematica)
+}
+
+func EliminarTematica(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"mensaje":    "Temática eliminada correctamente",
-		"eliminados": filasAfectadas,
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	var filasAfectadas int64
+	err = db.WithTx(ctx, func(tx bun.IDB) error {
+		var actual dto.TematicasSelectOne
+		if err := db.SelectOneTx(ctx, tx, config.Tablas["tm"], &actual, true, "id = ?", id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errTematicaNoEncontrada
+			}
+			return err
+		}
+		if err := verificarIfMatch(c, actual.Version); err != nil {
+			return err
+		}
+
+		// Queda en la papelera hasta que se restaure o se purgue (ver papelera.Purgar)
+		borrado := map[string]interface{}{"deleted_at": time.Now().In(config.Chilelocation)}
+		var err error
+		if filasAfectadas, err = db.UpdateConVersionTx(ctx, tx, config.Tablas["tm"], &borrado, actual.Version, "id = ?", id); err != nil {
+			return err
+		}
+		return auditarTx(ctx, c, tx, accionEliminar, config.Tablas["tm"], id, actual, nil)
 	})
+	if errors.Is(err, errTematicaNoEncontrada) {
+		respuesta.NoEncontrado(c, "Temática no encontrada")
+		return
+	}
+	if responderPrecondicion(c, err) {
+		return
+	}
+	if err != nil {
+		respuesta.ErrorBD(c, "Error eliminando", err)
+		return
+	}
+
+	respuesta.Eliminado(c, "Temática enviada a la papelera", filasAfectadas)
 }
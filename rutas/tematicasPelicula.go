@@ -2,6 +2,8 @@ package rutas
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -12,14 +14,16 @@ import (
 	"github.com/jgutierrez746/clase_7_gin_bun/config"
 	"github.com/jgutierrez746/clase_7_gin_bun/db"
 	"github.com/jgutierrez746/clase_7_gin_bun/dto"
+	"github.com/jgutierrez746/clase_7_gin_bun/respuesta"
+	"github.com/uptrace/bun"
 )
 
+var errTematicasInexistentes = errors.New("una o más temáticas no existen")
+
 func ConsultarTematicasPelicula(c *gin.Context) {
 	id := c.Param("id")
 	if strings.TrimSpace(id) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "No se ingresó parámetro solicitado.",
-		})
+		respuesta.SolicitudInvalida(c, "No se ingresó parámetro solicitado.")
 		return
 	}
 
@@ -32,7 +36,7 @@ func ConsultarTematicasPelicula(c *gin.Context) {
 	tm := config.Tablas["tm"]
 
 	var tablasJoin = []string{
-		fmt.Sprintf("LEFT JOIN %s ON (%s.tematica_id = %s.id)", tm, pt, tm),
+		fmt.Sprintf("JOIN %s ON (%s.tematica_id = %s.id AND %s.deleted_at IS NULL)", tm, pt, tm, tm), // Sin las temáticas en la papelera
 	}
 
 	var columnas = []string{
@@ -44,41 +48,29 @@ func ConsultarTematicasPelicula(c *gin.Context) {
 	order := fmt.Sprintf("%s.orden ASC", pt)
 
 	if err := db.SelectConJoin(ctx, config.Tablas["pt"], tablasJoin, columnas, &modelo, order, where, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error consultando temáticas asociadas: " + err.Error(),
-		})
-		return
-	}
-
-	if len(modelo) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"peliculas": []interface{}{},
-			"mensaje":   "No hay temáticas registradas para esta película",
-		})
+		respuesta.ErrorBD(c, "Error consultando temáticas asociadas", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"tematicas_asociadas": modelo,
-		"total":               len(modelo),
-	})
+	respuesta.Lista(c, modelo)
 }
 
 func CrearTematicasPelicula(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Parámetro inválido.",
-		})
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
 		return
 	}
 
 	var peliculaTematicas []dto.PeliculaTematicasInsert
 	if err := c.ShouldBindJSON(&peliculaTematicas); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Error al procesar el JSON " + err.Error(),
-		})
+		respuesta.ErrorBind(c, err)
+		return
+	}
+
+	if len(peliculaTematicas) == 0 {
+		respuesta.SolicitudInvalida(c, "Se debe enviar al menos una temática")
 		return
 	}
 
@@ -92,28 +84,72 @@ func CrearTematicasPelicula(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	insertados, err := db.InsertBatch(ctx, config.Tablas["pt"], peliculaTematicas)
+	// Verificar la película y asociar las temáticas en una transacción: si falla alguna fila no queda ninguna
+	var insertados int64
+	err = db.WithTx(ctx, func(tx bun.IDB) error {
+		if err := bloquearPelicula(ctx, tx, int64(id)); err != nil {
+			return err
+		}
+
+		// La FK acepta las temáticas en la papelera, pero no se pueden asociar
+		if err := verificarTematicas(ctx, tx, peliculaTematicas); err != nil {
+			return err
+		}
+
+		var err error
+		if insertados, err = db.InsertBatchTx(ctx, tx, config.Tablas["pt"], peliculaTematicas); err != nil {
+			return err
+		}
+		return auditarTx(ctx, c, tx, accionCrear, config.Tablas["pt"], id, nil, peliculaTematicas)
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		if errors.Is(err, errPeliculaNoEncontrada) {
+			respuesta.NoEncontrado(c, "Película no encontrada")
+			return
+		}
+		if errors.Is(err, errTematicasInexistentes) {
+			respuesta.Error(c, http.StatusUnprocessableEntity, db.CodigoReferenciaInvalida, err.Error())
+			return
+		}
+		respuesta.ErrorBD(c, "Error asociando temáticas", err)
 		return
 	}
 
 	// Se envía respuesta con el modelo actualizado
-	c.JSON(http.StatusCreated, gin.H{
-		"mensaje":    "Temáticas asociadas se registraron correctamente",
-		"insertados": insertados,
-	})
+	respuesta.Creado(c, "Temáticas asociadas se registraron correctamente", insertados)
+}
+
+// verificarTematicas retorna errTematicasInexistentes si alguna de las temáticas no existe o está en la papelera.
+func verificarTematicas(ctx context.Context, tx bun.IDB, peliculaTematicas []dto.PeliculaTematicasInsert) error {
+	ids := make(map[int64]bool, len(peliculaTematicas))
+	lista := make([]int64, 0, len(peliculaTematicas))
+	for _, pt := range peliculaTematicas {
+		if !ids[pt.TematicaID] {
+			ids[pt.TematicaID] = true
+			lista = append(lista, pt.TematicaID)
+		}
+	}
+
+	var existentes []dto.TematicasSelectOne
+	if err := db.SelectTx(ctx, tx, config.Tablas["tm"], &existentes, "", "id IN (?)", bun.In(lista)); err != nil {
+		return err
+	}
+	if len(existentes) != len(lista) {
+		return errTematicasInexistentes
+	}
+	return nil
 }
 
 func EliminarTematicaPelicula(c *gin.Context) {
 	id := c.Param("id")
 	idt := c.Param("idt")
 	if strings.TrimSpace(id) == "" || strings.TrimSpace(idt) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "No se ingresó uno o más parámetros solicitados.",
-		})
+		respuesta.SolicitudInvalida(c, "No se ingresó uno o más parámetros solicitados.")
+		return
+	}
+	pid, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
 		return
 	}
 
@@ -122,24 +158,35 @@ func EliminarTematicaPelicula(c *gin.Context) {
 
 	where := "p_id = ? AND tematica_id = ?"
 
-	// Ejecutamos Delete
-	filasAfectadas, err := db.Delete(ctx, config.Tablas["pt"], where, id, idt)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error eliminando temática asociada: " + err.Error(),
-		})
+	var filasAfectadas int64
+	err = db.WithTx(ctx, func(tx bun.IDB) error {
+		if err := bloquearPelicula(ctx, tx, pid); err != nil {
+			return err
+		}
+
+		var antes dto.PeliculaTematicasSelectOne
+		if err := db.SelectOneTx(ctx, tx, config.Tablas["pt"], &antes, true, where, id, idt); err != nil {
+			return err
+		}
+
+		var err error
+		if filasAfectadas, err = db.DeleteTx(ctx, tx, config.Tablas["pt"], where, id, idt); err != nil {
+			return err
+		}
+		return auditarTx(ctx, c, tx, accionEliminar, config.Tablas["pt"], id+":"+idt, antes, nil)
+	})
+	if errors.Is(err, errPeliculaNoEncontrada) {
+		respuesta.NoEncontrado(c, "Película no encontrada")
 		return
 	}
-
-	if filasAfectadas == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Temática asociada no encontrada",
-		})
+	if errors.Is(err, sql.ErrNoRows) {
+		respuesta.NoEncontrado(c, "Temática asociada no encontrada")
+		return
+	}
+	if err != nil {
+		respuesta.ErrorBD(c, "Error eliminando temática asociada", err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"mensaje":    "Temática asociada eliminada correctamente",
-		"eliminados": filasAfectadas,
-	})
+	respuesta.Eliminado(c, "Temática asociada eliminada correctamente", filasAfectadas)
 }
@@ -3,6 +3,7 @@ package rutas
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
@@ -13,18 +14,49 @@ import (
 	"github.com/jgutierrez746/clase_7_gin_bun/config"
 	"github.com/jgutierrez746/clase_7_gin_bun/db"
 	"github.com/jgutierrez746/clase_7_gin_bun/dto"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/jgutierrez746/clase_7_gin_bun/helpers"
+	jwtPkg "github.com/jgutierrez746/clase_7_gin_bun/jwt"
+	"github.com/jgutierrez746/clase_7_gin_bun/respuesta"
+	"github.com/uptrace/bun"
 )
 
-func ConsultarUsuarios(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
-	defer cancel()
+// camposUsuarios es la lista blanca de campos por los que se puede ordenar y filtrar el listado.
+// Las columnas van calificadas con la tabla por el JOIN con perfiles.
+var camposUsuarios = map[string]string{
+	"id":         config.Tablas["u"] + ".id",
+	"nombre":     config.Tablas["u"] + ".nombre",
+	"correo":     config.Tablas["u"] + ".correo",
+	"perfil_id":  config.Tablas["u"] + ".perfil_id",
+	"created_at": config.Tablas["u"] + ".created_at",
+	"updated_at": config.Tablas["u"] + ".updated_at",
+}
 
-	var usuarios []dto.UsuarioPerfilDTO
+// usuarioAuditado son las columnas de un usuario que se guardan en la auditoría: nunca la contraseña, solo si cambió.
+type usuarioAuditado struct {
+	ID               int64      `json:"id" bun:"id"`
+	Nombre           string     `json:"nombre" bun:"nombre"`
+	Correo           string     `json:"correo" bun:"correo"`
+	Telefono         string     `json:"telefono" bun:"telefono"`
+	PerfilID         int64      `json:"perfil_id" bun:"perfil_id"`
+	DeletedAt        *time.Time `json:"deleted_at,omitempty" bun:"deleted_at"`
+	PasswordCambiada bool       `json:"password_cambiada,omitempty" bun:"-"`
+}
 
+func ConsultarUsuarios(c *gin.Context) {
 	u := config.Tablas["u"]
 	p := config.Tablas["p"]
 
+	paginacion, err := helpers.ParsearPaginacion(c, camposUsuarios, u+".id DESC")
+	if err != nil {
+		respuesta.SolicitudInvalida(c, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	usuarios := []dto.UsuarioPerfilDTO{}
+
 	var tablasJoin = []string{
 		"JOIN " + p + " ON " + u + ".perfil_id = " + p + ".id",
 	}
@@ -34,39 +66,41 @@ func ConsultarUsuarios(c *gin.Context) {
 		p + ".nombre AS perfil_nombre",
 	}
 
-	if err := db.SelectConJoin(ctx, config.Tablas["u"], tablasJoin, columnas, &usuarios, u+".id DESC", ""); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error consultando usuarios: " + err.Error(),
-		})
-		return
-	}
-
-	if len(usuarios) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"usuarios": []interface{}{},
-			"mensaje":  "No hay usuarios registrados",
-		})
+	total, err := db.SelectConJoinPaginado(ctx, config.Tablas["u"], tablasJoin, columnas, &usuarios, paginacion, "")
+	if err != nil {
+		respuesta.ErrorBD(c, "Error consultando usuarios", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"usuarios": usuarios,
-		"total":    len(usuarios),
-	})
+	respuesta.Paginada(c, usuarios, total, paginacion)
 }
 
 func ConsultarUsuarioPorId(c *gin.Context) {
 	id := c.Param("id")
 	if strings.TrimSpace(id) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "No se ingresó parámetro solicitado.",
-		})
+		respuesta.SolicitudInvalida(c, "No se ingresó parámetro solicitado.")
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
+	usuario, err := consultarUsuario(ctx, id)
+	if err != nil {
+		respuesta.ErrorBD(c, "Error consultando usuario", err)
+		return
+	}
+
+	if usuario == nil {
+		respuesta.NoEncontrado(c, "Usuario no encontrado")
+		return
+	}
+
+	respuesta.OK(c, usuario)
+}
+
+// consultarUsuario retorna el usuario id con el nombre de su perfil, o nil si no existe.
+func consultarUsuario(ctx context.Context, id interface{}) (*dto.UsuarioPerfilDTO, error) {
 	var usuarios []dto.UsuarioPerfilDTO
 
 	u := config.Tablas["u"]
@@ -84,42 +118,29 @@ func ConsultarUsuarioPorId(c *gin.Context) {
 	where := u + ".id = ?"
 
 	if err := db.SelectConJoin(ctx, config.Tablas["u"], tablasJoin, columnas, &usuarios, "", where, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error consultando usuario: " + err.Error(),
-		})
-		return
+		return nil, err
 	}
 
 	if len(usuarios) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Usuario no encontrado",
-		})
-		return
+		return nil, nil
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"usuario": usuarios[0],
-	})
+	return &usuarios[0], nil
 }
 
 func CrearUsuario(c *gin.Context) {
 	var input dto.UsuarioInsert
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Error de validación: " + err.Error(),
-		})
+		respuesta.ErrorBind(c, err)
 		return
 	}
 
 	// Hashear password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), 8)
+	hashedPassword, err := helpers.HashPassword(input.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error procesando contraseña",
-		})
+		respuesta.Error(c, http.StatusInternalServerError, respuesta.CodigoErrorInterno, "Error procesando contraseña")
 		return
 	}
-	input.Password = string(hashedPassword)
+	input.Password = hashedPassword
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
@@ -129,118 +150,189 @@ func CrearUsuario(c *gin.Context) {
 	// Usamos SelectOne para validar existencia (retornará error si no existe)
 	if err := db.SelectOne(ctx, config.Tablas["p"], &perfilDummy, "id = ?", input.PerfilID); err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "El PerfilID no existe."})
+			respuesta.Error(c, http.StatusUnprocessableEntity, db.CodigoReferenciaInvalida, "El PerfilID no existe.")
 			return
 		}
 		// Otro error de base de datos
 		log.Println("Error verificando perfil:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error verificando perfil"})
+		respuesta.Error(c, http.StatusInternalServerError, respuesta.CodigoErrorInterno, "Error verificando perfil")
 		return
 	}
 
-	if err := db.Insert(ctx, config.Tablas["u"], &input); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error creando usuario: " + err.Error(),
-		})
+	err = db.WithTx(ctx, func(tx bun.IDB) error {
+		if err := db.InsertTx(ctx, tx, config.Tablas["u"], &input); err != nil {
+			return err
+		}
+		var despues usuarioAuditado
+		if err := db.SelectOneTx(ctx, tx, config.Tablas["u"], &despues, false, "id = ?", input.ID); err != nil {
+			return err
+		}
+		return auditarTx(ctx, c, tx, accionCrear, config.Tablas["u"], input.ID, nil, despues)
+	})
+	if err != nil {
+		respuesta.ErrorBD(c, "Error creando usuario", err)
 		return
 	}
 
 	// Limpiar password para respuesta
 	input.Password = ""
 
-	c.JSON(http.StatusCreated, gin.H{
-		"mensaje": "Usuario creado exitosamente",
-		"usuario": input,
-	})
+	respuesta.Creado(c, "Usuario creado exitosamente", input)
 }
 
 func EditarUsuario(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Parámetro inválido.",
-		})
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
 		return
 	}
 
 	var input dto.UsuarioUpdate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Error de validación: " + err.Error(),
-		})
+		respuesta.ErrorBind(c, err)
 		return
 	}
 
+	// Los campos vacíos conservan su valor (ej: sin password no se cambia la contraseña)
+	var patch dto.UsuarioPatch
+	if input.Nombre != "" {
+		patch.Nombre = dto.Campo[string]{Presente: true, Valor: input.Nombre}
+	}
+	if input.Correo != "" {
+		patch.Correo = dto.Campo[string]{Presente: true, Valor: input.Correo}
+	}
+	if input.Telefono != "" {
+		patch.Telefono = dto.Campo[string]{Presente: true, Valor: input.Telefono}
+	}
 	if input.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), 8)
+		patch.Password = dto.Campo[string]{Presente: true, Valor: input.Password}
+	}
+	if input.PerfilID != 0 {
+		patch.PerfilID = dto.Campo[int64]{Presente: true, Valor: input.PerfilID}
+	}
+
+	actualizarUsuario(c, id, patch, "Usuario actualizado correctamente")
+}
+
+// ModificarUsuario actualiza solo los campos que vienen en el cuerpo (PATCH con JSON Merge Patch, ver dto.Campo).
+func ModificarUsuario(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
+		return
+	}
+
+	var patch dto.UsuarioPatch
+	if err := helpers.ParsearPatch(c, &patch); err != nil {
+		respuesta.ErrorBind(c, err)
+		return
+	}
+
+	actualizarUsuario(c, id, patch, "Usuario modificado correctamente")
+}
+
+// actualizarUsuario guarda los campos presentes en patch y responde el usuario actualizado.
+// Si cambia la contraseña se cierran las sesiones abiertas del usuario.
+func actualizarUsuario(c *gin.Context, id int, patch dto.UsuarioPatch, mensaje string) {
+	if patch.Password.Asignado() {
+		hashedPassword, err := helpers.HashPassword(patch.Password.Valor)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Error procesando contraseña",
-			})
+			respuesta.Error(c, http.StatusInternalServerError, respuesta.CodigoErrorInterno, "Error procesando contraseña")
 			return
 		}
-		input.Password = string(hashedPassword)
+		patch.Password.Valor = hashedPassword
 	}
 
-	input.UpdatedAt = time.Now().In(config.Chilelocation)
-	input.ID = int64(id)
-
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	filasAfectadas, err := db.Update(ctx, config.Tablas["u"], &input, "id = ?", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error actualizando usuario: " + err.Error(),
+	// Un perfil_id inexistente lo rechaza la FK (422)
+	if columnas := helpers.ColumnasPatch(&patch); len(columnas) > 0 {
+		columnas["updated_at"] = time.Now().In(config.Chilelocation)
+		err := db.WithTx(ctx, func(tx bun.IDB) error {
+			var antes, despues usuarioAuditado
+			if err := db.SelectOneTx(ctx, tx, config.Tablas["u"], &antes, true, "id = ?", id); err != nil {
+				return err
+			}
+			if _, err := db.UpdateColumnasTx(ctx, tx, config.Tablas["u"], columnas, "id = ?", id); err != nil {
+				return err
+			}
+			if err := db.SelectOneTx(ctx, tx, config.Tablas["u"], &despues, false, "id = ?", id); err != nil {
+				return err
+			}
+			despues.PasswordCambiada = patch.Password.Asignado()
+			return auditarTx(ctx, c, tx, accionActualizar, config.Tablas["u"], id, antes, despues)
 		})
-		return
+		if errors.Is(err, sql.ErrNoRows) {
+			respuesta.NoEncontrado(c, "Usuario no encontrado")
+			return
+		}
+		if err != nil {
+			respuesta.ErrorBD(c, "Error actualizando usuario", err)
+			return
+		}
 	}
 
-	if filasAfectadas == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Usuario no encontrado",
-		})
+	usuario, err := consultarUsuario(ctx, id)
+	if err != nil {
+		respuesta.ErrorBD(c, "Error consultando usuario", err)
+		return
+	}
+	if usuario == nil {
+		respuesta.NoEncontrado(c, "Usuario no encontrado")
 		return
 	}
 
-	input.Password = "" // No devolver hash
-	c.JSON(http.StatusOK, gin.H{
-		"mensaje": "Usuario actualizado correctamente",
-		"usuario": input,
-	})
+	if patch.Password.Asignado() {
+		if err := jwtPkg.RevocarRefreshTokensUsuario(ctx, usuario.ID); err != nil {
+			log.Println("Error revocando sesiones del usuario:", err)
+		}
+	}
+
+	respuesta.OKConMensaje(c, mensaje, usuario)
 }
 
 func EliminarUsuario(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Parámetro inválido.",
-		})
+		respuesta.SolicitudInvalida(c, "Parámetro inválido.")
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	filasAfectadas, err := db.Delete(ctx, config.Tablas["u"], "id = ?", id)
+	var filasAfectadas int64
+	err = db.WithTx(ctx, func(tx bun.IDB) error {
+		var antes usuarioAuditado
+		if err := db.SelectOneTx(ctx, tx, config.Tablas["u"], &antes, true, "id = ?", id); err != nil {
+			return err
+		}
+
+		// Queda en la papelera hasta que se restaure o se purgue (ver papelera.Purgar)
+		borrado := map[string]interface{}{"deleted_at": time.Now().In(config.Chilelocation)}
+		var err error
+		if filasAfectadas, err = db.UpdateColumnasTx(ctx, tx, config.Tablas["u"], borrado, "id = ?", id); err != nil {
+			return err
+		}
+		return auditarTx(ctx, c, tx, accionEliminar, config.Tablas["u"], id, antes, nil)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respuesta.NoEncontrado(c, "Usuario no encontrado")
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error eliminando usuario: " + err.Error(),
-		})
+		respuesta.ErrorBD(c, "Error eliminando usuario", err)
 		return
 	}
 
-	if filasAfectadas == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Usuario no encontrado",
-		})
-		return
+	// Un usuario en la papelera no puede renovar sus sesiones
+	if err := jwtPkg.RevocarRefreshTokensUsuario(ctx, int64(id)); err != nil {
+		log.Println("Error revocando sesiones del usuario:", err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"mensaje":    "Usuario eliminado correctamente",
-		"eliminados": filasAfectadas,
-	})
+	respuesta.Eliminado(c, "Usuario enviado a la papelera", filasAfectadas)
 }
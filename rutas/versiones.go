@@ -0,0 +1,45 @@
+package rutas
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jgutierrez746/clase_7_gin_bun/db"
+	"github.com/jgutierrez746/clase_7_gin_bun/helpers"
+	"github.com/jgutierrez746/clase_7_gin_bun/respuesta"
+)
+
+// errPrecondicionFallida es un If-Match que no corresponde a la versión actual del registro.
+var errPrecondicionFallida = errors.New("el registro fue modificado por otra solicitud")
+
+// verificarIfMatch retorna errPrecondicionFallida si el If-Match de la request no corresponde a version.
+// Se llama con la fila ya bloqueada (FOR UPDATE), así nadie la cambia entre la verificación y la escritura.
+func verificarIfMatch(c *gin.Context, version int64) error {
+	if !helpers.CumpleIfMatch(c, version) {
+		return errPrecondicionFallida
+	}
+	return nil
+}
+
+// responderPrecondicion responde 412 si err es un If-Match que no coincide o una escritura concurrente detectada
+// por la versión (db.ErrVersion). Retorna si respondió.
+func responderPrecondicion(c *gin.Context, err error) bool {
+	if !errors.Is(err, errPrecondicionFallida) && !errors.Is(err, db.ErrVersion) {
+		return false
+	}
+	respuesta.Error(c, http.StatusPreconditionFailed, respuesta.CodigoPrecondicion,
+		"El registro fue modificado por otra solicitud, se debe consultar de nuevo antes de modificarlo")
+	return true
+}
+
+// responderNoModificado agrega el ETag de version a la respuesta y, si coincide con el If-None-Match de la request,
+// responde 304 sin cuerpo. Retorna si respondió.
+func responderNoModificado(c *gin.Context, version int64) bool {
+	c.Header("ETag", helpers.ETag(version))
+	if helpers.NoModificado(c, version) {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+	return false
+}
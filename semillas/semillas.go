@@ -0,0 +1,155 @@
+package semillas
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/gosimple/slug"
+	"github.com/jgutierrez746/clase_7_gin_bun/config"
+	"github.com/jgutierrez746/clase_7_gin_bun/db"
+	"github.com/jgutierrez746/clase_7_gin_bun/dto"
+	"github.com/jgutierrez746/clase_7_gin_bun/helpers"
+	"github.com/uptrace/bun"
+)
+
+// peliculaSemilla es una película de ejemplo con los nombres de sus temáticas en orden.
+type peliculaSemilla struct {
+	Pelicula  dto.PeliculaInsert
+	Tematicas []string
+}
+
+var tematicasSemilla = []string{"Terror", "Ciencia Ficción", "Drama", "Comedia", "Animación", "Fantasía"}
+
+var peliculasSemilla = []peliculaSemilla{
+	{
+		Pelicula: dto.PeliculaInsert{
+			Anio:        1931,
+			Titulo:      "Drácula",
+			Descripcion: "El conde Drácula viaja de Transilvania a Londres en busca de nuevas víctimas.",
+			Director:    "Tod Browning",
+		},
+		Tematicas: []string{"Terror", "Fantasía"},
+	},
+	{
+		Pelicula: dto.PeliculaInsert{
+			Anio:        1982,
+			Titulo:      "Blade Runner",
+			Descripcion: "Un cazador de replicantes persigue a un grupo de androides fugitivos en Los Ángeles.",
+			Director:    "Ridley Scott",
+		},
+		Tematicas: []string{"Ciencia Ficción", "Drama"},
+	},
+	{
+		Pelicula: dto.PeliculaInsert{
+			Anio:        2001,
+			Titulo:      "El viaje de Chihiro",
+			Descripcion: "Una niña queda atrapada en un mundo de espíritus y debe trabajar en una casa de baños.",
+			Director:    "Hayao Miyazaki",
+		},
+		Tematicas: []string{"Animación", "Fantasía"},
+	},
+	{
+		Pelicula: dto.PeliculaInsert{
+			Anio:        1936,
+			Titulo:      "Tiempos modernos",
+			Descripcion: "Un obrero lucha por sobrevivir en la era industrial.",
+			Director:    "Charles Chaplin",
+		},
+		Tematicas: []string{"Comedia", "Drama"},
+	},
+	{
+		Pelicula: dto.PeliculaInsert{
+			Anio:        2006,
+			Titulo:      "El laberinto del fauno",
+			Descripcion: "En la posguerra española, una niña descubre un laberinto habitado por un fauno.",
+			Director:    "Guillermo del Toro",
+		},
+		Tematicas: []string{"Fantasía", "Drama", "Terror"},
+	},
+}
+
+// Ejecutar carga las temáticas y películas de ejemplo. Es idempotente: omite las que ya existen por slug.
+func Ejecutar(ctx context.Context) error {
+	nowChile := time.Now().In(config.Chilelocation)
+
+	idsTematicas := make(map[string]int64, len(tematicasSemilla))
+	for _, nombre := range tematicasSemilla {
+		tematica := dto.TematicasInsert{
+			Nombre:    nombre,
+			Slug:      slug.Make(nombre),
+			CreatedAt: nowChile,
+			UpdatedAt: nowChile,
+		}
+
+		id, existe, err := idPorSlug(ctx, config.Tablas["tm"], tematica.Slug)
+		if err != nil {
+			return err
+		}
+		if !existe {
+			if err := db.Insert(ctx, config.Tablas["tm"], &tematica); err != nil {
+				return err
+			}
+			id = tematica.ID
+			log.Printf("Temática creada: %s", nombre)
+		}
+		idsTematicas[nombre] = id
+	}
+
+	for _, semilla := range peliculasSemilla {
+		pelicula := semilla.Pelicula
+		pelicula.Slug = slug.Make(pelicula.Titulo)
+		pelicula.Busqueda = helpers.NormalizarBusqueda(pelicula.Titulo, pelicula.Descripcion, pelicula.Director)
+		pelicula.CreatedAt = nowChile
+		pelicula.UpdatedAt = nowChile
+
+		_, existe, err := idPorSlug(ctx, config.Tablas["pl"], pelicula.Slug)
+		if err != nil {
+			return err
+		}
+		if existe {
+			continue
+		}
+
+		// La película y sus temáticas van juntas, así una semilla a medias no queda marcada como existente
+		err = db.WithTx(ctx, func(tx bun.IDB) error {
+			if err := db.InsertTx(ctx, tx, config.Tablas["pl"], &pelicula); err != nil {
+				return err
+			}
+
+			asociaciones := make([]dto.PeliculaTematicasInsert, 0, len(semilla.Tematicas))
+			for i, nombre := range semilla.Tematicas {
+				asociaciones = append(asociaciones, dto.PeliculaTematicasInsert{
+					PID:        pelicula.ID,
+					TematicaID: idsTematicas[nombre],
+					Orden:      i + 1,
+					CreatedAt:  nowChile,
+				})
+			}
+			_, err := db.InsertBatchTx(ctx, tx, config.Tablas["pt"], asociaciones)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("Película creada: %s", pelicula.Titulo)
+	}
+
+	return nil
+}
+
+// idPorSlug busca el id de una fila por su slug.
+func idPorSlug(ctx context.Context, tabla, slugBuscado string) (int64, bool, error) {
+	var fila struct {
+		ID int64 `bun:"id"`
+	}
+	// Lo que está en la papelera también existe: su slug sigue ocupado
+	if err := db.SelectOne(db.IncluirBorrados(ctx), tabla, &fila, "slug = ?", slugBuscado); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return fila.ID, true, nil
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jgutierrez746/clase_7_gin_bun/almacenamiento"
+	"github.com/jgutierrez746/clase_7_gin_bun/db"
+	"github.com/jgutierrez746/clase_7_gin_bun/helpers"
+	auth "github.com/jgutierrez746/clase_7_gin_bun/jwt"
+	"github.com/jgutierrez746/clase_7_gin_bun/migraciones"
+	"github.com/jgutierrez746/clase_7_gin_bun/papelera"
+	"github.com/jgutierrez746/clase_7_gin_bun/reconciliacion"
+	"github.com/jgutierrez746/clase_7_gin_bun/respuesta"
+	"github.com/jgutierrez746/clase_7_gin_bun/rutas"
+)
+
+// servir aplica las migraciones (si DB_AUTO_MIGRATE=true) e inicia el servidor HTTP hasta recibir SIGINT o SIGTERM.
+func servir() {
+	// Obtener puerto de .env o default 8085
+	portStr := os.Getenv("PORT")
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port == 0 {
+		port = 8085
+	}
+
+	// Claves de firma de los tokens (JWT_PRIVATE_KEY_FILE o JWT_SECRET)
+	if err := auth.CargarClaves(); err != nil {
+		log.Fatal("Error cargando claves JWT: ", err)
+	}
+
+	// Almacenamiento de portadas (STORAGE_DRIVER: local, s3 o memoria)
+	if err := almacenamiento.Cargar(); err != nil {
+		log.Fatal("Error configurando almacenamiento: ", err)
+	}
+
+	// Migraciones de esquema (ver paquete migraciones). Con DB_AUTO_MIGRATE=true se aplican las pendientes al iniciar.
+	if os.Getenv("DB_AUTO_MIGRATE") == "true" {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		aplicadas, err := migraciones.Up(ctx)
+		cancel()
+		if err != nil {
+			log.Fatal("Error aplicando migraciones: ", err)
+		}
+		log.Printf("Migraciones aplicadas: %d", aplicadas)
+	}
+	registrarBorradoLogico()
+
+	// SIGINT/SIGTERM (ej: un deploy) cancelan ctx: se detienen las tareas periódicas y el servidor deja de aceptar
+	// conexiones, esperando a que terminen las solicitudes en curso.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Tareas periódicas, ya con el esquema migrado. Al apagar se espera que terminen antes de cerrar la BD.
+	var tareas []<-chan struct{}
+
+	// Reconciliación periódica de portadas (RECONCILIAR_INTERVALO, ej: "24h"; vacío la desactiva).
+	// Con RECONCILIAR_CORREGIR=true además borra archivos huérfanos y filas inconsistentes.
+	if intervalo, err := time.ParseDuration(os.Getenv("RECONCILIAR_INTERVALO")); err == nil && intervalo > 0 {
+		tareas = append(tareas, reconciliacion.Programar(ctx, intervalo, reconciliacion.Opciones{
+			Corregir:         os.Getenv("RECONCILIAR_CORREGIR") == "true",
+			AntiguedadMinima: time.Hour,
+		}))
+	}
+
+	// Purga periódica de la papelera (PAPELERA_PURGA_INTERVALO, por defecto "24h"; "0" la desactiva).
+	// Borra definitivamente lo que lleva en la papelera más de PAPELERA_RETENCION (por defecto "720h", 30 días).
+	if intervalo := duracionEntorno("PAPELERA_PURGA_INTERVALO", 24*time.Hour); intervalo > 0 {
+		tareas = append(tareas, papelera.Programar(ctx, intervalo, duracionEntorno("PAPELERA_RETENCION", 30*24*time.Hour)))
+	}
+
+	// Plazos del servidor (HTTP_*_TIMEOUT, ej: "30s"). ReadHeaderTimeout corta a los clientes que envían los headers
+	// de a poco; ReadTimeout y WriteTimeout deben alcanzar para subir una portada o un archivo de importación.
+	// La exportación extiende su propio plazo de escritura.
+	servidor := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           nuevoRouter(),
+		ReadHeaderTimeout: duracionEntorno("HTTP_READ_HEADER_TIMEOUT", 10*time.Second),
+		ReadTimeout:       duracionEntorno("HTTP_READ_TIMEOUT", 2*time.Minute),
+		WriteTimeout:      duracionEntorno("HTTP_WRITE_TIMEOUT", 3*time.Minute),
+		IdleTimeout:       duracionEntorno("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+	}
+
+	// Iniciar servidor
+	errServidor := make(chan error, 1)
+	go func() {
+		fmt.Printf("servidor iniciado en http://localhost:%d\n", port)
+		errServidor <- servidor.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errServidor:
+		log.Fatal("Error al iniciar el servidor: ", err)
+	case <-ctx.Done():
+	}
+	stop() // Una segunda señal termina el proceso de inmediato
+
+	// Espera a las solicitudes en curso como máximo HTTP_SHUTDOWN_TIMEOUT (por defecto "30s")
+	log.Println("Apagando servidor, esperando solicitudes en curso...")
+	ctxApagado, cancel := context.WithTimeout(context.Background(), duracionEntorno("HTTP_SHUTDOWN_TIMEOUT", 30*time.Second))
+	defer cancel()
+	if err := servidor.Shutdown(ctxApagado); err != nil {
+		log.Printf("Error apagando el servidor: %v", err)
+	}
+
+	// ctx ya está cancelado: las tareas periódicas terminan la ejecución en curso, si hay una, y se detienen
+	for _, terminada := range tareas {
+		<-terminada
+	}
+
+	if err := db.CerrarDB(); err != nil {
+		log.Printf("Error cerrando la conexión a MySQL: %v", err)
+	}
+	log.Println("Servidor detenido.")
+}
+
+// duracionEntorno lee una duración (ej: "24h") de la variable de entorno nombre, o retorna porDefecto si no está definida.
+func duracionEntorno(nombre string, porDefecto time.Duration) time.Duration {
+	v := os.Getenv(nombre)
+	if v == "" {
+		return porDefecto
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("Valor inválido en %s: %v", nombre, err)
+	}
+	return d
+}
+
+// nuevoRouter crea el router de Gin con todas las rutas HTTP.
+func nuevoRouter() *gin.Engine {
+	// Configurar Gin en modo release (sin logs verbose)
+	gin.SetMode(gin.ReleaseMode)
+
+	// Crear router
+	router := gin.Default()
+
+	// Cada solicitud lleva un X-Request-ID, que queda en la auditoría
+	router.Use(helpers.MiddlewareIDSolicitud())
+
+	// Rutas y métodos inexistentes responden con el mismo formato de error que los handlers
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(func(c *gin.Context) {
+		respuesta.NoEncontrado(c, "Ruta no encontrada")
+	})
+	router.NoMethod(func(c *gin.Context) {
+		respuesta.Error(c, http.StatusMethodNotAllowed, respuesta.CodigoMetodoNoPermitido, "Método no permitido")
+	})
+
+	// Definición de Rutas HTTP
+	// Ruta para archivos estaticos
+	router.Static("/fotos", "./public/upload/fotos")
+	router.GET(almacenamiento.RutaImagenes+"*clave", rutas.ServirImagen) // Portadas, desde el almacenamiento configurado
+
+	// Claves públicas para que otros servicios verifiquen los tokens
+	router.GET("/.well-known/jwks.json", auth.JWKS)
+
+	// Grupo prefijo
+	apiV1 := router.Group(prefijo)
+	{
+		apiV1.POST("/login", rutas.Login) // Ruta publica
+
+		// Rutas de sesión, públicas porque el access token puede haber expirado
+		authGroup := apiV1.Group("/auth")
+		{
+			authGroup.POST("/refresh", rutas.Refrescar)
+			authGroup.POST("/logout", rutas.Logout)
+		}
+
+		// Grupo protegido general
+		protected := apiV1.Group("/")
+		protected.Use(auth.AuthMiddleware()) // Middleware de autenticación global para estos grupos
+		{
+
+			// Cada ruta exige un permiso del perfil (ver config.Permisos y /perfiles/:id/permisos)
+			tematicasGroup := protected.Group("/tematicas")
+			{
+				tematicasGroup.GET("", auth.RequirePermission("tematicas:read"), rutas.ConsultarTematicas)
+				tematicasGroup.GET("/slug/:slug", auth.RequirePermission("tematicas:read"), rutas.ConsultarTematicaPorSlug)
+				tematicasGroup.GET("/trash", auth.RequirePermission("tematicas:read"), rutas.ConsultarPapeleraTematicas)
+				tematicasGroup.GET("/:id", auth.RequirePermission("tematicas:read"), rutas.ConsultarTematicasPorId)
+				tematicasGroup.POST("", auth.RequirePermission("tematicas:write"), rutas.CrearTematica)
+				tematicasGroup.PUT("/:id", auth.RequirePermission("tematicas:write"), rutas.EditarTematica)
+				tematicasGroup.PATCH("/:id", auth.RequirePermission("tematicas:write"), rutas.ModificarTematica)
+				tematicasGroup.DELETE("/:id", auth.RequirePermission("tematicas:delete"), rutas.EliminarTematica)
+				tematicasGroup.POST("/:id/restore", auth.RequirePermission("tematicas:delete"), rutas.RestaurarTematica)
+			}
+
+			peliculasGroup := protected.Group("/peliculas")
+			{
+				peliculasGroup.GET("", auth.RequirePermission("peliculas:read"), rutas.ConsultarPeliculas)
+				peliculasGroup.GET("/search", auth.RequirePermission("peliculas:read"), rutas.BuscarPeliculas)
+				peliculasGroup.POST("/import", auth.RequirePermission("peliculas:write"), rutas.ImportarPeliculas)
+				peliculasGroup.GET("/export", auth.RequirePermission("peliculas:read"), rutas.ExportarPeliculas)
+				peliculasGroup.GET("/slug/:slug", auth.RequirePermission("peliculas:read"), rutas.ConsultarPeliculaPorSlug)
+				peliculasGroup.GET("/trash", auth.RequirePermission("peliculas:read"), rutas.ConsultarPapeleraPeliculas)
+				peliculasGroup.GET("/:id", auth.RequirePermission("peliculas:read"), rutas.ConsultarPeliculaPorId)
+				peliculasGroup.POST("", auth.RequirePermission("peliculas:write"), rutas.CrearPelicula)
+				peliculasGroup.PUT("/:id", auth.RequirePermission("peliculas:write"), rutas.EditarPelicula)
+				peliculasGroup.PATCH("/:id", auth.RequirePermission("peliculas:write"), rutas.ModificarPelicula)
+				peliculasGroup.DELETE("/:id", auth.RequirePermission("peliculas:delete"), rutas.EliminarPelicula)
+				peliculasGroup.POST("/:id/restore", auth.RequirePermission("peliculas:delete"), rutas.RestaurarPelicula)
+
+				tematicasPeliculaGroup := peliculasGroup.Group("/:id/tematicas")
+				{
+					tematicasPeliculaGroup.GET("", auth.RequirePermission("peliculas:read"), rutas.ConsultarTematicasPelicula)
+					tematicasPeliculaGroup.POST("", auth.RequirePermission("peliculas:write"), rutas.CrearTematicasPelicula)
+					tematicasPeliculaGroup.DELETE("/:idt", auth.RequirePermission("peliculas:delete"), rutas.EliminarTematicaPelicula)
+				}
+
+				portadaPeliculaGroup := peliculasGroup.Group("/:id/portada")
+				{
+					portadaPeliculaGroup.GET("", auth.RequirePermission("peliculas:read"), rutas.ConsultarPortadasPelicula)
+					portadaPeliculaGroup.POST("", auth.RequirePermission("peliculas:write"), rutas.CrearPortada)
+					portadaPeliculaGroup.DELETE("/:idf", auth.RequirePermission("peliculas:delete"), rutas.EliminarImagenPelicula)
+				}
+
+				// Galería de imágenes de la película (posters, backdrops y stills)
+				imagenesPeliculaGroup := peliculasGroup.Group("/:id/imagenes")
+				{
+					imagenesPeliculaGroup.GET("", auth.RequirePermission("peliculas:read"), rutas.ConsultarImagenesPelicula)
+					imagenesPeliculaGroup.POST("", auth.RequirePermission("peliculas:write"), rutas.CrearImagenPelicula)
+					imagenesPeliculaGroup.PUT("/orden", auth.RequirePermission("peliculas:write"), rutas.OrdenarImagenesPelicula)
+					imagenesPeliculaGroup.POST("/:idf/principal", auth.RequirePermission("peliculas:write"), rutas.PromoverImagenPelicula)
+					imagenesPeliculaGroup.DELETE("/:idf", auth.RequirePermission("peliculas:delete"), rutas.EliminarImagenPelicula)
+				}
+			}
+
+			perfilesGroup := protected.Group("/perfiles")
+			{
+				perfilesGroup.GET("", auth.RequirePermission("perfiles:read"), rutas.ConsultarPerfiles)
+				perfilesGroup.GET("/:id", auth.RequirePermission("perfiles:read"), rutas.ConsultarPerfilPorId)
+				perfilesGroup.POST("", auth.RequirePermission("perfiles:write"), rutas.CrearPerfil)
+				perfilesGroup.PUT("/:id", auth.RequirePermission("perfiles:write"), rutas.EditarPerfil)
+				perfilesGroup.PATCH("/:id", auth.RequirePermission("perfiles:write"), rutas.ModificarPerfil)
+				perfilesGroup.DELETE("/:id", auth.RequirePermission("perfiles:delete"), rutas.EliminarPerfil)
+
+				permisosPerfilGroup := perfilesGroup.Group("/:id/permisos")
+				{
+					permisosPerfilGroup.GET("", auth.RequirePermission("perfiles:read"), rutas.ConsultarPermisosPerfil)
+					permisosPerfilGroup.POST("", auth.RequirePermission("permisos:write"), rutas.OtorgarPermisosPerfil)
+					permisosPerfilGroup.DELETE("/:codigo", auth.RequirePermission("permisos:write"), rutas.RevocarPermisoPerfil)
+				}
+			}
+
+			protected.GET("/permisos", auth.RequirePermission("perfiles:read"), rutas.ConsultarPermisos)
+			protected.GET("/auditoria", auth.RequirePermission("auditoria:read"), rutas.ConsultarAuditoria)
+
+			usuariosGroup := protected.Group("/usuarios")
+			{
+				usuariosGroup.GET("", auth.RequirePermission("usuarios:read"), rutas.ConsultarUsuarios)
+				usuariosGroup.GET("/trash", auth.RequirePermission("usuarios:read"), rutas.ConsultarPapeleraUsuarios)
+				usuariosGroup.GET("/:id", auth.RequirePermission("usuarios:read"), rutas.ConsultarUsuarioPorId)
+				usuariosGroup.POST("", auth.RequirePermission("usuarios:write"), rutas.CrearUsuario)
+				usuariosGroup.PUT("/:id", auth.RequirePermission("usuarios:write"), rutas.EditarUsuario)
+				usuariosGroup.PATCH("/:id", auth.RequirePermission("usuarios:write"), rutas.ModificarUsuario)
+				usuariosGroup.DELETE("/:id", auth.RequirePermission("usuarios:delete"), rutas.EliminarUsuario)
+				usuariosGroup.POST("/:id/restore", auth.RequirePermission("usuarios:delete"), rutas.RestaurarUsuario)
+			}
+		}
+		/*
+			// Grupo 1 users
+			usersGroup := apiV1.Group("/users")
+			{
+				usersGroup.GET("", rutas.GetUsers)    // GET /api/v1/users
+				usersGroup.POST("", rutas.CreateUser) // POST /api/v1/users
+			}
+
+			// Grupo 2 admin con middleware
+			adminGroup := apiV1.Group("/admin", adminMiddleware)
+			{
+				adminGroup.GET("/dashboard", rutas.AdminOnly) // GET /admin/dashboard
+			}
+		*/
+	}
+
+	return router
+}